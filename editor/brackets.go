@@ -0,0 +1,152 @@
+package editor
+
+import (
+	"NutCode/rope"
+	"NutCode/syntax"
+	"fmt"
+	"slices"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// How far away the matching bracket is looked for, in bytes, so that
+// drawing stays fast in large files
+const maxMatchDistance = 256 << 10
+
+// Quotes typed in pairs with autoPairs, along with the brackets
+const autoQuotes = "\"'`"
+
+// Parse bracket pairs written like "(:),[:]"
+func parsePairs(s string) ([][2]rune, error) {
+	var pairs [][2]rune
+	if s == "" {
+		return pairs, nil
+	}
+	for _, pair := range strings.Split(s, ",") {
+		r := []rune(pair)
+		if len(r) != 3 || r[1] != ':' || r[0] == r[2] {
+			return nil, fmt.Errorf("%q is not a pair like (:)", pair)
+		}
+		pairs = append(pairs, [2]rune{r[0], r[2]})
+	}
+	return pairs, nil
+}
+
+// Get the bracket pairs of the matchPairs option
+func (o Options) Pairs() [][2]rune {
+	pairs, _ := parsePairs(o.MatchPairs)
+	return pairs
+}
+
+// Brackets in strings and comments don't count when matching
+func ignoresBrackets(kind syntax.Kind) bool {
+	return kind == syntax.String || kind == syntax.Comment
+}
+
+// Find the bracket matching the one at an offset, false if there is no
+// bracket there or its match is not found. Brackets in strings and comments
+// are skipped, unless the one at offset is in one too. The text is gone
+// through once from offset, only as far as the match, and only in the text
+// loaded for large buffers.
+func (b *Buffer) MatchBracket(offset int) (int, bool) {
+	text, start := b.Text(), b.TextStart()
+	i := offset - start
+	if i < 0 || i >= len(text) {
+		return 0, false
+	}
+	r, _ := utf8.DecodeRuneInString(text[i:])
+	pairs := b.Options.Pairs()
+	p := slices.IndexFunc(pairs, func(p [2]rune) bool { return p[0] == r || p[1] == r })
+	if p < 0 {
+		return 0, false
+	}
+	opener, closer := pairs[p][0], pairs[p][1]
+	forward := r == opener
+	line := b.Content.LineAt(offset)
+	lineStart := strings.LastIndexByte(text[:i], '\n') + 1
+	tokens := b.Tokens(line)
+	skip := !ignoresBrackets(syntax.KindAt(tokens, i-lineStart))
+
+	depth := 0
+	for j := i; j < len(text) && max(j-i, i-j) <= maxMatchDistance; {
+		r, size := utf8.DecodeRuneInString(text[j:])
+		if r == '\n' {
+			// Tokens are by line
+			if forward {
+				line, lineStart = line+1, j+1
+			} else {
+				line, lineStart = line-1, strings.LastIndexByte(text[:j], '\n')+1
+			}
+			tokens = b.Tokens(line)
+		} else if (r == opener || r == closer) && !(skip && ignoresBrackets(syntax.KindAt(tokens, j-lineStart))) {
+			// Going forward openers nest deeper, going back closers do
+			if (r == opener) == forward {
+				depth++
+			} else {
+				depth--
+			}
+			if depth == 0 {
+				return start + j, true
+			}
+		}
+		if forward {
+			j += size
+		} else if j == 0 {
+			break
+		} else {
+			_, size = utf8.DecodeLastRuneInString(text[:j])
+			j -= size
+		}
+	}
+	return 0, false
+}
+
+// Make the edits for a character typed at an offset with autoPairs. An
+// opening bracket or a quote gets its closer after the cursor, and a closer
+// typed before the same closer goes over it. move is how far the cursor
+// goes from where the edits leave it. false when the character is typed
+// like any other.
+func AutoPair(content string, offset int, typed string, o Options) (rope.Transaction, int, bool) {
+	if !o.AutoPairs || utf8.RuneCountInString(typed) != 1 {
+		return nil, 0, false
+	}
+	r, _ := utf8.DecodeRuneInString(typed)
+	next, _ := utf8.DecodeRuneInString(content[offset:])
+	prev, _ := utf8.DecodeLastRuneInString(content[:offset])
+	pairs := o.Pairs()
+	isCloser := slices.ContainsFunc(pairs, func(p [2]rune) bool { return p[1] == next })
+	// Pairs are only typed where the closer doesn't end up glued to a word
+	free := offset == len(content) || unicode.IsSpace(next) || isCloser
+
+	isQuote := strings.ContainsRune(autoQuotes, r)
+	if next == r && (isQuote || slices.ContainsFunc(pairs, func(p [2]rune) bool { return p[1] == r })) {
+		return nil, len(typed), true
+	}
+	if isQuote {
+		if !free || IsWordChar(prev, o.WordChars) || prev == r {
+			return nil, 0, false
+		}
+		return rope.Transaction{{Offset: offset, Text: typed + typed}}, -len(typed), true
+	}
+	for _, p := range pairs {
+		if p[0] == r && free {
+			return rope.Transaction{{Offset: offset, Text: typed + string(p[1])}}, -utf8.RuneLen(p[1]), true
+		}
+	}
+	return nil, 0, false
+}
+
+// Check if the cursor is between an empty pair of brackets or quotes, which
+// Backspace deletes together with autoPairs
+func InEmptyPair(content string, offset int, o Options) bool {
+	if !o.AutoPairs || offset == 0 || offset == len(content) {
+		return false
+	}
+	prev, _ := utf8.DecodeLastRuneInString(content[:offset])
+	next, _ := utf8.DecodeRuneInString(content[offset:])
+	if strings.ContainsRune(autoQuotes, prev) {
+		return next == prev
+	}
+	return slices.Contains(o.Pairs(), [2]rune{prev, next})
+}
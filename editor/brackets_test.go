@@ -0,0 +1,103 @@
+package editor
+
+import (
+	"NutCode/syntax"
+	"strings"
+	"testing"
+)
+
+func TestMatchBracket(t *testing.T) {
+	content := "f(a[1], \")\") {\n\t// }\n\tg(«x»)\n}\n"
+	b := NewBufferFromString("", content)
+	b.SetSyntax(syntax.ByName("go"))
+
+	tests := []struct{ offset, match int }{
+		{1, 11},
+		{11, 1},
+		{3, 5},
+		{13, 31},
+		{31, 13},
+		{23, 29},
+		// Inside a string, brackets are matched without skipping anything
+		{9, 1},
+		{0, -1},
+		{len(content), -1},
+	}
+	for _, tt := range tests {
+		match, ok := b.MatchBracket(tt.offset)
+		if !ok {
+			match = -1
+		}
+		if match != tt.match {
+			t.Fatalf("Match of %d. Expected=%d, got=%d", tt.offset, tt.match, match)
+		}
+	}
+
+	if err := b.Options.Set("matchPairs", "(:),«:»"); err != nil {
+		t.Fatal(err)
+	}
+	if match, ok := b.MatchBracket(24); !ok || match != 27 {
+		t.Fatalf("Expected the match of a configured pair, got=%d", match)
+	}
+	if _, ok := b.MatchBracket(13); ok {
+		t.Fatalf("Expected no match for brackets left out of matchPairs")
+	}
+	if err := b.Options.Set("matchPairs", "(:),<>"); err == nil {
+		t.Fatalf("Expected an invalid pair")
+	}
+
+	// Matches too far away are not looked for
+	far := NewBufferFromString("", "("+strings.Repeat("x\n", maxMatchDistance/2)+")")
+	if _, ok := far.MatchBracket(0); ok {
+		t.Fatalf("Expected no match that far")
+	}
+	near := NewBufferFromString("", "("+strings.Repeat("x\n", maxMatchDistance/4)+")")
+	if match, ok := near.MatchBracket(near.Content.Len() - 1); !ok || match != 0 {
+		t.Fatalf("Expected the match many lines back, got=%d", match)
+	}
+}
+
+func TestAutoPair(t *testing.T) {
+	o := DefaultOptions()
+	o.AutoPairs = true
+	tests := []struct {
+		content  string
+		offset   int
+		typed    string
+		expected string
+		cursor   int
+	}{
+		{"", 0, "(", "()", 1},
+		{"f)", 1, "[", "f[])", 2},
+		{"x ", 2, "{", "x {}", 3},
+		{"()", 1, ")", "()", 2},
+		{"x", 0, "(", "", 0},
+		{"", 0, "\"", "\"\"", 1},
+		{"\"\"", 1, "\"", "\"\"", 2},
+		{"don", 3, "'", "", 0},
+		{"a", 1, "x", "", 0},
+	}
+	for _, tt := range tests {
+		t2, move, ok := AutoPair(tt.content, tt.offset, tt.typed, o)
+		if tt.expected == "" {
+			if ok {
+				t.Fatalf("Typing %s in %q. Expected no pair", tt.typed, tt.content)
+			}
+			continue
+		}
+		if got := t2.ApplyToString(tt.content); !ok || got != tt.expected {
+			t.Fatalf("Typing %s in %q. Expected=%q, got=%q", tt.typed, tt.content, tt.expected, got)
+		}
+		if cursor := t2.MapOffset(tt.offset) + move; cursor != tt.cursor {
+			t.Fatalf("Typing %s in %q. Expected the cursor at %d, got=%d", tt.typed, tt.content, tt.cursor, cursor)
+		}
+	}
+
+	if !InEmptyPair("(\"\")", 2, o) || !InEmptyPair("[]", 1, o) || InEmptyPair("(]", 1, o) {
+		t.Fatalf("Expected empty pairs to be found")
+	}
+	o.AutoPairs = false
+	if _, _, ok := AutoPair("", 0, "(", o); ok || InEmptyPair("()", 1, o) {
+		t.Fatalf("Expected no pairs without autoPairs")
+	}
+}
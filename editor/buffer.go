@@ -0,0 +1,545 @@
+package editor
+
+import (
+	"NutCode/rope"
+	"NutCode/syntax"
+	"NutCode/text"
+	"cmp"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+var ErrNoFileName = errors.New("No file name")
+
+// A file opened in the editor, along with everything needed to get back to
+// where the user left it
+type Buffer struct {
+	ID int
+	// The content, in a piece table, or a rope reading the file for large
+	// files (see the benchmarks of package text)
+	Content text.TextBuffer
+	Path    string
+	// Name shown for a buffer without a file, like a help view
+	Title string
+	Dirty bool
+	// Cursor offset and view, saved when the buffer is hidden
+	Offset   int
+	StartRow int
+	StartCol int
+	Options  Options
+	// Line endings of the file when it was read or last saved, empty if it
+	// had no line breaks, and whether it had other line endings too
+	LineEndings      string
+	MixedLineEndings bool
+	// Character set of the file when it was read or last saved, empty if
+	// there was no file
+	Encoding string
+	// The file is bigger than the largeFileSize option, see newLargeBuffer
+	Large bool
+	// Called before every change to the content, while it is still unchanged
+	Changing func(t rope.Transaction)
+	// Called after the content was written to the file of the buffer
+	Saved func()
+
+	// The content as a string, only the part around the cursors for large
+	// buffers, from textStart
+	text        string
+	textStart   int
+	highlighter *syntax.Highlighter
+	// Words for completion, nil until they are needed
+	words   *wordIndex
+	history History
+	// Position of the history when the file was last saved, -1 when the
+	// file differs from every state in it
+	saved int
+	// Offsets kept in step with every change, like the cursors of the windows showing the buffer
+	tracked []*int
+	// Windows showing the buffer, their extra cursors are kept in step too
+	windows []*EditorWindow
+	marks   map[rune]*int
+	// Problems a language server found, see SetDiagnostics
+	diagnostics []*Diagnostic
+	// File the rope of a large buffer reads from
+	file *os.File
+}
+
+// Create a buffer for a file, read with the options given. A file that does
+// not exist yet gives an empty buffer, the file is created when the buffer
+// is saved. A file that is not UTF-8 is read with the fallback encoding,
+// unless it has a byte order mark.
+func NewBuffer(path string, options Options) (*Buffer, error) {
+	info, err := os.Stat(path)
+	if err == nil && options.LargeFileSize > 0 && info.Size() >= int64(options.LargeFileSize)<<20 {
+		return newLargeBuffer(path, options)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, err
+	}
+	text, charset, err := DecodeText(data, options.FallbackEncoding)
+	if err != nil {
+		return nil, err
+	}
+	b := NewBufferFromString(path, text)
+	b.Options = options
+	b.Options.FileFormat = cmp.Or(b.LineEndings, options.FileFormat)
+	if len(data) > 0 {
+		b.Encoding = charset
+		b.Options.FileEncoding = charset
+	}
+	return b, nil
+}
+
+// Create a buffer holding the content of a file. Line endings are turned
+// into \n, the options remember which ones the file used.
+func NewBufferFromString(path, content string) *Buffer {
+	format, mixed := DetectFileFormat(content)
+	content = normalizeLineEndings(content)
+	b := &Buffer{
+		Content: text.NewPieceTable(content),
+		Path:    path,
+		Options: DefaultOptions(),
+		text:    content,
+
+		LineEndings:      format,
+		MixedLineEndings: mixed,
+	}
+	if format != "" {
+		b.Options.FileFormat = format
+	}
+	firstLine, _, _ := strings.Cut(content, "\n")
+	if g := syntax.Detect(path, firstLine); g != nil {
+		b.SetSyntax(g)
+	}
+	return b
+}
+
+// Name shown to the user
+func (b *Buffer) Name() string {
+	if b.Path == "" && b.Title != "" {
+		return b.Title
+	}
+	if b.Path == "" {
+		return "[No Name]"
+	}
+	return b.Path
+}
+
+// Get the content as a string. Large buffers only have the part of it
+// around the cursors, from TextStart, see LoadAround.
+func (b *Buffer) Text() string {
+	return b.text
+}
+
+// Get the offset in the content where Text starts, 0 but for large buffers
+func (b *Buffer) TextStart() int {
+	return b.textStart
+}
+
+// Apply a transaction, recording it in the undo history.
+// cursor is where the cursor was before the change.
+func (b *Buffer) Apply(t rope.Transaction, cursor int) {
+	if len(t) == 0 {
+		return
+	}
+	b.changing(t)
+	inverse := text.Apply(b.Content, t)
+	b.applyText(t)
+	b.Dirty = true
+	b.history.record(change{forward: t, inverse: inverse, cursor: cursor})
+	b.mapOffsets(t)
+}
+
+func (b *Buffer) changing(t rope.Transaction) {
+	if b.Changing != nil {
+		b.Changing(t)
+	}
+}
+
+// Make the same change to the text as to the rope, only what follows the
+// first changed line has to be highlighted again
+func (b *Buffer) applyText(t rope.Transaction) {
+	if b.Large {
+		b.applyLoadedText(t)
+		return
+	}
+	if b.highlighter != nil {
+		first := len(b.text)
+		for _, e := range t {
+			first = min(first, e.Offset)
+		}
+		b.highlighter.Invalidate(strings.Count(b.text[:first], "\n"))
+	}
+	if b.words != nil && len(t) > 0 {
+		b.words.update(b, t, b.text)
+	}
+	b.text = t.ApplyToString(b.text)
+}
+
+// Get a line, without its newline. Only that line is read from the content.
+func (b *Buffer) Line(i int) string {
+	if i < 0 || i >= b.Content.LineCount() {
+		return ""
+	}
+	line := b.Content.Slice(b.Content.LineStart(i), b.Content.LineStart(i+1))
+	return strings.TrimSuffix(line, "\n")
+}
+
+// Highlight the buffer with a lexer, nil turns highlighting off
+func (b *Buffer) SetSyntax(lexer syntax.Lexer) {
+	b.highlighter = nil
+	if lexer != nil {
+		b.highlighter = syntax.NewHighlighter(lexer)
+	}
+}
+
+// Get the lexer used to highlight the buffer, nil if there is none
+func (b *Buffer) Syntax() syntax.Lexer {
+	if b.highlighter == nil {
+		return nil
+	}
+	return b.highlighter.Lexer()
+}
+
+// Get the highlighted tokens of a line
+func (b *Buffer) Tokens(line int) []syntax.Token {
+	if b.highlighter == nil {
+		return nil
+	}
+	return b.highlighter.Tokens(b, line)
+}
+
+// Keep an offset in step with changes to the buffer
+func (b *Buffer) Track(offset *int) {
+	b.tracked = append(b.tracked, offset)
+}
+
+func (b *Buffer) Untrack(offset *int) {
+	for i, tracked := range b.tracked {
+		if tracked == offset {
+			b.tracked = append(b.tracked[:i], b.tracked[i+1:]...)
+			return
+		}
+	}
+}
+
+// Keep the cursors of a window showing the buffer in step with its changes
+func (b *Buffer) trackWindow(ew *EditorWindow) {
+	b.Track(&ew.Cursor.offset)
+	b.windows = append(b.windows, ew)
+}
+
+func (b *Buffer) untrackWindow(ew *EditorWindow) {
+	b.Untrack(&ew.Cursor.offset)
+	if i := slices.Index(b.windows, ew); i >= 0 {
+		b.windows = slices.Delete(b.windows, i, i+1)
+	}
+}
+
+func (b *Buffer) mapOffsets(t rope.Transaction) {
+	b.Offset = t.MapOffset(b.Offset)
+	for _, offset := range b.tracked {
+		*offset = t.MapOffset(*offset)
+	}
+	for _, ew := range b.windows {
+		ew.MapCursors(t, ew.Cursor.offset)
+	}
+}
+
+// Mark the buffer as differing from its file, even after undoing every
+// change since it was saved, like when an option changes how it is written
+func (b *Buffer) MarkDirty() {
+	b.Dirty = true
+	b.saved = -1
+}
+
+// Group all changes until EndGroup into one undo step
+func (b *Buffer) BeginGroup() {
+	b.history.begin()
+}
+
+func (b *Buffer) EndGroup() {
+	b.history.end()
+}
+
+// Revert the last undo step, returns where the cursor should go
+func (b *Buffer) Undo() (int, bool) {
+	group, ok := b.history.popUndo()
+	if !ok {
+		return 0, false
+	}
+	for i := len(group) - 1; i >= 0; i-- {
+		b.changing(group[i].inverse)
+		text.Apply(b.Content, group[i].inverse)
+		b.applyText(group[i].inverse)
+		b.mapOffsets(group[i].inverse)
+	}
+	b.Dirty = b.history.position() != b.saved
+	return group[0].cursor, true
+}
+
+// Redo the last undone step, returns where the cursor should go
+func (b *Buffer) Redo() (int, bool) {
+	group, ok := b.history.popRedo()
+	if !ok {
+		return 0, false
+	}
+	for _, c := range group {
+		b.changing(c.forward)
+		text.Apply(b.Content, c.forward)
+		b.applyText(c.forward)
+		b.mapOffsets(c.forward)
+	}
+	b.Dirty = b.history.position() != b.saved
+	return group[0].cursor, true
+}
+
+// Write the content to the buffer's file, or to path if given. Trailing
+// whitespace and the final newline are fixed first if the options say so,
+// as a change that can be undone.
+func (b *Buffer) Save(path string) error {
+	if path == "" {
+		path = b.Path
+	}
+	if path == "" {
+		return ErrNoFileName
+	}
+	b.cleanUp()
+	if b.Large {
+		if err := b.writeLargeFile(path); err != nil {
+			return err
+		}
+	} else {
+		data, err := b.encode()
+		if err != nil {
+			return err
+		}
+		if err := os.WriteFile(path, data, 0644); err != nil {
+			return err
+		}
+	}
+	if b.Path == "" {
+		b.Path = path
+	}
+	if path == b.Path {
+		b.Dirty = false
+		b.saved = b.history.position()
+		b.LineEndings = b.Options.FileFormat
+		b.MixedLineEndings = false
+		b.Encoding = b.Options.FileEncoding
+		if b.Saved != nil {
+			b.Saved()
+		}
+	}
+	return nil
+}
+
+// Remove trailing whitespace and add the final newline
+func (b *Buffer) cleanUp() {
+	var t rope.Transaction
+	if b.Options.TrimTrailingWhitespace {
+		// Go through the chunks of the content, a run of whitespace can
+		// span several of them
+		offset, run := 0, -1
+		for chunk := range b.Content.Chunks() {
+			for i := 0; i < len(chunk); i++ {
+				switch chunk[i] {
+				case ' ', '\t':
+					if run == -1 {
+						run = offset + i
+					}
+				case '\n':
+					if run != -1 {
+						t = append(t, rope.Edit{Offset: run, Length: offset + i - run})
+					}
+					run = -1
+				default:
+					run = -1
+				}
+			}
+			offset += len(chunk)
+		}
+		if run != -1 {
+			t = append(t, rope.Edit{Offset: run, Length: offset - run})
+		}
+	}
+	n := b.Content.Len()
+	if b.Options.InsertFinalNewline && n > 0 && b.Content.Slice(n-1, n) != "\n" {
+		t = append(t, rope.Edit{Offset: n, Text: "\n"})
+	}
+	cursor := b.Offset
+	if len(b.tracked) > 0 {
+		cursor = *b.tracked[0]
+	}
+	b.Apply(t, cursor)
+}
+
+// Get the bytes written to the file, with the line endings and character
+// set of the options
+func (b *Buffer) encode() ([]byte, error) {
+	text := b.text
+	if b.Options.FileFormat != "unix" {
+		text = strings.ReplaceAll(text, "\n", FileFormats[b.Options.FileFormat])
+	}
+	return EncodeText(text, b.Options.FileEncoding)
+}
+
+// The open buffers, one of which is shown
+type BufferList struct {
+	buffers []*Buffer
+	current int
+	nextID  int
+	// Called on every buffer added, to set its options
+	Configure func(*Buffer)
+	// Called on every buffer deleted, before it is closed
+	Closing func(*Buffer)
+	// Options files are read with
+	Options Options
+}
+
+func NewBufferList() *BufferList {
+	return &BufferList{nextID: 1, Options: DefaultOptions()}
+}
+
+func (bl *BufferList) Current() *Buffer {
+	return bl.buffers[bl.current]
+}
+
+func (bl *BufferList) Buffers() []*Buffer {
+	return bl.buffers
+}
+
+// Open a file and show it. A file that is already open is shown instead of being read again.
+func (bl *BufferList) Open(path string) (*Buffer, error) {
+	for i, b := range bl.buffers {
+		if b.Path != "" && samePath(b.Path, path) {
+			bl.current = i
+			return b, nil
+		}
+	}
+	b, err := NewBuffer(path, bl.Options)
+	if err != nil {
+		return nil, err
+	}
+	bl.Add(b)
+	return b, nil
+}
+
+// Add a buffer to the list and show it
+func (bl *BufferList) Add(b *Buffer) {
+	b.ID = bl.nextID
+	bl.nextID++
+	if bl.Configure != nil {
+		bl.Configure(b)
+	}
+	bl.buffers = append(bl.buffers, b)
+	bl.current = len(bl.buffers) - 1
+}
+
+// Show the next buffer, wrapping around
+func (bl *BufferList) Next() {
+	bl.current = (bl.current + 1) % len(bl.buffers)
+}
+
+// Show the previous buffer, wrapping around
+func (bl *BufferList) Prev() {
+	bl.current = (bl.current - 1 + len(bl.buffers)) % len(bl.buffers)
+}
+
+// Show the buffer with an ID
+func (bl *BufferList) Show(id int) error {
+	i := bl.index(id)
+	if i == -1 {
+		return fmt.Errorf("Buffer %d does not exist", id)
+	}
+	bl.current = i
+	return nil
+}
+
+// Close the buffer with an ID, buffers with unsaved changes are only closed
+// when forced. Closing the last buffer leaves an empty one.
+func (bl *BufferList) Delete(id int, force bool) error {
+	i := bl.index(id)
+	if i == -1 {
+		return fmt.Errorf("Buffer %d does not exist", id)
+	}
+	if bl.buffers[i].Dirty && !force {
+		return fmt.Errorf("No write since last change for buffer %d (add ! to override)", id)
+	}
+	if bl.Closing != nil {
+		bl.Closing(bl.buffers[i])
+	}
+	bl.buffers[i].Close()
+	bl.buffers = append(bl.buffers[:i], bl.buffers[i+1:]...)
+	if len(bl.buffers) == 0 {
+		bl.Add(NewBufferFromString("", ""))
+		return nil
+	}
+	if bl.current > i || bl.current == len(bl.buffers) {
+		bl.current--
+	}
+	return nil
+}
+
+// Get the first buffer with unsaved changes, if any
+func (bl *BufferList) Modified() *Buffer {
+	for _, b := range bl.buffers {
+		if b.Dirty {
+			return b
+		}
+	}
+	return nil
+}
+
+func (bl *BufferList) index(id int) int {
+	for i, b := range bl.buffers {
+		if b.ID == id {
+			return i
+		}
+	}
+	return -1
+}
+
+func samePath(a, b string) bool {
+	absA, errA := filepath.Abs(a)
+	absB, errB := filepath.Abs(b)
+	if errA != nil || errB != nil {
+		return a == b
+	}
+	return absA == absB
+}
+
+// Show a buffer in the window, where it was left when it was last hidden
+func (ew *EditorWindow) ShowBuffer(b *Buffer) {
+	if ew.Buffer == b {
+		return
+	}
+	if ew.Buffer != nil {
+		ew.StoreView(ew.Buffer)
+		ew.Buffer.untrackWindow(ew)
+	}
+	ew.Buffer = b
+	ew.Cursor.offset = b.Offset
+	b.trackWindow(ew)
+	ew.LoadView(b)
+}
+
+// Save the view of the window into a buffer that is about to be hidden
+func (ew *EditorWindow) StoreView(b *Buffer) {
+	b.Offset = ew.Cursor.offset
+	b.StartRow = ew.startRow
+	b.StartCol = ew.StartCol
+}
+
+// Show a buffer the way it was when it was hidden
+func (ew *EditorWindow) LoadView(b *Buffer) {
+	ew.useOptions(b.Options)
+	ew.startRow = b.StartRow
+	ew.StartCol = b.StartCol
+	ew.ClearCursors()
+	ew.ClearSelection()
+}
@@ -0,0 +1,167 @@
+package editor
+
+import (
+	"NutCode/rope"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestBufferUndoRedo(t *testing.T) {
+	b := NewBufferFromString("", "hello world")
+
+	b.Apply(rope.Transaction{{Offset: 5, Length: 6}}, 5)
+	b.BeginGroup()
+	b.Apply(rope.Transaction{{Offset: 5, Text: ","}}, 5)
+	b.Apply(rope.Transaction{{Offset: 6, Text: " you"}}, 6)
+	b.EndGroup()
+	if b.Text() != "hello, you" {
+		t.Fatalf("Content mismatch. Expected=hello, you, got=%s", b.Text())
+	}
+
+	steps := []struct {
+		undo     bool
+		expected string
+		cursor   int
+	}{
+		{true, "hello", 5},
+		{true, "hello world", 5},
+		{false, "hello", 5},
+		{false, "hello, you", 5},
+	}
+	for _, v := range steps {
+		var cursor int
+		var ok bool
+		if v.undo {
+			cursor, ok = b.Undo()
+		} else {
+			cursor, ok = b.Redo()
+		}
+		if !ok {
+			t.Fatalf("Nothing to undo/redo, expected=%s", v.expected)
+		}
+		if b.Text() != v.expected || b.Content.String() != v.expected {
+			t.Fatalf("Content mismatch. Expected=%s, got=%s", v.expected, b.Text())
+		}
+		if cursor != v.cursor {
+			t.Fatalf("Cursor mismatch. Expected=%d, got=%d", v.cursor, cursor)
+		}
+	}
+	if _, ok := b.Redo(); ok {
+		t.Fatalf("Redo past the newest change")
+	}
+
+	// A new change drops what could be redone
+	b.Undo()
+	b.Apply(rope.Transaction{{Offset: 0, Text: "oh "}}, 0)
+	if _, ok := b.Redo(); ok {
+		t.Fatalf("Redo after a new change")
+	}
+}
+
+func TestBufferLine(t *testing.T) {
+	b := NewBufferFromString("", "one\ntwo\nthree")
+	b.Apply(rope.Transaction{{Offset: 4, Length: 3, Text: "2\n2b"}}, 4)
+	for i, expected := range []string{"one", "2", "2b", "three", ""} {
+		if line := b.Line(i); line != expected {
+			t.Fatalf("Line %d. Expected=%q, got=%q", i, expected, line)
+		}
+	}
+}
+
+func TestBufferDirty(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "dirty.txt")
+	b := NewBufferFromString(path, "hello")
+
+	b.Apply(rope.Transaction{{Offset: 5, Text: " world"}}, 5)
+	if err := b.Save(""); err != nil {
+		t.Fatal(err)
+	}
+	steps := []struct {
+		undo  bool
+		dirty bool
+	}{
+		{true, true},
+		{false, false},
+		{false, false},
+		{true, true},
+	}
+	for i, v := range steps {
+		if v.undo {
+			b.Undo()
+		} else {
+			b.Redo()
+		}
+		if b.Dirty != v.dirty {
+			t.Fatalf("Step %d: expected dirty=%v, got %v", i, v.dirty, b.Dirty)
+		}
+	}
+
+	// Back to the saved text by a new change, which is not the saved state
+	b.Apply(rope.Transaction{{Offset: 5, Text: " world"}}, 5)
+	if !b.Dirty {
+		t.Fatalf("Expected a new change to make the buffer dirty")
+	}
+	b.Undo()
+	b.MarkDirty()
+	b.Undo()
+	b.Redo()
+	if !b.Dirty {
+		t.Fatalf("Expected MarkDirty to last through undo and redo")
+	}
+
+	// Saving is told even when there was nothing to save, but not when
+	// writing to another file
+	saved := 0
+	b.Saved = func() { saved++ }
+	b.Save("")
+	b.Save("")
+	b.Save(filepath.Join(t.TempDir(), "copy.txt"))
+	if saved != 2 {
+		t.Fatalf("Expected 2 saves, got=%d", saved)
+	}
+}
+
+func TestBufferListDelete(t *testing.T) {
+	bl := NewBufferList()
+	bl.Add(NewBufferFromString("a", ""))
+	bl.Add(NewBufferFromString("b", ""))
+	bl.Add(NewBufferFromString("c", ""))
+	bl.Show(2)
+
+	bl.Current().Dirty = true
+	if err := bl.Delete(2, false); err == nil {
+		t.Fatalf("Deleted a buffer with unsaved changes")
+	}
+	if err := bl.Delete(2, true); err != nil {
+		t.Fatal(err)
+	}
+	if bl.Current().Path != "c" {
+		t.Fatalf("Expected to show buffer c, got=%s", bl.Current().Path)
+	}
+	bl.Delete(3, false)
+	bl.Delete(1, false)
+	if len(bl.Buffers()) != 1 || bl.Current().Path != "" {
+		t.Fatalf("Expected a single empty buffer after deleting all")
+	}
+}
+
+func TestBufferListOpen(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "file.txt")
+	os.WriteFile(path, []byte("content"), 0644)
+
+	bl := NewBufferList()
+	b, err := bl.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b.Text() != "content" {
+		t.Fatalf("Content mismatch. Expected=content, got=%s", b.Text())
+	}
+	bl.Open(filepath.Join(dir, "new.txt"))
+	again, _ := bl.Open(path)
+	if again != b || len(bl.Buffers()) != 2 {
+		t.Fatalf("Opening a file twice should reuse its buffer")
+	}
+}
@@ -0,0 +1,139 @@
+package editor
+
+import (
+	"encoding/base64"
+	"errors"
+	"io"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+var ErrClipboardUnavailable = errors.New("clipboard unavailable")
+
+// The system clipboard
+type Clipboard interface {
+	Copy(text string) error
+	Paste() (string, error)
+}
+
+// Clipboard using the OSC 52 escape sequence. The terminal sets its own
+// clipboard, which also works over SSH. Reading is not supported since most
+// terminals refuse to answer OSC 52 queries.
+type OSC52Clipboard struct {
+	w io.Writer
+}
+
+func NewOSC52Clipboard(w io.Writer) *OSC52Clipboard {
+	return &OSC52Clipboard{w: w}
+}
+
+func (c *OSC52Clipboard) Copy(text string) error {
+	seq := "\x1b]52;c;" + base64.StdEncoding.EncodeToString([]byte(text)) + "\a"
+	if os.Getenv("TMUX") != "" {
+		// tmux only passes the sequence on to the terminal when wrapped
+		seq = "\x1bPtmux;" + strings.ReplaceAll(seq, "\x1b", "\x1b\x1b") + "\x1b\\"
+	}
+	_, err := io.WriteString(c.w, seq)
+	return err
+}
+
+func (c *OSC52Clipboard) Paste() (string, error) {
+	return "", ErrClipboardUnavailable
+}
+
+// Clipboard shelling out to a helper program such as xclip or wl-copy
+type CommandClipboard struct {
+	copyCmd  []string
+	pasteCmd []string
+}
+
+// Helper programs to try, in order
+var clipboardCommands = []struct {
+	env   string
+	copy  []string
+	paste []string
+}{
+	{"WAYLAND_DISPLAY", []string{"wl-copy"}, []string{"wl-paste", "--no-newline"}},
+	{"DISPLAY", []string{"xclip", "-selection", "clipboard"}, []string{"xclip", "-selection", "clipboard", "-o"}},
+	{"DISPLAY", []string{"xsel", "--clipboard", "--input"}, []string{"xsel", "--clipboard", "--output"}},
+	{"", []string{"pbcopy"}, []string{"pbpaste"}},
+}
+
+// Find a clipboard program that is installed and usable in this session,
+// returns nil if there is none
+func FindCommandClipboard() *CommandClipboard {
+	for _, cmd := range clipboardCommands {
+		if cmd.env != "" && os.Getenv(cmd.env) == "" {
+			continue
+		}
+		if _, err := exec.LookPath(cmd.copy[0]); err != nil {
+			continue
+		}
+		if _, err := exec.LookPath(cmd.paste[0]); err != nil {
+			continue
+		}
+		return &CommandClipboard{copyCmd: cmd.copy, pasteCmd: cmd.paste}
+	}
+	return nil
+}
+
+func (c *CommandClipboard) Copy(text string) error {
+	cmd := exec.Command(c.copyCmd[0], c.copyCmd[1:]...)
+	cmd.Stdin = strings.NewReader(text)
+	return cmd.Run()
+}
+
+func (c *CommandClipboard) Paste() (string, error) {
+	out, err := exec.Command(c.pasteCmd[0], c.pasteCmd[1:]...).Output()
+	if err != nil {
+		return "", err
+	}
+	return string(out), nil
+}
+
+// Clipboard copying with OSC 52 and, when installed, a helper program.
+// Pasting needs the helper program.
+type SystemClipboard struct {
+	osc52   *OSC52Clipboard
+	command *CommandClipboard
+}
+
+// Set up the system clipboard. tty may be nil if OSC 52 should not be used,
+// useCommand enables the fallback to xclip/wl-copy and friends.
+func NewSystemClipboard(tty io.Writer, useCommand bool) *SystemClipboard {
+	c := &SystemClipboard{}
+	if tty != nil {
+		c.osc52 = NewOSC52Clipboard(tty)
+	}
+	if useCommand {
+		c.command = FindCommandClipboard()
+	}
+	return c
+}
+
+func (c *SystemClipboard) Copy(text string) error {
+	if c.osc52 == nil && c.command == nil {
+		return ErrClipboardUnavailable
+	}
+	var err error
+	if c.osc52 != nil {
+		err = c.osc52.Copy(text)
+	}
+	if c.command != nil {
+		// The helper program succeeding is good enough
+		if cmdErr := c.command.Copy(text); cmdErr == nil {
+			return nil
+		} else if c.osc52 == nil {
+			err = cmdErr
+		}
+	}
+	return err
+}
+
+func (c *SystemClipboard) Paste() (string, error) {
+	if c.command == nil {
+		return "", ErrClipboardUnavailable
+	}
+	return c.command.Paste()
+}
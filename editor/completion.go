@@ -0,0 +1,207 @@
+package editor
+
+import (
+	"NutCode/rope"
+	"slices"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/mattn/go-runewidth"
+)
+
+// Words shorter than this are not worth completing
+const minWordLength = 2
+
+// Most words offered at once, the ones that appear most come first
+const maxCompletions = 100
+
+// Rows of the completion menu, more items scroll
+const maxMenuRows = 8
+
+// Words of a buffer for completion. They are kept line by line, so that an
+// edit only has the lines it changed read again.
+type wordIndex struct {
+	wordChars string
+	lines     [][]string
+	counts    map[string]int
+}
+
+// Split a line into its words
+func lineWords(line, wordChars string) []string {
+	var words []string
+	start := -1
+	for i, r := range line + "\n" {
+		if IsWordChar(r, wordChars) {
+			if start < 0 {
+				start = i
+			}
+			continue
+		}
+		if start >= 0 && utf8.RuneCountInString(line[start:i]) >= minWordLength {
+			words = append(words, line[start:i])
+		}
+		start = -1
+	}
+	return words
+}
+
+// Read the words of lines of the buffer, from first up to end
+func (b *Buffer) readWords(first, end int) [][]string {
+	lines := make([][]string, 0, end-first)
+	for line := first; line < end; line++ {
+		text := b.Content.Slice(b.Content.LineStart(line), b.Content.LineStart(line+1))
+		lines = append(lines, lineWords(text, b.Options.WordChars))
+	}
+	return lines
+}
+
+func (w *wordIndex) count(lines [][]string, n int) {
+	for _, words := range lines {
+		for _, word := range words {
+			if w.counts[word] += n; w.counts[word] == 0 {
+				delete(w.counts, word)
+			}
+		}
+	}
+}
+
+// Get the index of the words of the buffer, reading them all the first time
+// and after the word characters changed
+func (b *Buffer) wordIndex() *wordIndex {
+	if b.words == nil || b.words.wordChars != b.Options.WordChars {
+		b.words = &wordIndex{
+			wordChars: b.Options.WordChars,
+			lines:     b.readWords(0, b.Content.LineCount()),
+			counts:    make(map[string]int),
+		}
+		b.words.count(b.words.lines, 1)
+	}
+	return b.words
+}
+
+// Read the words of the lines a transaction changed again. old is the text
+// before the change, the content is already changed.
+func (w *wordIndex) update(b *Buffer, t rope.Transaction, old string) {
+	first, end, added := len(old), 0, 0
+	for _, e := range t {
+		first = min(first, e.Offset)
+		end = max(end, e.Offset+e.Length)
+		added += strings.Count(e.Text, "\n") - strings.Count(old[e.Offset:e.Offset+e.Length], "\n")
+	}
+	// Lines before the first edit are the same in both texts
+	firstLine := b.Content.LineAt(first)
+	endLine := firstLine + strings.Count(old[first:end], "\n") + 1
+	w.count(w.lines[firstLine:endLine], -1)
+	lines := b.readWords(firstLine, endLine+added)
+	w.count(lines, 1)
+	w.lines = slices.Replace(w.lines, firstLine, endLine, lines...)
+}
+
+// Find the words of the buffers starting with prefix, the words of the first
+// buffer before the others'. The words of each buffer come in order of how
+// often they appear.
+func CompleteWord(buffers []*Buffer, prefix string) []string {
+	var words []string
+	seen := make(map[string]bool)
+	for _, b := range buffers {
+		counts := b.wordIndex().counts
+		var found []string
+		for word := range counts {
+			if strings.HasPrefix(word, prefix) && word != prefix && !seen[word] {
+				found = append(found, word)
+				seen[word] = true
+			}
+		}
+		slices.SortFunc(found, func(x, y string) int {
+			if counts[x] != counts[y] {
+				return counts[y] - counts[x]
+			}
+			return strings.Compare(x, y)
+		})
+		words = append(words, found...)
+		if len(words) >= maxCompletions {
+			return words[:maxCompletions]
+		}
+	}
+	return words
+}
+
+// Find the start of the word before an offset, offset when there is none
+func WordStart(content string, offset int, wordChars string) int {
+	for offset > 0 {
+		r, size := utf8.DecodeLastRuneInString(content[:offset])
+		if !IsWordChar(r, wordChars) {
+			break
+		}
+		offset -= size
+	}
+	return offset
+}
+
+// A menu of completions, drawn under the text it completes
+type Menu struct {
+	Items []string
+	// Text each item inserts, the items themselves when nil
+	Texts    []string
+	Selected int
+	// Start of the text completed, the menu lines up with it
+	Offset int
+}
+
+// Get the text the selected item inserts
+func (m *Menu) Text() string {
+	if m.Texts != nil {
+		return m.Texts[m.Selected]
+	}
+	return m.Items[m.Selected]
+}
+
+// Move the selection by n items, going round at the ends
+func (m *Menu) Select(n int) {
+	m.Selected = ((m.Selected+n)%len(m.Items) + len(m.Items)) % len(m.Items)
+}
+
+// Draw the menu under the line of the cursor, or above it when there is
+// more room there
+func (ew *EditorWindow) drawMenu() {
+	m := ew.Menu
+	if m == nil || len(m.Items) == 0 {
+		return
+	}
+	rows := min(len(m.Items), maxMenuRows)
+	// The last row of the window is the status bar
+	y := ew.cursorY + 1
+	if below := ew.height - 1 - y; below < rows && ew.cursorY > below {
+		rows = min(rows, ew.cursorY)
+		y = ew.cursorY - rows
+	} else {
+		rows = min(rows, below)
+	}
+	width := 0
+	for _, item := range m.Items {
+		width = max(width, runewidth.StringWidth(item)+2)
+	}
+	width = min(width, ew.width-ew.contentOffset)
+	text := ew.Buffer.Content
+	lineStart := text.LineStart(text.LineAt(m.Offset))
+	x := ew.contentOffset + textWidth(text.Slice(lineStart, m.Offset), ew.Buffer.Options.TabSize) - ew.StartCol
+	x = max(min(x, ew.width-width), ew.contentOffset)
+
+	// Scroll to keep the selected item in view
+	top := max(0, min(m.Selected-rows/2, len(m.Items)-rows))
+	for row := 0; row < rows; row++ {
+		style := theme.Menu
+		if top+row == m.Selected {
+			style = theme.MenuSelected
+		}
+		text := " " + runewidth.Truncate(m.Items[top+row], width-2, "…")
+		col := 0
+		for _, r := range text {
+			ew.setContent(x+col, y+row, r, style)
+			col += runewidth.RuneWidth(r)
+		}
+		for ; col < width; col++ {
+			ew.setContent(x+col, y+row, ' ', style)
+		}
+	}
+}
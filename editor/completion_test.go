@@ -0,0 +1,50 @@
+package editor
+
+import (
+	"NutCode/rope"
+	"reflect"
+	"testing"
+)
+
+func TestWordIndexFollowsEdits(t *testing.T) {
+	b := NewBufferFromString("", "alpha beta\ngamma_delta x\n\nbeta")
+	b.wordIndex()
+	for _, tr := range []rope.Transaction{
+		{{Offset: 0, Text: "new words\n"}},
+		{{Offset: 14, Length: 12, Text: "joined"}},
+		{{Offset: 3, Text: "\n\n"}, {Offset: 20, Length: 1}},
+		{{Offset: 0, Length: 5}},
+	} {
+		b.Apply(tr, 0)
+		fresh := NewBufferFromString("", b.Text())
+		if got, expected := b.wordIndex(), fresh.wordIndex(); !reflect.DeepEqual(got, expected) {
+			t.Fatalf("After %v. Expected=%v, got=%v", tr, expected, got)
+		}
+	}
+	b.Undo()
+	if got, expected := b.wordIndex(), NewBufferFromString("", b.Text()).wordIndex(); !reflect.DeepEqual(got, expected) {
+		t.Fatalf("After undo. Expected=%v, got=%v", expected, got)
+	}
+}
+
+func TestCompleteWord(t *testing.T) {
+	current := NewBufferFromString("", "format fmt for fo forge\nformat(x)")
+	other := NewBufferFromString("", "for_each forge foo_bar")
+	tests := []struct {
+		prefix   string
+		expected []string
+	}{
+		{"fo", []string{"format", "for", "forge", "foo_bar", "for_each"}},
+		{"for", []string{"format", "forge", "for_each"}},
+		{"zz", nil},
+	}
+	for _, tt := range tests {
+		if got := CompleteWord([]*Buffer{current, other}, tt.prefix); !reflect.DeepEqual(got, tt.expected) {
+			t.Fatalf("Completing %s. Expected=%v, got=%v", tt.prefix, tt.expected, got)
+		}
+	}
+
+	if got := WordStart("x := foo_ba", 11, "_"); got != 5 {
+		t.Fatalf("Expected the word to start at 5, got=%d", got)
+	}
+}
@@ -0,0 +1,232 @@
+package editor
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+)
+
+// Settings from the config file. Options can be set for files of a type,
+// by the name of their syntax:
+//
+//	tabSize = 4
+//	cursorStyle = "block"
+//	colorscheme = "light"
+//	[filetype.go]
+//	tabSize = 8
+//
+// Keys are bound to actions for each mode, an empty action unbinds them:
+//
+//	[keys.normal]
+//	"<C-p>" = "buffer.save"
+//	x = ""
+//
+// Language servers are started for files of a type with a command, an empty
+// one turns them off:
+//
+//	[lsp]
+//	python = ["pylsp"]
+//	go = []
+type Config struct {
+	Options     Options
+	ColorScheme string
+	// Send every yank to the system clipboard, not only yanks into "+ and "*
+	SyncClipboard bool
+	Keys          []Binding
+	// Commands of language servers, by lowercase syntax name
+	LanguageServers map[string][]string
+	// Options for file types, by lowercase syntax name
+	filetypes map[string][]setting
+}
+
+type setting struct {
+	name, value string
+}
+
+func DefaultConfig() *Config {
+	return &Config{
+		Options:         DefaultOptions(),
+		LanguageServers: map[string][]string{"go": {"gopls"}},
+		filetypes:       map[string][]setting{},
+	}
+}
+
+// Load a config file. A missing file gives the default config. Settings
+// that are wrong are left out and reported in the error, the rest of the
+// config is still used.
+func LoadConfig(path string) (*Config, error) {
+	c := DefaultConfig()
+	var file map[string]any
+	if _, err := toml.DecodeFile(path, &file); err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return c, nil
+		}
+		return c, err
+	}
+	errs := []error{}
+	for _, name := range sortedKeys(file) {
+		value := file[name]
+		switch name {
+		case "colorscheme":
+			scheme, ok := value.(string)
+			if !ok {
+				errs = append(errs, fmt.Errorf("colorscheme must be a string"))
+				continue
+			}
+			c.ColorScheme = scheme
+		case "syncClipboard":
+			sync, ok := value.(bool)
+			if !ok {
+				errs = append(errs, fmt.Errorf("syncClipboard must be true or false"))
+				continue
+			}
+			c.SyncClipboard = sync
+		case "filetype":
+			types, ok := value.(map[string]any)
+			if !ok {
+				errs = append(errs, fmt.Errorf("filetype must be a table of file types"))
+				continue
+			}
+			for _, filetype := range sortedKeys(types) {
+				options, ok := types[filetype].(map[string]any)
+				if !ok {
+					errs = append(errs, fmt.Errorf("filetype.%s must be a table of options", filetype))
+					continue
+				}
+				key := strings.ToLower(filetype)
+				for _, option := range sortedKeys(options) {
+					s, err := checkSetting(option, options[option])
+					if err != nil {
+						errs = append(errs, fmt.Errorf("filetype.%s: %w", filetype, err))
+						continue
+					}
+					c.filetypes[key] = append(c.filetypes[key], s)
+				}
+			}
+		case "keys":
+			errs = append(errs, c.loadKeys(value)...)
+		case "lsp":
+			errs = append(errs, c.loadLanguageServers(value)...)
+		default:
+			s, err := checkSetting(name, value)
+			if err == nil {
+				err = c.Options.Set(s.name, s.value)
+			}
+			if err != nil {
+				errs = append(errs, err)
+			}
+		}
+	}
+	if err := c.Options.Validate(); err != nil {
+		errs = append(errs, err)
+		c.Options = DefaultOptions()
+	}
+	for filetype := range c.filetypes {
+		o := c.OptionsFor(filetype)
+		if err := o.Validate(); err != nil {
+			errs = append(errs, fmt.Errorf("filetype.%s: %w", filetype, err))
+			delete(c.filetypes, filetype)
+		}
+	}
+	return c, errors.Join(errs...)
+}
+
+// Get the options for files of a type, given by the name of their syntax
+func (c *Config) OptionsFor(filetype string) Options {
+	o := c.Options
+	for _, s := range c.filetypes[strings.ToLower(filetype)] {
+		o.Set(s.name, s.value)
+	}
+	return o
+}
+
+// Read the key bindings of the config file, whose actions are checked
+// when they are bound
+func (c *Config) loadKeys(value any) []error {
+	modes, ok := value.(map[string]any)
+	if !ok {
+		return []error{fmt.Errorf("keys must be a table of modes")}
+	}
+	errs := []error{}
+	for _, mode := range sortedKeys(modes) {
+		if !slices.Contains(KeymapModes, mode) {
+			errs = append(errs, fmt.Errorf("keys: Unknown mode: %s", mode))
+			continue
+		}
+		bindings, ok := modes[mode].(map[string]any)
+		if !ok {
+			errs = append(errs, fmt.Errorf("keys.%s must be a table of keys", mode))
+			continue
+		}
+		for _, keys := range sortedKeys(bindings) {
+			action, ok := bindings[keys].(string)
+			if !ok {
+				errs = append(errs, fmt.Errorf("keys.%s: Action for %s must be a string", mode, keys))
+				continue
+			}
+			if _, err := ParseKeys(keys); err != nil {
+				errs = append(errs, fmt.Errorf("keys.%s: %w", mode, err))
+				continue
+			}
+			c.Keys = append(c.Keys, Binding{Mode: mode, Keys: keys, Action: action})
+		}
+	}
+	return errs
+}
+
+// Read the commands of language servers, lists of strings by file type
+func (c *Config) loadLanguageServers(value any) []error {
+	types, ok := value.(map[string]any)
+	if !ok {
+		return []error{fmt.Errorf("lsp must be a table of file types")}
+	}
+	errs := []error{}
+	for _, filetype := range sortedKeys(types) {
+		args, ok := types[filetype].([]any)
+		command := make([]string, 0, len(args))
+		for _, arg := range args {
+			s, isString := arg.(string)
+			ok = ok && isString
+			command = append(command, s)
+		}
+		if !ok {
+			errs = append(errs, fmt.Errorf("lsp.%s must be a list of strings", filetype))
+			continue
+		}
+		c.LanguageServers[strings.ToLower(filetype)] = command
+	}
+	return errs
+}
+
+// Turn a value from the config file into the text :set takes, checking it
+// on the default options
+func checkSetting(name string, value any) (setting, error) {
+	var text string
+	switch v := value.(type) {
+	case string:
+		text = v
+	case int64, bool:
+		text = fmt.Sprint(v)
+	default:
+		return setting{}, fmt.Errorf("Invalid value for %s: %v", name, value)
+	}
+	o := DefaultOptions()
+	if err := o.Set(name, text); err != nil {
+		return setting{}, err
+	}
+	return setting{name, text}, nil
+}
+
+func sortedKeys(m map[string]any) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
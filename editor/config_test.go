@@ -0,0 +1,87 @@
+package editor
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestLoadConfig(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.toml")
+	os.WriteFile(path, []byte(`
+tabSize = 2
+cursorStyle = "block"
+colorscheme = "light"
+syncClipboard = true
+lineNumberWidth = "wide"
+wrap = true
+
+[filetype.Go]
+tabSize = 8
+contentOffset = 3
+
+[lsp]
+Python = ["pylsp", "-v"]
+rust = "rust-analyzer"
+`), 0644)
+	cfg, err := LoadConfig(path)
+	if err == nil {
+		t.Fatalf("Invalid settings gave no error")
+	}
+	for _, expected := range []string{"lineNumberWidth", "wrap", "filetype.go", "lsp.rust"} {
+		if !strings.Contains(err.Error(), expected) {
+			t.Fatalf("Error does not mention %s: %v", expected, err)
+		}
+	}
+	if cfg.Options.TabSize != 2 || cfg.Options.CursorStyle != "block" || cfg.ColorScheme != "light" || !cfg.SyncClipboard {
+		t.Fatalf("Valid settings not loaded, got=%+v", cfg.Options)
+	}
+	if cfg.Options.LineNumberWidth != 5 {
+		t.Fatalf("Invalid setting should keep the default, got=%d", cfg.Options.LineNumberWidth)
+	}
+	if command := cfg.LanguageServers["python"]; len(command) != 2 || command[1] != "-v" {
+		t.Fatalf("Language server not loaded, got=%q", command)
+	}
+	if _, ok := cfg.LanguageServers["rust"]; ok {
+		t.Fatalf("Invalid language server was loaded")
+	}
+	// contentOffset 3 is less than lineNumberWidth, so the go options are dropped
+	if o := cfg.OptionsFor("go"); o.TabSize != 2 {
+		t.Fatalf("Invalid file type options were used, got=%+v", o)
+	}
+
+	os.WriteFile(path, []byte("[filetype.go]\ntabSize = 8\n"), 0644)
+	cfg, err = LoadConfig(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.OptionsFor("Go").TabSize != 8 || cfg.OptionsFor("markdown").TabSize != 4 {
+		t.Fatalf("File type options not applied")
+	}
+	if command := cfg.LanguageServers["go"]; len(command) != 1 || command[0] != "gopls" {
+		t.Fatalf("Expected gopls for go by default, got=%q", command)
+	}
+
+	if _, err := LoadConfig(filepath.Join(t.TempDir(), "missing.toml")); err != nil {
+		t.Fatalf("Missing config file gave an error: %v", err)
+	}
+}
+
+func TestSetOption(t *testing.T) {
+	o := DefaultOptions()
+	if err := o.Set("tabSize", "8"); err != nil || o.TabSize != 8 {
+		t.Fatalf("tabSize not set")
+	}
+	for _, setting := range [][2]string{{"tabSize", "0"}, {"tabSize", "x"}, {"cursorStyle", "round"}, {"fileEncoding", "klingon"}, {"nope", "1"}} {
+		if err := o.Set(setting[0], setting[1]); err == nil {
+			t.Fatalf("Setting %s=%s gave no error", setting[0], setting[1])
+		}
+	}
+	if err := o.Set("fileencoding", "Shift_JIS"); err != nil || o.FileEncoding != "shift_jis" {
+		t.Fatalf("fileEncoding not set")
+	}
+	if value, _ := o.Get("tabSize"); value != "8" {
+		t.Fatalf("Expected tabSize=8, got=%s", value)
+	}
+}
@@ -0,0 +1,173 @@
+package editor
+
+import (
+	"NutCode/rope"
+	"sort"
+	"strings"
+	"unicode/utf8"
+)
+
+// The main cursor of a window. It only holds its offset in the buffer, which
+// the buffer keeps in step with every edit. The line, the column and where
+// the window scrolls to are all worked out from the offset.
+type Cursor struct {
+	ew     *EditorWindow
+	offset int
+}
+
+func (c *Cursor) Offset() int {
+	return c.offset
+}
+
+// Move the cursor to an offset, kept within the buffer
+func (c *Cursor) Set(offset int) {
+	c.offset = max(min(offset, c.ew.Buffer.Content.Len()), 0)
+}
+
+// Get the line of the cursor, counted from 0
+func (c *Cursor) Line() int {
+	return c.ew.Buffer.Content.LineAt(c.offset)
+}
+
+// Get the column of the cursor on screen, counted from the start of its line
+func (c *Cursor) Column() int {
+	text := c.ew.Buffer.Content
+	start := text.LineStart(text.LineAt(c.offset))
+	return textWidth(text.Slice(start, c.offset), c.ew.Buffer.Options.TabSize)
+}
+
+// Move the cursor to a line and a column, counted from 1 like in compiler
+// messages, and show the line in the middle of the window. Columns count
+// bytes, 0 goes to the first character of the line that is not a space.
+func (ew *EditorWindow) GoTo(line, col int) {
+	text := ew.Buffer.Content
+	line = max(min(line, text.LineCount()), 1) - 1
+	start := text.LineStart(line)
+	content := strings.TrimSuffix(text.Slice(start, text.LineStart(line+1)), "\n")
+	offset := len(content) - len(strings.TrimLeft(content, " \t"))
+	if col > 0 {
+		offset = min(col-1, len(content))
+		for offset < len(content) && !utf8.RuneStart(content[offset]) {
+			offset--
+		}
+	}
+	ew.Cursor.Set(start + offset)
+	ew.ClearCursors()
+	ew.ForgetColumns()
+	ew.Center()
+}
+
+// Extra cursors, on top of the main one. They are kept as offsets into the
+// content and sorted.
+
+// Add a cursor at an offset, returns false if there already is one there
+func (ew *EditorWindow) AddCursor(offset int) bool {
+	i := sort.SearchInts(ew.cursors, offset)
+	if i < len(ew.cursors) && ew.cursors[i] == offset {
+		return false
+	}
+	ew.cursors = append(ew.cursors, 0)
+	copy(ew.cursors[i+1:], ew.cursors[i:])
+	ew.cursors[i] = offset
+	return true
+}
+
+// Get the offsets of the extra cursors
+func (ew *EditorWindow) Cursors() []int {
+	return ew.cursors
+}
+
+// Replace the extra cursors. Cursors ending up at the same offset, or at the
+// main cursor's offset, are merged.
+func (ew *EditorWindow) SetCursors(offsets []int, main int) {
+	ew.cursors = nil
+	for _, offset := range offsets {
+		if offset != main {
+			ew.AddCursor(offset)
+		}
+	}
+}
+
+// Move every extra cursor along with the changes made by a transaction
+func (ew *EditorWindow) MapCursors(t rope.Transaction, main int) {
+	offsets := make([]int, len(ew.cursors))
+	for i, offset := range ew.cursors {
+		offsets[i] = t.MapOffset(offset)
+	}
+	ew.SetCursors(offsets, main)
+}
+
+func (ew *EditorWindow) HasCursors() bool {
+	return len(ew.cursors) > 0
+}
+
+func (ew *EditorWindow) ClearCursors() {
+	ew.cursors = nil
+}
+
+// Check if an extra cursor sits at an offset
+func (ew *EditorWindow) isCursor(offset int) bool {
+	i := sort.SearchInts(ew.cursors, offset)
+	return i < len(ew.cursors) && ew.cursors[i] == offset
+}
+
+// Move the main cursor and the extra ones with a motion
+func (ew *EditorWindow) Move(m Motion) {
+	content, start := ew.loadAroundCursors()
+	move := func(offset int) int {
+		return m(content, offset-start) + start
+	}
+	ew.Cursor.Set(move(ew.Cursor.offset))
+	offsets := make([]int, 0, len(ew.cursors))
+	for _, offset := range ew.cursors {
+		offsets = append(offsets, move(offset))
+	}
+	// Cursors that end up at the same place are merged
+	ew.SetCursors(offsets, ew.Cursor.offset)
+	ew.ForgetColumns()
+}
+
+// Move the main cursor and the extra ones n lines down, or up when negative.
+// Each goes to the column on screen it had before moving up and down, even
+// after going through lines too short for it.
+func (ew *EditorWindow) MoveLines(n int) {
+	content, start := ew.loadAroundCursors()
+	tabSize := ew.Buffer.Options.TabSize
+	goals := make(map[int]int)
+	move := func(offset int) int {
+		col, ok := ew.goalColumns[offset]
+		offset -= start
+		if !ok {
+			col = VisualColumn(content, offset, tabSize)
+		}
+		offset = OffsetAtColumn(content, lineBelow(content, offset, n), col, tabSize) + start
+		if _, ok := goals[offset]; !ok {
+			goals[offset] = col
+		}
+		return offset
+	}
+	ew.Cursor.Set(move(ew.Cursor.offset))
+	offsets := make([]int, 0, len(ew.cursors))
+	for _, offset := range ew.cursors {
+		offsets = append(offsets, move(offset))
+	}
+	ew.SetCursors(offsets, ew.Cursor.offset)
+	ew.goalColumns = goals
+}
+
+// Get the text of the buffer around the cursors and where it starts, loading
+// it first for large buffers
+func (ew *EditorWindow) loadAroundCursors() (string, int) {
+	first, last := ew.Cursor.offset, ew.Cursor.offset
+	if len(ew.cursors) > 0 {
+		first, last = min(first, ew.cursors[0]), max(last, ew.cursors[len(ew.cursors)-1])
+	}
+	ew.Buffer.LoadAround(first, last)
+	return ew.Buffer.Text(), ew.Buffer.TextStart()
+}
+
+// Forget the columns of the cursors, after they moved sideways or the
+// content changed
+func (ew *EditorWindow) ForgetColumns() {
+	ew.goalColumns = nil
+}
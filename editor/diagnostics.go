@@ -0,0 +1,151 @@
+package editor
+
+import (
+	"cmp"
+	"slices"
+
+	"github.com/gdamore/tcell/v2"
+)
+
+// Diagnostics are the errors and warnings a language server found in a
+// buffer. The buffer keeps them in step with its edits until the server
+// sends new ones.
+
+// How severe a diagnostic is, the same numbers as the language server
+// protocol
+const (
+	SeverityError = iota + 1
+	SeverityWarning
+	SeverityInformation
+	SeverityHint
+)
+
+// Signs shown in the gutter, by severity
+var diagnosticSigns = [...]rune{SeverityError: 'E', SeverityWarning: 'W', SeverityInformation: 'I', SeverityHint: 'H'}
+
+type Diagnostic struct {
+	// The text it is about, as [Start, End) offsets
+	Start, End int
+	Severity   int
+	Message    string
+}
+
+// Replace the diagnostics of the buffer
+func (b *Buffer) SetDiagnostics(diagnostics []Diagnostic) {
+	for _, d := range b.diagnostics {
+		b.Untrack(&d.Start)
+		b.Untrack(&d.End)
+	}
+	b.diagnostics = make([]*Diagnostic, 0, len(diagnostics))
+	for _, d := range diagnostics {
+		if d.Severity < SeverityError || d.Severity > SeverityHint {
+			d.Severity = SeverityError
+		}
+		b.diagnostics = append(b.diagnostics, &d)
+		b.Track(&d.Start)
+		b.Track(&d.End)
+	}
+}
+
+// Get the diagnostics of the buffer, in the order of the text
+func (b *Buffer) Diagnostics() []Diagnostic {
+	diagnostics := make([]Diagnostic, 0, len(b.diagnostics))
+	for _, d := range b.diagnostics {
+		diagnostics = append(diagnostics, *d)
+	}
+	slices.SortStableFunc(diagnostics, func(a, b Diagnostic) int {
+		return cmp.Or(a.Start-b.Start, a.Severity-b.Severity)
+	})
+	return diagnostics
+}
+
+// Get the diagnostics about the text at an offset, the most severe first
+func (b *Buffer) DiagnosticsAt(offset int) []Diagnostic {
+	var found []Diagnostic
+	for _, d := range b.Diagnostics() {
+		if d.Start <= offset && (offset < d.End || offset == d.Start) {
+			found = append(found, d)
+		}
+	}
+	slices.SortStableFunc(found, func(a, b Diagnostic) int {
+		return a.Severity - b.Severity
+	})
+	return found
+}
+
+// Find the start of the next diagnostic after an offset, or the one before
+// it, wrapping around the buffer
+func (b *Buffer) NextDiagnostic(offset int, forward bool) (int, bool) {
+	diagnostics := b.Diagnostics()
+	if len(diagnostics) == 0 {
+		return 0, false
+	}
+	if forward {
+		for _, d := range diagnostics {
+			if d.Start > offset {
+				return d.Start, true
+			}
+		}
+		return diagnostics[0].Start, true
+	}
+	for i := len(diagnostics) - 1; i >= 0; i-- {
+		if diagnostics[i].Start < offset {
+			return diagnostics[i].Start, true
+		}
+	}
+	return diagnostics[len(diagnostics)-1].Start, true
+}
+
+// Find the most severe diagnostic of each line, for the gutter
+func (b *Buffer) diagnosticLines() map[int]int {
+	lines := make(map[int]int, len(b.diagnostics))
+	for _, d := range b.diagnostics {
+		line := b.Content.LineAt(min(d.Start, b.Content.Len()))
+		if severity, ok := lines[line]; !ok || d.Severity < severity {
+			lines[line] = d.Severity
+		}
+	}
+	return lines
+}
+
+// Get the style of a diagnostic
+func diagnosticStyle(severity int) tcell.Style {
+	switch severity {
+	case SeverityWarning:
+		return theme.DiagnosticWarning
+	case SeverityInformation:
+		return theme.DiagnosticInfo
+	case SeverityHint:
+		return theme.DiagnosticHint
+	}
+	return theme.DiagnosticError
+}
+
+// Find the diagnostics shown in the window, to underline their text
+func (ew *EditorWindow) findDiagnostics() {
+	ew.diagnostics = ew.diagnostics[:0]
+	if ew.Buffer == nil {
+		return
+	}
+	content := ew.Buffer.Content
+	start := content.LineStart(ew.startRow)
+	end := content.LineStart(ew.startRow + ew.height + 1)
+	for _, d := range ew.Buffer.diagnostics {
+		if d.Start <= end && (d.End > start || d.Start >= start) {
+			ew.diagnostics = append(ew.diagnostics, *d)
+		}
+	}
+}
+
+// Get the style of the diagnostic underlining the text at an offset, the
+// most severe one, false if there is none
+func (ew *EditorWindow) diagnosticAt(offset int) (tcell.Style, bool) {
+	severity := 0
+	for _, d := range ew.diagnostics {
+		// A diagnostic of no text underlines the character it is at
+		if d.Start <= offset && (offset < d.End || offset == d.Start) && (severity == 0 || d.Severity < severity) {
+			severity = d.Severity
+		}
+	}
+	return diagnosticStyle(severity), severity != 0
+}
@@ -0,0 +1,71 @@
+package editor
+
+import (
+	"NutCode/rope"
+	"testing"
+
+	"github.com/gdamore/tcell/v2"
+)
+
+func TestDiagnosticsFollowEdits(t *testing.T) {
+	b := NewBufferFromString("", "x := 1\ny := z\n")
+	b.SetDiagnostics([]Diagnostic{
+		{Start: 12, End: 13, Severity: SeverityError, Message: "undefined: z"},
+		{Start: 0, End: 1, Severity: SeverityWarning, Message: "x unused"},
+	})
+	b.Apply(rope.Transaction{{Offset: 0, Text: "// x\n"}}, 0)
+	diagnostics := b.Diagnostics()
+	if len(diagnostics) != 2 || diagnostics[0].Start != 5 || diagnostics[1].Start != 17 || diagnostics[1].End != 18 {
+		t.Fatalf("Diagnostics did not follow the insert, got=%+v", diagnostics)
+	}
+	if found := b.DiagnosticsAt(17); len(found) != 1 || found[0].Message != "undefined: z" {
+		t.Fatalf("Wrong diagnostics at 17, got=%+v", found)
+	}
+	if found := b.DiagnosticsAt(18); len(found) != 0 {
+		t.Fatalf("Expected no diagnostic after the end, got=%+v", found)
+	}
+	if lines := b.diagnosticLines(); lines[1] != SeverityWarning || lines[2] != SeverityError {
+		t.Fatalf("Wrong lines with diagnostics, got=%v", lines)
+	}
+
+	for _, test := range []struct {
+		offset   int
+		forward  bool
+		expected int
+	}{{0, true, 5}, {5, true, 17}, {17, true, 5}, {17, false, 5}, {5, false, 17}} {
+		if offset, ok := b.NextDiagnostic(test.offset, test.forward); !ok || offset != test.expected {
+			t.Fatalf("Wrong diagnostic from %d (forward=%v). Expected=%d, got=%d", test.offset, test.forward, test.expected, offset)
+		}
+	}
+
+	b.SetDiagnostics(nil)
+	if _, ok := b.NextDiagnostic(0, true); ok || len(b.tracked) != 0 {
+		t.Fatalf("Diagnostics not cleared")
+	}
+}
+
+func TestDrawDiagnostics(t *testing.T) {
+	s := tcell.NewSimulationScreen("")
+	if err := s.Init(); err != nil {
+		t.Fatal(err)
+	}
+	s.SetSize(40, 5)
+	ew := New(s, 0, 0, 5, 7)
+	content := "x := 1\ny := z\n"
+	b := NewBufferFromString("", content)
+	b.SetDiagnostics([]Diagnostic{{Start: 12, End: 13, Severity: SeverityError, Message: "undefined: z"}})
+	ew.ShowBuffer(b)
+	ew.DrawFull("", false, 0)
+
+	if r, _, _, _ := s.GetContent(5, 1); r != 'E' {
+		t.Fatalf("Expected an error sign in the gutter, got=%c", r)
+	}
+	r, _, style, _ := s.GetContent(12, 1)
+	if _, _, attrs := style.Decompose(); r != 'z' || attrs&tcell.AttrUnderline == 0 {
+		t.Fatalf("Expected z to be underlined, got=%c", r)
+	}
+	_, _, style, _ = s.GetContent(11, 1)
+	if _, _, attrs := style.Decompose(); attrs&tcell.AttrUnderline != 0 {
+		t.Fatalf("Expected only the diagnostic to be underlined")
+	}
+}
@@ -1,8 +1,11 @@
 package editor
 
 import (
+	"NutCode/syntax"
 	"fmt"
 	"math"
+	"slices"
+	"strings"
 
 	"github.com/gdamore/tcell/v2"
 )
@@ -11,76 +14,81 @@ const (
 	NORMAL = iota
 	INSERT
 	COMMAND
+	VISUAL
+	VISUAL_LINE
+	VISUAL_BLOCK
 )
 
-// Type for representing the cursor position
-type Cursor struct {
-	X int
-	Y int
-}
-
 // Type for the most common data related to the window
 type EditorWindow struct {
 	screen          tcell.Screen
 	Cursor          *Cursor
-	style           tcell.Style
 	NumRows         int
+	x               int
+	y               int
 	height          int
 	width           int
 	startRow        int
 	StartCol        int
 	lineNumberWidth int
 	contentOffset   int
+	// Selected regions of the content, as [start, end) offsets
+	selections [][2]int
+	// Offsets of extra cursors
+	cursors []int
+	// Columns the cursors keep to when moving up and down, by their offset
+	goalColumns map[int]int
+	Jumps       JumpList
+	// Buffer shown in the window
+	Buffer *Buffer
+	// Completions offered at the cursor, nil when there are none
+	Menu *Menu
+	// The bracket at the cursor and the one matching it, highlighted
+	matchingBrackets []int
+	// Diagnostics of the rows shown, underlined
+	diagnostics []Diagnostic
+	// Place of the cursor on screen, worked out from its offset when drawing
+	cursorX int
+	cursorY int
+	// The window has the focus
+	active bool
 }
 
-func New(s tcell.Screen, startRow, StartCol, lineNumberWidth, contentOffset int, style tcell.Style) *EditorWindow {
-	cursor := Cursor{
-		X: 0,
-		Y: 0,
-	}
+func New(s tcell.Screen, startRow, StartCol, lineNumberWidth, contentOffset int) *EditorWindow {
 	w, h := s.Size()
-	return &EditorWindow{
+	ew := &EditorWindow{
+		active:          true,
 		screen:          s,
-		Cursor:          &cursor,
 		height:          h,
 		width:           w,
 		startRow:        startRow,
 		StartCol:        StartCol,
 		lineNumberWidth: lineNumberWidth,
 		contentOffset:   contentOffset,
-		style:           style,
 	}
+	ew.Cursor = &Cursor{ew: ew}
+	return ew
 }
 
-func (ew *EditorWindow) ComputeNumRows(content string) {
-	for _, r := range content {
-		if r == '\n' {
-			ew.NumRows++
-		}
-	}
+// Place the window on the screen, the bottom row of the area is used for the status bar
+func (ew *EditorWindow) SetRect(x, y, width, height int) {
+	ew.x = x
+	ew.y = y
+	ew.width = width
+	ew.height = height
 }
 
-func (ew *EditorWindow) MoveY(numRows int) {
-	if numRows > 0 {
-		// Check if we should move the window down
-		if ew.Cursor.Y+numRows+5 >= ew.height && ew.startRow+numRows+ew.height-1 <= ew.NumRows+1 {
-			ew.startRow = min(ew.startRow+numRows, ew.NumRows+1)
-		} else {
-			if ew.Cursor.Y+numRows == ew.height-1 {
-				ew.startRow += numRows
-			} else {
-				ew.Cursor.Y += numRows
+// Get the area of the screen used by the window
+func (ew *EditorWindow) Rect() (int, int, int, int) {
+	return ew.x, ew.y, ew.width, ew.height
+}
 
-			}
-		}
-	} else {
-		// Check if we should move the window up
-		if ew.Cursor.Y+numRows-5 <= 0 && ew.startRow+numRows >= 0 {
-			ew.startRow = max(ew.startRow+numRows, 0)
-		} else {
-			ew.Cursor.Y = max(numRows+ew.Cursor.Y, 0)
-		}
+// Draw a cell, with coordinates relative to the window. Cells outside of the window are ignored.
+func (ew *EditorWindow) setContent(x, y int, r rune, style tcell.Style) {
+	if x < 0 || y < 0 || x >= ew.width || y >= ew.height {
+		return
 	}
+	ew.screen.SetContent(ew.x+x, ew.y+y, r, nil, style)
 }
 
 func max(a, b int) int {
@@ -96,140 +104,327 @@ func min(a, b int) int {
 	return b
 }
 
-func (ew *EditorWindow) MoveX(numCols int) {
-	if numCols > 0 {
-		if ew.Cursor.X+numCols+5+ew.contentOffset+ew.lineNumberWidth >= ew.width {
-			ew.StartCol += numCols
-		} else {
-			ew.Cursor.X += numCols
-		}
-	} else {
-		if ew.Cursor.X+numCols <= ew.StartCol {
-			if ew.StartCol > -1*numCols {
-				ew.StartCol += numCols
-			} else {
-				ew.Cursor.X = max(ew.StartCol+numCols, 0)
-				ew.StartCol = 0
-			}
-		} else {
-			ew.Cursor.X = max(ew.Cursor.X+numCols, 0)
-		}
-	}
+// Scroll the window to show the cursor, and work out where it is on screen
+func (ew *EditorWindow) scrollToCursor() {
+	ew.NumRows = ew.Buffer.Content.LineCount() - 1
+	ew.scrollToRow(ew.Cursor.Line())
+	ew.scrollToColumn(ew.Cursor.Column())
 }
 
-func (ew *EditorWindow) SetX(col int) {
-
+// Scroll sideways when a column is not visible
+func (ew *EditorWindow) scrollToColumn(col int) {
 	windowSize := ew.width - ew.contentOffset
-	if col > windowSize {
+	if col >= ew.StartCol && col < ew.StartCol+windowSize {
+		// Already visible
+	} else if col >= windowSize {
 		leftSpace := int(math.Floor(float64(windowSize/3) * 2))
 		ew.StartCol = col - leftSpace
-		ew.Cursor.X = col - ew.StartCol
 	} else {
 		ew.StartCol = 0
-		ew.Cursor.X = col
 	}
+	ew.cursorX = col - ew.StartCol
 }
 
-func (ew *EditorWindow) ResetX() {
-	ew.StartCol = 0
-	ew.Cursor.X = 0
+// Rows kept visible above and below the cursor when scrolling
+const scrollMargin = 5
+
+// Scroll to a row. Rows near the window are scrolled to, keeping a few rows
+// around the cursor, others end up in the middle of the window.
+func (ew *EditorWindow) scrollToRow(row int) {
+	rows := ew.height - 1
+	margin := min(scrollMargin, (rows-1)/2)
+	top, bottom := ew.startRow+margin, ew.startRow+rows-1-margin
+	switch {
+	case row >= top && row <= bottom:
+	case row < top && top-row < rows/2:
+		ew.startRow = max(row-margin, 0)
+	case row > bottom && row-bottom < rows/2:
+		ew.startRow = min(row-rows+1+margin, max(ew.NumRows-rows+1, 0))
+	default:
+		ew.startRow = max(row-ew.height/2, 0)
+	}
+	ew.cursorY = row - ew.startRow
+}
+
+// Number of rows of content the window shows
+func (ew *EditorWindow) Rows() int {
+	return ew.height - 1
+}
+
+// Scroll the view down by rows, or up when negative, as far as the content goes
+func (ew *EditorWindow) Scroll(rows int) {
+	ew.startRow = max(min(ew.startRow+rows, ew.Buffer.Content.LineCount()-1), 0)
+}
+
+// Scroll so that the line of the cursor is in the middle of the window
+func (ew *EditorWindow) Center() {
+	ew.startRow = max(ew.Cursor.Line()-ew.Rows()/2, 0)
+}
+
+// Highlight the content between start and end (exclusive)
+func (ew *EditorWindow) SetSelection(start, end int) {
+	ew.selections = [][2]int{{start, end}}
 }
 
-// Completely redraw the screen
-func (ew *EditorWindow) DrawFull(content, fileName string, unsavedChanges bool) {
-	ew.screen.Clear()
-	ew.DrawContent(content)
+// Highlight another region, used for block selections
+func (ew *EditorWindow) AddSelection(start, end int) {
+	ew.selections = append(ew.selections, [2]int{start, end})
+}
+
+func (ew *EditorWindow) ClearSelection() {
+	ew.selections = nil
+}
+
+func (ew *EditorWindow) isSelected(offset int) bool {
+	for _, sel := range ew.selections {
+		if offset >= sel[0] && offset < sel[1] {
+			return true
+		}
+	}
+	return false
+}
+
+// Completely redraw the window
+func (ew *EditorWindow) DrawFull(fileName string, unsavedChanges bool, mode int) {
+	ew.scrollToCursor()
+	ew.findMatchingBracket()
+	ew.findDiagnostics()
+	ew.clear()
+	ew.DrawContent()
 	ew.DrawLineNumbers()
-	ew.DrawStatus(fileName, unsavedChanges)
-	ew.screen.ShowCursor(ew.Cursor.X+ew.contentOffset, ew.Cursor.Y)
+	ew.drawMenu()
+	ew.DrawStatus(fileName, unsavedChanges, mode)
+	if ew.active {
+		ew.screen.ShowCursor(ew.x+ew.cursorX+ew.contentOffset, ew.y+ew.cursorY)
+	}
+}
+
+// Find the brackets to highlight
+func (ew *EditorWindow) findMatchingBracket() {
+	ew.matchingBrackets = ew.matchingBrackets[:0]
+	if ew.Buffer == nil {
+		return
+	}
+	if match, ok := ew.Buffer.MatchBracket(ew.Cursor.Offset()); ok {
+		ew.matchingBrackets = append(ew.matchingBrackets, ew.Cursor.Offset(), match)
+	}
+}
+
+func (ew *EditorWindow) clear() {
+	for y := 0; y < ew.height; y++ {
+		for x := 0; x < ew.width; x++ {
+			ew.setContent(x, y, ' ', theme.Default)
+		}
+	}
 }
 
 // Draw line numbers
 func (ew *EditorWindow) DrawLineNumbers() {
-	_, height := ew.screen.Size()
-	style := tcell.StyleDefault.Foreground(tcell.Color140)
-	activeRow := tcell.StyleDefault.Foreground(tcell.ColorReset)
+	height := ew.height
+	style := overlay(theme.Default, theme.LineNumber)
+	activeRow := overlay(theme.Default, theme.CurrentLineNumber)
+	var marks map[int]rune
+	var signs map[int]int
+	if ew.contentOffset > ew.lineNumberWidth {
+		marks = ew.Buffer.markLines()
+		signs = ew.Buffer.diagnosticLines()
+	}
 
 	for i := 0; i < height; i++ {
-		if i < ew.Cursor.Y {
-			str := fmt.Sprint(ew.Cursor.Y - i)
+		// Marks and diagnostic signs go in the gap between the numbers and
+		// the content, a sign hides a mark
+		if severity, ok := signs[i+ew.startRow]; ok {
+			fg, _, _ := diagnosticStyle(severity).Decompose()
+			ew.setContent(ew.lineNumberWidth, i, diagnosticSigns[severity], overlay(theme.Default, tcell.StyleDefault.Foreground(fg).Bold(true)))
+		} else if name, ok := marks[i+ew.startRow]; ok {
+			ew.setContent(ew.lineNumberWidth, i, name, overlay(theme.Default, theme.Mark))
+		}
+		if i < ew.cursorY {
+			str := fmt.Sprint(ew.cursorY - i)
 			off := ew.lineNumberWidth - len(str)
 			for j, r := range str {
-				ew.screen.SetContent(j+off, i, r, nil, style)
+				ew.setContent(j+off, i, r, style)
 			}
-		} else if i > ew.Cursor.Y {
-			str := fmt.Sprint(i - ew.Cursor.Y)
+		} else if i > ew.cursorY {
+			str := fmt.Sprint(i - ew.cursorY)
 			off := ew.lineNumberWidth - len(str)
 			for j, r := range str {
-				ew.screen.SetContent(j+off, i, r, nil, style)
+				ew.setContent(j+off, i, r, style)
 			}
 		} else {
 			str := fmt.Sprint(i + ew.startRow)
 			off := ew.lineNumberWidth - len(str)
 			for j, r := range str {
-				ew.screen.SetContent(j+off, i, r, nil, activeRow)
+				ew.setContent(j+off, i, r, activeRow)
 			}
 		}
 	}
 }
 
-// Draw the content to the screen
-func (ew *EditorWindow) DrawContent(content string) {
-	row := 0
+// Draw the content to the screen, only the lines in the window are read
+// from the buffer
+func (ew *EditorWindow) DrawContent() {
+	text := ew.Buffer.Content
+	lineStart := text.LineStart(ew.startRow)
+	end := text.LineStart(ew.startRow + ew.height + 1)
+	content := text.Slice(lineStart, end)
+	row := ew.startRow
 	col := ew.contentOffset
 	epicCol := col
 	minCol := ew.contentOffset + ew.StartCol
-	activeRow := tcell.StyleDefault.Background(tcell.Color24).Foreground(tcell.ColorReset)
-	for _, r := range content {
-		if row > ew.NumRows {
+	activeRow := overlay(theme.Default, theme.CurrentLine)
+	cursor := overlay(theme.Default, theme.ExtraCursor)
+	tabSize := ew.Buffer.Options.TabSize
+	// Highlighted tokens of the row being drawn
+	tokens := ew.tokens(row)
+	start, atEnd := lineStart, end == text.Len()
+	for i, r := range content {
+		i += start
+		if row > ew.NumRows || row > ew.startRow+ew.height {
 			// Ignore rest of file
+			atEnd = false
 			break
 		}
 		if r == '\n' {
+			if ew.isCursor(i) && row >= ew.startRow && col >= minCol {
+				// Show extra cursors sitting at the end of a line
+				ew.setContent(col-ew.StartCol, row-ew.startRow, ' ', cursor)
+			}
 			row++
+			lineStart = i + 1
+			tokens = ew.tokens(row)
 			if row >= ew.startRow {
-				ew.screen.SetContent(col, row-ew.startRow, r, nil, ew.style)
+				ew.setContent(col, row-ew.startRow, r, theme.Default)
 				col = ew.contentOffset
 			}
 		} else {
 			if row >= ew.startRow && row <= ew.startRow+ew.height {
-				if row-ew.startRow == ew.Cursor.Y {
-					if col >= minCol {
-						ew.screen.SetContent(col-ew.StartCol, row-ew.startRow, r, nil, activeRow)
-					}
-					epicCol = col
-				} else {
-					if col >= minCol {
-						ew.screen.SetContent(col-ew.StartCol, row-ew.startRow, r, nil, ew.style)
+				style := syntaxStyle(syntax.KindAt(tokens, i-lineStart))
+				width := charWidth(r, col-ew.contentOffset, tabSize)
+				if row-ew.startRow == ew.cursorY {
+					style = overlay(style, theme.CurrentLine)
+					epicCol = col + width - 1
+				}
+				if ew.isSelected(i) {
+					style = overlay(style, theme.Selection)
+				}
+				if underline, ok := ew.diagnosticAt(i); ok {
+					style = overlay(style, underline)
+				}
+				if slices.Contains(ew.matchingBrackets, i) {
+					style = overlay(style, theme.MatchBracket)
+				}
+				if ew.isCursor(i) {
+					style = overlay(style, theme.ExtraCursor)
+				}
+				if col >= minCol {
+					if r == '\t' {
+						// Tabs are drawn as spaces up to the tab stop
+						for x := col; x < col+width; x++ {
+							ew.setContent(x-ew.StartCol, row-ew.startRow, ' ', style)
+						}
+					} else {
+						ew.setContent(col-ew.StartCol, row-ew.startRow, r, style)
 					}
 				}
-				col++
+				col += width
 			}
 		}
 	}
+	if atEnd && ew.isCursor(end) && col >= minCol {
+		ew.setContent(col-ew.StartCol, row-ew.startRow, ' ', cursor)
+	}
 	// Fill rest of activeRow
 	for i := epicCol + 1 - ew.StartCol; i < ew.width; i++ {
-		ew.screen.SetContent(i, ew.Cursor.Y, ' ', nil, activeRow)
+		ew.setContent(i, ew.cursorY, ' ', activeRow)
 	}
 }
 
-// Draw a statusbar showing line:col numbers, filename and if there are unsaved changes
-func (ew *EditorWindow) DrawStatus(filename string, unsavedChanges bool) {
-	style := tcell.StyleDefault.Background(tcell.Color18).Foreground(tcell.ColorReset)
-	w, h := ew.screen.Size()
+// Draw a statusbar showing the mode, line:col numbers, filename and if there are unsaved changes
+func (ew *EditorWindow) DrawStatus(filename string, unsavedChanges bool, mode int) {
+	style := overlay(theme.Default, theme.StatusBar)
+	curEnd := 0
+	if ew.active {
+		curEnd = ew.drawMode(mode, style)
+	} else {
+		style = overlay(theme.Default, theme.StatusBarInactive)
+	}
 
 	// Draw information
-	curEnd := drawCursorPositionStatus(ew.screen, ew.Cursor.X+ew.StartCol, ew.Cursor.Y+ew.startRow, 0, h, style)
-	curEnd = drawFileStatus(ew.screen, filename, unsavedChanges, curEnd, h, style)
+	curEnd = ew.drawCursorPositionStatus(ew.cursorY+ew.startRow, ew.cursorX+ew.StartCol, curEnd, style)
+	curEnd = ew.drawFileStatus(filename, unsavedChanges, curEnd, style)
 
 	// Fill the rest of the row
-	for i := curEnd; i < w; i++ {
-		ew.screen.SetContent(i, h-1, rune(' '), nil, style)
+	for i := curEnd; i < ew.width; i++ {
+		ew.setContent(i, ew.height-1, rune(' '), style)
+	}
+	if ew.Buffer != nil {
+		ew.drawFileFormat(ew.Buffer, curEnd, style)
+	}
+}
+
+// Draw the character set and line endings of the file at the right of the
+// status bar, with a warning when the file had mixed line endings. They are
+// left out when there is no room after the file name.
+func (ew *EditorWindow) drawFileFormat(b *Buffer, from int, style tcell.Style) {
+	info := " " + b.Options.FileEncoding + " " + b.Options.FileFormat + " "
+	if b.Large {
+		info = " large" + info
+	}
+	warning := ""
+	if b.MixedLineEndings {
+		warning = " mixed line endings "
+	}
+	x := ew.width - len(info) - len(warning)
+	if x < from {
+		return
+	}
+	warningStyle := overlay(style, theme.Error)
+	for i, r := range warning {
+		ew.setContent(x+i, ew.height-1, r, warningStyle)
+	}
+	for i, r := range info {
+		ew.setContent(x+len(warning)+i, ew.height-1, r, style)
+	}
+}
+
+// Draw the command line over the status bar, with the cursor at its end
+func (ew *EditorWindow) DrawCommandLine(text string) {
+	w, h := ew.screen.Size()
+	runes := []rune(text)
+	for i := 0; i < w; i++ {
+		r := ' '
+		if i < len(runes) {
+			r = runes[i]
+		}
+		ew.screen.SetContent(i, h-1, r, nil, theme.Default)
+	}
+	ew.screen.ShowCursor(len(runes), h-1)
+}
+
+// Draw a message over the bottom of the content, right above the status bar
+func (ew *EditorWindow) DrawMessage(message string, isError bool) {
+	style := theme.Default
+	if isError {
+		style = overlay(style, theme.Error)
+	}
+	w, h := ew.screen.Size()
+	lines := strings.Split(message, "\n")
+	top := max(h-1-len(lines), 0)
+	for i, line := range lines[max(len(lines)-(h-1), 0):] {
+		runes := []rune(line)
+		for j := 0; j < w; j++ {
+			r := ' '
+			if j < len(runes) {
+				r = runes[j]
+			}
+			ew.screen.SetContent(j, top+i, r, nil, style)
+		}
 	}
 }
 
 // Draw file name & if the changes the user has made are saved
-func drawFileStatus(s tcell.Screen, fileName string, unsavedChanges bool, startAt, height int, style tcell.Style) int {
+func (ew *EditorWindow) drawFileStatus(fileName string, unsavedChanges bool, startAt int, style tcell.Style) int {
 	info := ""
 	if unsavedChanges {
 		info = "*"
@@ -237,23 +432,23 @@ func drawFileStatus(s tcell.Screen, fileName string, unsavedChanges bool, startA
 	info += fileName
 
 	for i, r := range info {
-		s.SetContent(i+startAt, height-1, r, nil, style)
+		ew.setContent(i+startAt, ew.height-1, r, style)
 	}
-	s.SetContent(len(info)+startAt, height-1, rune(' '), nil, style)
+	ew.setContent(len(info)+startAt, ew.height-1, rune(' '), style)
 	return startAt + len(info)
 }
 
 // Draw the current line and col number in the status bar
-func drawCursorPositionStatus(s tcell.Screen, lineNr, colNr, startAt, height int, style tcell.Style) int {
+func (ew *EditorWindow) drawCursorPositionStatus(lineNr, colNr, startAt int, style tcell.Style) int {
 	info := fmt.Sprintf(" %d:%d ", lineNr+1, colNr)
 	for i, r := range info {
-		s.SetContent(i+startAt, height-1, r, nil, style)
+		ew.setContent(i+startAt, ew.height-1, r, style)
 	}
 	return startAt + len(info)
 }
 
 // Draw the current mode in the status bar
-func drawMode(s tcell.Screen, mode, height int, style tcell.Style) int {
+func (ew *EditorWindow) drawMode(mode int, style tcell.Style) int {
 
 	modeString := ""
 	switch mode {
@@ -263,13 +458,19 @@ func drawMode(s tcell.Screen, mode, height int, style tcell.Style) int {
 		modeString = "INSERT"
 	case COMMAND:
 		modeString = "COMMAND"
+	case VISUAL:
+		modeString = "VISUAL"
+	case VISUAL_LINE:
+		modeString = "V-LINE"
+	case VISUAL_BLOCK:
+		modeString = "V-BLOCK"
 	default:
 		modeString = "unknown"
 	}
-	s.SetContent(0, height-1, rune(' '), nil, style)
+	ew.setContent(0, ew.height-1, rune(' '), style)
 	for i, r := range modeString {
-		s.SetContent(i+1, height-1, r, nil, style)
+		ew.setContent(i+1, ew.height-1, r, style)
 	}
-	s.SetContent(len(modeString)+1, height-1, rune(' '), nil, style)
+	ew.setContent(len(modeString)+1, ew.height-1, rune(' '), style)
 	return len(modeString) + 2
 }
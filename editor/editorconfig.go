@@ -0,0 +1,261 @@
+package editor
+
+import (
+	"bufio"
+	"errors"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Get the EditorConfig properties of a file, from the .editorconfig files
+// in its directory and the ones above, up to one with root = true. Closer
+// files win over ones further up, and later sections over earlier ones.
+// Property names and values are lowercase.
+func EditorConfig(path string) (map[string]string, error) {
+	path, err := filepath.Abs(path)
+	if err != nil {
+		return nil, err
+	}
+	files := []*editorConfigFile{}
+	for dir := filepath.Dir(path); ; dir = filepath.Dir(dir) {
+		f, err := readEditorConfig(filepath.Join(dir, ".editorconfig"))
+		if err != nil && !errors.Is(err, os.ErrNotExist) {
+			return nil, err
+		}
+		if f != nil {
+			files = append(files, f)
+			if f.root {
+				break
+			}
+		}
+		if filepath.Dir(dir) == dir {
+			break
+		}
+	}
+
+	props := map[string]string{}
+	for i := len(files) - 1; i >= 0; i-- {
+		f := files[i]
+		rel, err := filepath.Rel(f.dir, path)
+		if err != nil {
+			continue
+		}
+		rel = filepath.ToSlash(rel)
+		for _, s := range f.sections {
+			if s.pattern.MatchString(rel) {
+				for _, p := range s.properties {
+					props[p[0]] = p[1]
+				}
+			}
+		}
+	}
+	for name, value := range props {
+		if value == "unset" {
+			delete(props, name)
+		}
+	}
+	return props, nil
+}
+
+type editorConfigFile struct {
+	dir      string
+	root     bool
+	sections []editorConfigSection
+}
+
+type editorConfigSection struct {
+	pattern    *regexp.Regexp
+	properties [][2]string
+}
+
+// Read an .editorconfig file. Lines that can't be understood are skipped,
+// like the specification says.
+func readEditorConfig(path string) (*editorConfigFile, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	f := &editorConfigFile{dir: filepath.Dir(path)}
+	var section *editorConfigSection
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || line[0] == '#' || line[0] == ';' {
+			continue
+		}
+		if line[0] == '[' && line[len(line)-1] == ']' {
+			pattern, err := globRegexp(line[1 : len(line)-1])
+			if err != nil {
+				section = nil
+				continue
+			}
+			f.sections = append(f.sections, editorConfigSection{pattern: pattern})
+			section = &f.sections[len(f.sections)-1]
+			continue
+		}
+		name, value, ok := strings.Cut(line, "=")
+		if !ok {
+			continue
+		}
+		name = strings.ToLower(strings.TrimSpace(name))
+		value = strings.ToLower(strings.TrimSpace(value))
+		if section == nil {
+			// The preamble before the first section only holds root
+			if name == "root" {
+				f.root = value == "true"
+			}
+			continue
+		}
+		section.properties = append(section.properties, [2]string{name, value})
+	}
+	return f, scanner.Err()
+}
+
+// Turn an EditorConfig glob into a regular expression matching paths
+// relative to the directory of the file. Globs without a slash match files
+// in any directory.
+func globRegexp(glob string) (*regexp.Regexp, error) {
+	if !strings.Contains(glob, "/") {
+		glob = "**/" + glob
+	} else {
+		glob = strings.TrimPrefix(glob, "/")
+	}
+	var out strings.Builder
+	out.WriteString("^")
+	braces := 0
+	for i := 0; i < len(glob); i++ {
+		c := glob[i]
+		switch c {
+		case '\\':
+			if i+1 < len(glob) {
+				i++
+				out.WriteString(regexp.QuoteMeta(glob[i : i+1]))
+			}
+		case '*':
+			if strings.HasPrefix(glob[i:], "**/") {
+				// Any number of directories, also none
+				out.WriteString("(?:.*/)?")
+				i += 2
+			} else if strings.HasPrefix(glob[i:], "**") {
+				out.WriteString(".*")
+				i++
+			} else {
+				out.WriteString("[^/]*")
+			}
+		case '?':
+			out.WriteString("[^/]")
+		case '[':
+			end := strings.IndexByte(glob[i:], ']')
+			if end == -1 {
+				out.WriteString(`\[`)
+				continue
+			}
+			class := glob[i+1 : i+end]
+			if strings.HasPrefix(class, "!") {
+				class = "^" + class[1:]
+			}
+			out.WriteString("[" + strings.ReplaceAll(class, `\`, `\\`) + "]")
+			i += end
+		case '{':
+			end := strings.IndexByte(glob[i:], '}')
+			if end == -1 {
+				out.WriteString(`\{`)
+				continue
+			}
+			if numbers := numberRange(glob[i+1 : i+end]); numbers != "" {
+				out.WriteString(numbers)
+				i += end
+				continue
+			}
+			if !strings.Contains(glob[i:i+end], ",") {
+				// A single choice is taken literally
+				out.WriteString(regexp.QuoteMeta(glob[i : i+end+1]))
+				i += end
+				continue
+			}
+			braces++
+			out.WriteString("(?:")
+		case '}':
+			if braces == 0 {
+				out.WriteString(`\}`)
+				continue
+			}
+			braces--
+			out.WriteString(")")
+		case ',':
+			if braces == 0 {
+				out.WriteString(",")
+				continue
+			}
+			out.WriteString("|")
+		default:
+			out.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+	out.WriteString("$")
+	return regexp.Compile(out.String())
+}
+
+// Turn {num1..num2} into a regular expression matching the numbers in the
+// range, empty if it's not a range
+func numberRange(spec string) string {
+	from, to, ok := strings.Cut(spec, "..")
+	if !ok {
+		return ""
+	}
+	start, err1 := strconv.Atoi(from)
+	end, err2 := strconv.Atoi(to)
+	if err1 != nil || err2 != nil || end < start || end-start > 1000 {
+		return ""
+	}
+	numbers := []string{}
+	for n := start; n <= end; n++ {
+		numbers = append(numbers, strconv.Itoa(n))
+	}
+	return "(?:" + strings.Join(numbers, "|") + ")"
+}
+
+// Apply EditorConfig properties to options. Values the editor does not
+// support are left out.
+func (o *Options) ApplyEditorConfig(props map[string]string) {
+	set := func(name, value string) {
+		options := *o
+		if options.Set(name, value) == nil && options.Validate() == nil {
+			*o = options
+		}
+	}
+	switch props["indent_style"] {
+	case "space":
+		set("expandTab", "true")
+	case "tab":
+		set("expandTab", "false")
+	}
+	if width, ok := props["tab_width"]; ok {
+		set("tabSize", width)
+	} else if size := props["indent_size"]; size != "tab" && size != "" {
+		// The tab width defaults to the indent size
+		set("tabSize", size)
+	}
+	if size := props["indent_size"]; size == "tab" {
+		set("indentSize", strconv.Itoa(o.TabSize))
+	} else if size != "" {
+		set("indentSize", size)
+	}
+	if format, ok := map[string]string{"lf": "unix", "crlf": "dos", "cr": "mac"}[props["end_of_line"]]; ok {
+		set("fileFormat", format)
+	}
+	if charset, ok := props["charset"]; ok {
+		set("fileEncoding", charset)
+	}
+	if trim, ok := props["trim_trailing_whitespace"]; ok {
+		set("trimTrailingWhitespace", trim)
+	}
+	if newline, ok := props["insert_final_newline"]; ok {
+		set("insertFinalNewline", newline)
+	}
+}
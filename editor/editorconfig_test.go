@@ -0,0 +1,98 @@
+package editor
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestGlobRegexp(t *testing.T) {
+	tests := []struct {
+		glob    string
+		path    string
+		matches bool
+	}{
+		{"*", "main.go", true},
+		{"*.go", "cmd/main.go", true},
+		{"*.go", "main.py", false},
+		{"*.{js,ts}", "src/app.ts", true},
+		{"lib/**.js", "lib/a/b.js", true},
+		{"/lib/*.js", "lib/a/b.js", false},
+		{"Makefile", "sub/Makefile", true},
+		{"file[0-9].txt", "file7.txt", true},
+		{"file[!0-9].txt", "file7.txt", false},
+		{"v{1..3}.md", "v2.md", true},
+		{"v{1..3}.md", "v4.md", false},
+	}
+	for _, tt := range tests {
+		re, err := globRegexp(tt.glob)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if re.MatchString(tt.path) != tt.matches {
+			t.Fatalf("Glob %q on %q. Expected match=%v", tt.glob, tt.path, tt.matches)
+		}
+	}
+}
+
+func TestEditorConfig(t *testing.T) {
+	dir := t.TempDir()
+	os.WriteFile(filepath.Join(dir, ".editorconfig"), []byte(`
+root = true
+
+[*]
+indent_style = space
+indent_size = 4
+end_of_line = lf
+
+[*.go]
+indent_style = tab
+tab_width = 8
+`), 0644)
+	os.Mkdir(filepath.Join(dir, "web"), 0755)
+	os.WriteFile(filepath.Join(dir, "web", ".editorconfig"), []byte(`
+[*.js]
+indent_size = 2
+end_of_line = CRLF
+insert_final_newline = true
+`), 0644)
+
+	props, err := EditorConfig(filepath.Join(dir, "web", "app.js"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	o := DefaultOptions()
+	o.ApplyEditorConfig(props)
+	if !o.ExpandTab || o.IndentSize != 2 || o.TabSize != 2 || o.FileFormat != "dos" || !o.InsertFinalNewline {
+		t.Fatalf("Wrong options for app.js: %+v", o)
+	}
+
+	props, _ = EditorConfig(filepath.Join(dir, "main.go"))
+	o = DefaultOptions()
+	o.ApplyEditorConfig(props)
+	if o.ExpandTab || o.TabSize != 8 || o.IndentSize != 4 {
+		t.Fatalf("Wrong options for main.go: %+v", o)
+	}
+}
+
+func TestSaveCleanUp(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "out.txt")
+	b := NewBufferFromString(path, "one  \ntwo\t\nthree")
+	b.Options.TrimTrailingWhitespace = true
+	b.Options.InsertFinalNewline = true
+	b.Options.FileFormat = "dos"
+	b.Options.FileEncoding = "utf-8-bom"
+	if err := b.Save(""); err != nil {
+		t.Fatal(err)
+	}
+	if b.Text() != "one\ntwo\nthree\n" {
+		t.Fatalf("Buffer not cleaned up, got=%q", b.Text())
+	}
+	data, _ := os.ReadFile(path)
+	if string(data) != "\uFEFFone\r\ntwo\r\nthree\r\n" {
+		t.Fatalf("Wrong file content, got=%q", data)
+	}
+	if b.Dirty {
+		t.Fatalf("Buffer dirty after saving")
+	}
+}
@@ -0,0 +1,101 @@
+package editor
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+
+	"golang.org/x/text/encoding"
+	"golang.org/x/text/encoding/charmap"
+	"golang.org/x/text/encoding/ianaindex"
+	"golang.org/x/text/encoding/unicode"
+)
+
+// Byte order marks, by the encoding they start
+var byteOrderMarks = []struct {
+	charset string
+	bom     []byte
+}{
+	{"utf-8-bom", []byte{0xEF, 0xBB, 0xBF}},
+	{"utf-16le", []byte{0xFF, 0xFE}},
+	{"utf-16be", []byte{0xFE, 0xFF}},
+}
+
+// Encodings known by names the IANA list doesn't have, or differently.
+// UTF-16 files get a byte order mark.
+var encodings = map[string]encoding.Encoding{
+	"utf-16le": unicode.UTF16(unicode.LittleEndian, unicode.UseBOM),
+	"utf-16be": unicode.UTF16(unicode.BigEndian, unicode.UseBOM),
+	"latin1":   charmap.ISO8859_1,
+}
+
+// Get the encoding of a character set name, like "latin1" or "shift_jis".
+// UTF-8 is nil, there is nothing to convert.
+func findEncoding(name string) (encoding.Encoding, error) {
+	name = strings.ToLower(name)
+	if name == "utf-8" || name == "utf-8-bom" {
+		return nil, nil
+	}
+	if e, ok := encodings[name]; ok {
+		return e, nil
+	}
+	e, err := ianaindex.IANA.Encoding(name)
+	if err != nil || e == nil {
+		return nil, fmt.Errorf("Unsupported character set: %s", name)
+	}
+	return e, nil
+}
+
+// Turn the content of a file into UTF-8 text. The encoding is found from
+// the byte order mark, else the content is UTF-8 if it is valid UTF-8, else
+// it is read with the fallback encoding.
+func DecodeText(data []byte, fallback string) (text, charset string, err error) {
+	charset = "utf-8"
+	for _, m := range byteOrderMarks {
+		if bytes.HasPrefix(data, m.bom) {
+			charset = m.charset
+			break
+		}
+	}
+	if charset == "utf-8" && !utf8.Valid(data) {
+		charset = strings.ToLower(fallback)
+	}
+	e, err := findEncoding(charset)
+	if err != nil {
+		return "", "", err
+	}
+	if e == nil {
+		return strings.TrimPrefix(string(data), "\uFEFF"), charset, nil
+	}
+	decoded, err := e.NewDecoder().Bytes(data)
+	if err != nil {
+		return "", "", fmt.Errorf("Can't read the file as %s: %w", charset, err)
+	}
+	return string(decoded), charset, nil
+}
+
+// Turn UTF-8 text into the bytes of an encoding. Characters the encoding
+// does not have are an error rather than being lost.
+func EncodeText(text, charset string) ([]byte, error) {
+	e, err := findEncoding(charset)
+	if err != nil {
+		return nil, err
+	}
+	if e == nil {
+		if charset == "utf-8-bom" {
+			text = "\uFEFF" + text
+		}
+		return []byte(text), nil
+	}
+	encoded, err := e.NewEncoder().Bytes([]byte(text))
+	if err != nil {
+		for _, r := range text {
+			if _, err := e.NewEncoder().String(string(r)); err != nil {
+				return nil, fmt.Errorf("Can't write %q as %s", r, charset)
+			}
+		}
+		return nil, fmt.Errorf("Can't write the text as %s: %w", charset, err)
+	}
+	return encoded, nil
+}
@@ -0,0 +1,86 @@
+package editor
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestDecodeText(t *testing.T) {
+	tests := []struct {
+		data     string
+		fallback string
+		text     string
+		charset  string
+	}{
+		{"héllo", "latin1", "héllo", "utf-8"},
+		{"\xEF\xBB\xBFhi", "latin1", "hi", "utf-8-bom"},
+		{"\xFF\xFEh\x00i\x00", "latin1", "hi", "utf-16le"},
+		{"\xFE\xFF\x00h\x00i", "latin1", "hi", "utf-16be"},
+		{"h\xE9llo", "latin1", "héllo", "latin1"},
+		{"\x80", "windows-1252", "€", "windows-1252"},
+	}
+	for _, tt := range tests {
+		text, charset, err := DecodeText([]byte(tt.data), tt.fallback)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if text != tt.text || charset != tt.charset {
+			t.Fatalf("Decoding %q. Expected=%q %s, got=%q %s", tt.data, tt.text, tt.charset, text, charset)
+		}
+	}
+	if _, _, err := DecodeText([]byte("\xE9"), "nope"); err == nil {
+		t.Fatalf("Expected an error for an unknown fallback")
+	}
+}
+
+func TestEncodeText(t *testing.T) {
+	tests := []struct {
+		text    string
+		charset string
+		data    string
+	}{
+		{"héllo", "utf-8", "héllo"},
+		{"hi", "utf-8-bom", "\xEF\xBB\xBFhi"},
+		{"hi", "utf-16le", "\xFF\xFEh\x00i\x00"},
+		{"héllo", "latin1", "h\xE9llo"},
+	}
+	for _, tt := range tests {
+		data, err := EncodeText(tt.text, tt.charset)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(data) != tt.data {
+			t.Fatalf("Encoding %q as %s. Expected=%q, got=%q", tt.text, tt.charset, tt.data, data)
+		}
+	}
+	if _, err := EncodeText("€", "latin1"); err == nil {
+		t.Fatalf("Expected an error for a character latin1 does not have")
+	}
+}
+
+func TestEncodingRoundTrip(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "latin1.txt")
+	os.WriteFile(path, []byte("caf\xE9\n"), 0644)
+	b, err := NewBuffer(path, DefaultOptions())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b.Text() != "café\n" || b.Options.FileEncoding != "latin1" {
+		t.Fatalf("File not decoded, got=%q as %s", b.Text(), b.Options.FileEncoding)
+	}
+	if err := b.Save(""); err != nil {
+		t.Fatal(err)
+	}
+	data, _ := os.ReadFile(path)
+	if string(data) != "caf\xE9\n" {
+		t.Fatalf("File not written back as latin1, got=%q", data)
+	}
+
+	b.Options.FileEncoding = "utf-8"
+	b.Save("")
+	data, _ = os.ReadFile(path)
+	if string(data) != "café\n" || b.Encoding != "utf-8" {
+		t.Fatalf("File not converted, got=%q", data)
+	}
+}
@@ -0,0 +1,41 @@
+package editor
+
+import "strings"
+
+// Find the line endings used most in text, and whether others are used
+// too. The format is empty when the text has no line breaks.
+func DetectFileFormat(text string) (format string, mixed bool) {
+	return countLineEndings(text).format()
+}
+
+// Number of line breaks of a text, by file format
+type lineEndings map[string]int
+
+func countLineEndings(text string) lineEndings {
+	dos := strings.Count(text, "\r\n")
+	return lineEndings{
+		"unix": strings.Count(text, "\n") - dos,
+		"dos":  dos,
+		"mac":  strings.Count(text, "\r") - dos,
+	}
+}
+
+// Find the line endings used most, and whether others are used too
+func (counts lineEndings) format() (format string, mixed bool) {
+	used := 0
+	for _, f := range []string{"unix", "dos", "mac"} {
+		if counts[f] > 0 {
+			used++
+		}
+		if counts[f] > counts[format] {
+			format = f
+		}
+	}
+	return format, used > 1
+}
+
+// Turn all line endings into \n, the editor only deals with those
+func normalizeLineEndings(text string) string {
+	text = strings.ReplaceAll(text, "\r\n", "\n")
+	return strings.ReplaceAll(text, "\r", "\n")
+}
@@ -0,0 +1,73 @@
+package editor
+
+import (
+	"NutCode/rope"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestDetectFileFormat(t *testing.T) {
+	tests := []struct {
+		text   string
+		format string
+		mixed  bool
+	}{
+		{"", "", false},
+		{"one line", "", false},
+		{"a\nb\n", "unix", false},
+		{"a\r\nb\r\n", "dos", false},
+		{"a\rb\r", "mac", false},
+		{"a\r\nb\r\nc\n", "dos", true},
+		{"a\nb\r\n", "unix", true},
+	}
+	for _, tt := range tests {
+		format, mixed := DetectFileFormat(tt.text)
+		if format != tt.format || mixed != tt.mixed {
+			t.Fatalf("Detecting %q. Expected=%s mixed=%v, got=%s mixed=%v", tt.text, tt.format, tt.mixed, format, mixed)
+		}
+	}
+}
+
+func TestLineEndingsRoundTrip(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "dos.txt")
+	os.WriteFile(path, []byte("one\r\ntwo\r\n"), 0644)
+	b, err := NewBuffer(path, DefaultOptions())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b.Text() != "one\ntwo\n" {
+		t.Fatalf("Line endings not normalized, got=%q", b.Text())
+	}
+	if b.Options.FileFormat != "dos" || b.LineEndings != "dos" {
+		t.Fatalf("Expected dos format, got=%s", b.Options.FileFormat)
+	}
+	b.Apply(rope.Transaction{{Offset: 3, Text: "!"}}, 3)
+	if err := b.Save(""); err != nil {
+		t.Fatal(err)
+	}
+	data, _ := os.ReadFile(path)
+	if string(data) != "one!\r\ntwo\r\n" {
+		t.Fatalf("Wrong file content, got=%q", data)
+	}
+
+	b.Options.FileFormat = "unix"
+	b.Save("")
+	data, _ = os.ReadFile(path)
+	if string(data) != "one!\ntwo\n" || b.LineEndings != "unix" {
+		t.Fatalf("File not converted, got=%q", data)
+	}
+}
+
+func TestMixedLineEndings(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "mixed.txt")
+	b := NewBufferFromString(path, "a\r\nb\nc\r\n")
+	if !b.MixedLineEndings || b.Options.FileFormat != "dos" {
+		t.Fatalf("Expected mixed dos line endings")
+	}
+	b.Save("")
+	data, _ := os.ReadFile(path)
+	if string(data) != "a\r\nb\r\nc\r\n" || b.MixedLineEndings {
+		t.Fatalf("Line endings not made the same, got=%q", data)
+	}
+}
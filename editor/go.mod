@@ -3,6 +3,7 @@ module NutCode/editor
 go 1.23
 
 require (
+	github.com/BurntSushi/toml v1.5.0 // indirect
 	github.com/gdamore/encoding v1.0.0 // indirect
 	github.com/gdamore/tcell/v2 v2.7.0 // indirect
 	github.com/lucasb-eyer/go-colorful v1.2.0 // indirect
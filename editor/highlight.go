@@ -0,0 +1,25 @@
+package editor
+
+import (
+	"NutCode/syntax"
+
+	"github.com/gdamore/tcell/v2"
+)
+
+// Get the style of a token kind, kinds the theme leaves out are drawn plain
+func syntaxStyle(kind syntax.Kind) tcell.Style {
+	style, ok := theme.Syntax[kind]
+	if !ok {
+		return theme.Default
+	}
+	return overlay(theme.Default, style)
+}
+
+// Get the highlighted tokens of a line of the buffer shown, nil for lines
+// outside the window
+func (ew *EditorWindow) tokens(row int) []syntax.Token {
+	if ew.Buffer == nil || row < ew.startRow || row > ew.startRow+ew.height {
+		return nil
+	}
+	return ew.Buffer.Tokens(row)
+}
@@ -0,0 +1,83 @@
+package editor
+
+import "NutCode/rope"
+
+// A recorded change, with what is needed to revert and redo it
+type change struct {
+	forward rope.Transaction
+	inverse rope.Transaction
+	// Cursor offset before the change
+	cursor int
+	// Numbers the changes in the order they were made, from 1
+	id int
+}
+
+// Undo history of a buffer. Changes are undone in groups, a group being a
+// single change or everything recorded between begin and end.
+type History struct {
+	undo  [][]change
+	redo  [][]change
+	depth int
+	// The open group already has an entry in undo
+	started bool
+	// Number of changes recorded
+	count int
+}
+
+func (h *History) record(c change) {
+	h.redo = nil
+	h.count++
+	c.id = h.count
+	if h.depth > 0 && h.started {
+		last := len(h.undo) - 1
+		h.undo[last] = append(h.undo[last], c)
+		return
+	}
+	h.undo = append(h.undo, []change{c})
+	h.started = h.depth > 0
+}
+
+func (h *History) begin() {
+	if h.depth == 0 {
+		h.started = false
+	}
+	h.depth++
+}
+
+func (h *History) end() {
+	if h.depth > 0 {
+		h.depth--
+	}
+}
+
+func (h *History) popUndo() ([]change, bool) {
+	if len(h.undo) == 0 {
+		return nil, false
+	}
+	group := h.undo[len(h.undo)-1]
+	h.undo = h.undo[:len(h.undo)-1]
+	h.redo = append(h.redo, group)
+	h.started = false
+	return group, true
+}
+
+func (h *History) popRedo() ([]change, bool) {
+	if len(h.redo) == 0 {
+		return nil, false
+	}
+	group := h.redo[len(h.redo)-1]
+	h.redo = h.redo[:len(h.redo)-1]
+	h.undo = append(h.undo, group)
+	h.started = false
+	return group, true
+}
+
+// Where the history is, the id of the last change not undone, 0 before
+// any. Undo and redo come back to the same positions.
+func (h *History) position() int {
+	if len(h.undo) == 0 {
+		return 0
+	}
+	group := h.undo[len(h.undo)-1]
+	return group[len(group)-1].id
+}
@@ -0,0 +1,134 @@
+package editor
+
+import (
+	"NutCode/rope"
+	"strings"
+	"unicode/utf8"
+)
+
+// Characters that open a block, the line after them is indented one level
+// deeper. Some languages open blocks with a colon too.
+const (
+	openers = "{(["
+	closers = "})]"
+)
+
+// Text of one indent level: a tab, or IndentSize spaces with expandTab
+func (o Options) IndentUnit() string {
+	if o.ExpandTab {
+		return strings.Repeat(" ", o.IndentSize)
+	}
+	return "\t"
+}
+
+// Get the spaces and tabs at the start of the line containing offset
+func LineIndent(content string, offset int) string {
+	start, _ := LineBounds(content, offset)
+	return content[start:FirstNonBlank(content, offset)]
+}
+
+// Get the text Tab inserts at an offset: a tab, or with expandTab the
+// spaces up to the next indent stop, so that indents line up like tabs do
+func TabText(content string, offset int, o Options) string {
+	if !o.ExpandTab {
+		return "\t"
+	}
+	col := VisualColumn(content, offset, o.TabSize)
+	return strings.Repeat(" ", o.IndentSize-col%o.IndentSize)
+}
+
+// Find where Backspace deletes from at an offset. In the spaces of an
+// indent it goes back to the previous indent stop, elsewhere it deletes a
+// character.
+func BackspaceStart(content string, offset int, o Options) int {
+	if offset == 0 {
+		return 0
+	}
+	if content[offset-1] != ' ' || FirstNonBlank(content, offset) < offset {
+		_, size := utf8.DecodeLastRuneInString(content[:offset])
+		return offset - size
+	}
+	col := VisualColumn(content, offset, o.TabSize)
+	stop := (col - 1) / o.IndentSize * o.IndentSize
+	start := offset
+	for start > 0 && content[start-1] == ' ' && col > stop {
+		start--
+		col--
+	}
+	return start
+}
+
+// Count the bytes of leading whitespace making up its first indent level:
+// a tab, or up to indentSize spaces
+func indentLevelLength(indent string, indentSize int) int {
+	if strings.HasPrefix(indent, "\t") {
+		return 1
+	}
+	n := 0
+	for n < len(indent) && n < indentSize && indent[n] == ' ' {
+		n++
+	}
+	return n
+}
+
+// Make the edits starting a new line at an offset. The new line gets the
+// indent of the current one, one level deeper after an opener. Between an
+// opener and its closer, the closer goes on a line of its own as well.
+func Newline(content string, offset int, o Options, colonOpens bool) rope.Transaction {
+	if !o.AutoIndent {
+		return rope.Transaction{{Offset: offset, Text: "\n"}}
+	}
+	start, _ := LineBounds(content, offset)
+	// Inside the indent, the text after the cursor keeps its column
+	indent := LineIndent(content, offset)
+	indent = indent[:min(len(indent), offset-start)]
+	before := strings.TrimRight(content[start:offset], " \t")
+	if before == "" {
+		return rope.Transaction{{Offset: offset, Text: "\n" + indent}}
+	}
+	last := before[len(before)-1]
+	opens := strings.IndexByte(openers, last) >= 0 || (colonOpens && last == ':')
+	if !opens {
+		return rope.Transaction{{Offset: offset, Text: "\n" + indent}}
+	}
+	t := rope.Transaction{{Offset: offset, Text: "\n" + indent + o.IndentUnit()}}
+	// The cursor stays before the closer, which is moved down by replacing it
+	if i := strings.IndexByte(openers, last); i >= 0 && offset < len(content) && content[offset] == closers[i] {
+		t = append(t, rope.Edit{Offset: offset, Length: 1, Text: "\n" + indent + closers[i:i+1]})
+	}
+	return t
+}
+
+// Make the edit outdenting the line when a closer is typed at an offset,
+// false when the closer is not the first character of the line or the line
+// is not indented
+func OutdentCloser(content string, offset int, typed string, o Options) (rope.Edit, bool) {
+	start, _ := LineBounds(content, offset)
+	if !o.AutoIndent || len(typed) != 1 || !strings.Contains(closers, typed) || FirstNonBlank(content, offset) < offset {
+		return rope.Edit{}, false
+	}
+	n := indentLevelLength(content[start:offset], o.IndentSize)
+	return rope.Edit{Offset: start, Length: n}, n > 0
+}
+
+// Make the edits indenting the lines from start to end one level, or
+// outdenting them. A line is left out when end is at its start.
+// Empty lines are not indented.
+func IndentLines(content string, start, end int, o Options, outdent bool) rope.Transaction {
+	var t rope.Transaction
+	for line := start; ; {
+		lineStart, lineEnd := LineBounds(content, line)
+		text := strings.TrimSuffix(content[lineStart:lineEnd], "\n")
+		if outdent {
+			if n := indentLevelLength(text, o.IndentSize); n > 0 {
+				t = append(t, rope.Edit{Offset: lineStart, Length: n})
+			}
+		} else if text != "" {
+			t = append(t, rope.Edit{Offset: lineStart, Text: o.IndentUnit()})
+		}
+		if lineEnd >= len(content) || lineEnd >= end {
+			return t
+		}
+		line = lineEnd
+	}
+}
@@ -0,0 +1,153 @@
+package editor
+
+import (
+	"NutCode/rope"
+	"strings"
+	"testing"
+
+	"github.com/gdamore/tcell/v2"
+)
+
+func TestNewline(t *testing.T) {
+	o := DefaultOptions()
+	tests := []struct {
+		content    string
+		offset     int
+		colonOpens bool
+		expected   string
+	}{
+		{"\tfoo", 4, false, "\tfoo\n\t"},
+		{"    if x {", 10, false, "    if x {\n        "},
+		{"f( ", 3, false, "f( \n    "},
+		{"if x {}", 6, false, "if x {\n    \n}"},
+		{"  [x]", 3, false, "  [\n      x]"},
+		{"if x:", 5, true, "if x:\n    "},
+		{"if x:", 5, false, "if x:\n"},
+		{"    foo", 2, false, "  \n    foo"},
+	}
+	for _, tt := range tests {
+		if got := Newline(tt.content, tt.offset, o, tt.colonOpens).ApplyToString(tt.content); got != tt.expected {
+			t.Fatalf("Newline in %q at %d. Expected=%q, got=%q", tt.content, tt.offset, tt.expected, got)
+		}
+	}
+
+	// The cursor stays on the line between the brackets
+	if got := Newline("{}", 1, o, false).MapOffset(1); got != 6 {
+		t.Fatalf("Expected the cursor after the new indent, got=%d", got)
+	}
+
+	o.AutoIndent = false
+	if got := Newline("\t{", 2, o, false).ApplyToString("\t{"); got != "\t{\n" {
+		t.Fatalf("Expected no indent without autoIndent, got=%q", got)
+	}
+}
+
+func TestOutdentCloser(t *testing.T) {
+	o := DefaultOptions()
+	tests := []struct {
+		content  string
+		offset   int
+		typed    string
+		expected string
+	}{
+		{"\t\t", 2, "}", "\t}"},
+		{"      ", 6, ")", "  )"},
+		{"  ", 2, "]", "]"},
+		{"  x", 3, "}", "  x}"},
+		{"\t", 1, "a", "\ta"},
+		{"", 0, "}", "}"},
+	}
+	for _, tt := range tests {
+		e, ok := OutdentCloser(tt.content, tt.offset, tt.typed, o)
+		var got string
+		if ok {
+			got = rope.Transaction{e, {Offset: tt.offset, Text: tt.typed}}.ApplyToString(tt.content)
+		} else {
+			got = tt.content[:tt.offset] + tt.typed + tt.content[tt.offset:]
+		}
+		if got != tt.expected {
+			t.Fatalf("Typing %s in %q. Expected=%q, got=%q", tt.typed, tt.content, tt.expected, got)
+		}
+	}
+}
+
+func TestIndentLines(t *testing.T) {
+	o := DefaultOptions()
+	content := "a\n\n  b\n\tc\n"
+	tests := []struct {
+		start, end int
+		outdent    bool
+		tabs       bool
+		expected   string
+	}{
+		{0, 0, false, false, "    a\n\n  b\n\tc\n"},
+		{0, 6, false, false, "    a\n\n      b\n\tc\n"},
+		{0, 3, false, false, "    a\n\n  b\n\tc\n"},
+		{3, 9, false, true, "a\n\n\t  b\n\t\tc\n"},
+		{0, 9, true, false, "a\n\nb\nc\n"},
+	}
+	for _, tt := range tests {
+		o.ExpandTab = !tt.tabs
+		if got := IndentLines(content, tt.start, tt.end, o, tt.outdent).ApplyToString(content); got != tt.expected {
+			t.Fatalf("Indenting %d-%d, outdent=%v. Expected=%q, got=%q", tt.start, tt.end, tt.outdent, tt.expected, got)
+		}
+	}
+}
+
+func TestTabText(t *testing.T) {
+	o := DefaultOptions()
+	content := "ab\n\tx"
+	for _, tt := range []struct{ offset, spaces int }{{0, 4}, {2, 2}, {3, 4}, {4, 4}, {5, 3}} {
+		if got := TabText(content, tt.offset, o); got != strings.Repeat(" ", tt.spaces) {
+			t.Fatalf("Tab at %d. Expected %d spaces, got=%q", tt.offset, tt.spaces, got)
+		}
+	}
+	o.ExpandTab = false
+	if got := TabText(content, 2, o); got != "\t" {
+		t.Fatalf("Expected a tab without expandTab, got=%q", got)
+	}
+}
+
+func TestBackspaceStart(t *testing.T) {
+	o := DefaultOptions()
+	content := "      x  \n\t  é\n"
+	tests := []struct{ offset, expected int }{
+		{0, 0},
+		{6, 4},
+		{4, 0},
+		{3, 0},
+		{9, 8},
+		{10, 9},
+		{13, 11},
+		{12, 11},
+		{11, 10},
+		{15, 13},
+	}
+	for _, tt := range tests {
+		if got := BackspaceStart(content, tt.offset, o); got != tt.expected {
+			t.Fatalf("Backspace at %d. Expected=%d, got=%d", tt.offset, tt.expected, got)
+		}
+	}
+}
+
+func TestDrawTabs(t *testing.T) {
+	s := tcell.NewSimulationScreen("")
+	if err := s.Init(); err != nil {
+		t.Fatal(err)
+	}
+	s.SetSize(40, 5)
+	ew := New(s, 0, 0, 5, 7)
+	content := "a\tb\n\t\tc\nabcd\te"
+	ew.ShowBuffer(NewBufferFromString("", content))
+	ew.DrawFull("", false, 0)
+
+	// Tabs reach the next tab stop, whatever comes before them
+	for _, cell := range []struct {
+		x, y int
+		r    rune
+	}{{7, 0, 'a'}, {11, 0, 'b'}, {15, 1, 'c'}, {15, 2, 'e'}} {
+		if r, _, _, _ := s.GetContent(cell.x, cell.y); r != cell.r {
+			t.Fatalf("Expected %c at %d,%d, got=%c", cell.r, cell.x, cell.y, r)
+		}
+	}
+}
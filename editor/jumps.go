@@ -0,0 +1,83 @@
+package editor
+
+import "slices"
+
+// Most jumps kept in a jump list, the oldest ones are dropped
+const maxJumps = 100
+
+// Places the cursor jumped away from, to go back to with Ctrl+O and forth
+// again with Ctrl+I like in vim. Each window has its own.
+type JumpList struct {
+	jumps []*Jump
+	// Where Back and Forward are in the list, len(jumps) when not going
+	// through it
+	current int
+}
+
+// A place in a buffer, which stays on the same text when the buffer is edited
+type Jump struct {
+	Buffer *Buffer
+	offset int
+}
+
+func (j *Jump) Offset() int {
+	return j.offset
+}
+
+// Remember a place jumped away from. A jump on the same line replaces the
+// one already there, so going back doesn't stop twice on a line.
+func (jl *JumpList) Push(b *Buffer, offset int) {
+	line := b.Content.LineAt(offset)
+	jl.jumps = slices.DeleteFunc(jl.jumps, func(j *Jump) bool {
+		if j.Buffer == b && b.Content.LineAt(j.offset) == line {
+			b.Untrack(&j.offset)
+			return true
+		}
+		return false
+	})
+	if len(jl.jumps) == maxJumps {
+		jl.jumps[0].Buffer.Untrack(&jl.jumps[0].offset)
+		jl.jumps = jl.jumps[1:]
+	}
+	j := &Jump{Buffer: b, offset: offset}
+	b.Track(&j.offset)
+	jl.jumps = append(jl.jumps, j)
+	jl.current = len(jl.jumps)
+}
+
+// Go back to the previous jump. The place left is remembered first when
+// not going through the list yet, so that Forward comes back to it.
+func (jl *JumpList) Back(b *Buffer, offset int) (*Jump, bool) {
+	if jl.current == len(jl.jumps) {
+		jl.Push(b, offset)
+		jl.current--
+	}
+	if jl.current == 0 {
+		return nil, false
+	}
+	jl.current--
+	return jl.jumps[jl.current], true
+}
+
+// Go forth to the next jump, after going back
+func (jl *JumpList) Forward() (*Jump, bool) {
+	if jl.current+1 >= len(jl.jumps) {
+		return nil, false
+	}
+	jl.current++
+	return jl.jumps[jl.current], true
+}
+
+// Drop the jumps to buffers that are not in a list anymore, after they were
+// deleted
+func (jl *JumpList) Keep(buffers []*Buffer) {
+	for i, j := range jl.jumps {
+		if !slices.Contains(buffers, j.Buffer) && i < jl.current {
+			jl.current--
+		}
+	}
+	jl.jumps = slices.DeleteFunc(jl.jumps, func(j *Jump) bool {
+		return !slices.Contains(buffers, j.Buffer)
+	})
+	jl.current = min(jl.current, len(jl.jumps))
+}
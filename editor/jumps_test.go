@@ -0,0 +1,83 @@
+package editor
+
+import (
+	"NutCode/rope"
+	"testing"
+
+	"github.com/gdamore/tcell/v2"
+)
+
+func TestJumpList(t *testing.T) {
+	b := NewBufferFromString("", "one\ntwo\nthree\nfour\n")
+	var jl JumpList
+	jl.Push(b, 0)
+	jl.Push(b, 4)
+	// Same line as the first jump, which is moved to the end
+	jl.Push(b, 1)
+
+	for _, offset := range []int{1, 4} {
+		if j, ok := jl.Back(b, 14); !ok || j.Offset() != offset {
+			t.Fatalf("Back. Expected=%d, got=%v", offset, j)
+		}
+	}
+	if _, ok := jl.Back(b, 4); ok {
+		t.Fatalf("Expected the start of the jump list")
+	}
+	for _, offset := range []int{1, 14} {
+		if j, ok := jl.Forward(); !ok || j.Offset() != offset {
+			t.Fatalf("Forward. Expected=%d, got=%v", offset, j)
+		}
+	}
+	if _, ok := jl.Forward(); ok {
+		t.Fatalf("Expected the end of the jump list")
+	}
+
+	// Jumps stay on their text when the buffer changes
+	b.Apply(rope.Transaction{{Offset: 0, Text: "zero\n"}}, 0)
+	if j, _ := jl.Back(b, 0); j.Offset() != 6 {
+		t.Fatalf("Jump did not follow the edit, got=%d", j.Offset())
+	}
+
+	other := NewBufferFromString("", "other")
+	jl.Push(other, 2)
+	jl.Keep([]*Buffer{b})
+	if j, _ := jl.Back(b, 0); j.Buffer != b {
+		t.Fatalf("Expected the jump to the deleted buffer to be gone")
+	}
+}
+
+func TestGoTo(t *testing.T) {
+	s := tcell.NewSimulationScreen("")
+	if err := s.Init(); err != nil {
+		t.Fatal(err)
+	}
+	s.SetSize(80, 11)
+	ew := New(s, 0, 0, 5, 7)
+	content := ""
+	for i := 0; i < 50; i++ {
+		content += "\tline\n"
+	}
+	content += "é€x"
+	ew.ShowBuffer(NewBufferFromString("", content))
+
+	tests := []struct{ line, col, offset int }{
+		{1, 0, 1},
+		{1, 1, 0},
+		{3, 4, 15},
+		{3, 100, 17},
+		{100, 0, 300},
+		{51, 3, 302},
+		{51, 4, 302},
+		{0, 0, 1},
+	}
+	for _, tt := range tests {
+		ew.GoTo(tt.line, tt.col)
+		if got := ew.Cursor.Offset(); got != tt.offset {
+			t.Fatalf("Going to %d:%d. Expected=%d, got=%d", tt.line, tt.col, tt.offset, got)
+		}
+	}
+	ew.GoTo(30, 0)
+	if ew.startRow != 24 {
+		t.Fatalf("Expected line 30 in the middle of the window, got start row=%d", ew.startRow)
+	}
+}
@@ -0,0 +1,303 @@
+package editor
+
+import (
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/gdamore/tcell/v2"
+)
+
+// Modes that have their own key bindings. Bindings of "all" work in every
+// mode but command.
+var KeymapModes = []string{"normal", "insert", "visual", "command", "all"}
+
+// Something the user can do, which keys are bound to by name
+type Action struct {
+	Name        string
+	Description string
+	Run         func()
+}
+
+// The actions, by name
+type Actions struct {
+	actions map[string]*Action
+}
+
+func NewActions() *Actions {
+	return &Actions{actions: map[string]*Action{}}
+}
+
+func (as *Actions) Register(name, description string, run func()) {
+	as.actions[name] = &Action{Name: name, Description: description, Run: run}
+}
+
+// Get an action by name, nil if there is none
+func (as *Actions) Get(name string) *Action {
+	return as.actions[name]
+}
+
+// Names of all actions, sorted
+func (as *Actions) Names() []string {
+	names := make([]string, 0, len(as.actions))
+	for name := range as.actions {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Keys bound to an action in a mode
+type Binding struct {
+	Mode   string
+	Keys   string
+	Action string
+}
+
+// Key bindings for each mode. Keys are written like in vim, "dd", "<C-s>"
+// or "<C-w>h", and kept in the form KeyName gives.
+type Keymap struct {
+	// Actions by keys for each mode, keys unbound in a mode map to ""
+	modes map[string]map[string]string
+}
+
+func NewKeymap() *Keymap {
+	k := &Keymap{modes: map[string]map[string]string{}}
+	for _, mode := range KeymapModes {
+		k.modes[mode] = map[string]string{}
+	}
+	return k
+}
+
+// Bind keys to an action in a mode. Binding to "" unbinds the keys, also
+// hiding a binding in "all".
+func (k *Keymap) Bind(mode, keys, action string) error {
+	bindings, ok := k.modes[mode]
+	if !ok {
+		return fmt.Errorf("Unknown mode: %s", mode)
+	}
+	keys, err := ParseKeys(keys)
+	if err != nil {
+		return err
+	}
+	bindings[keys] = action
+	return nil
+}
+
+// Get the action bound to keys in the first of the modes that binds them.
+// prefix is true when the keys are the start of a longer binding, so more
+// keys have to be typed. A binding of the keys themselves wins over a
+// longer one.
+func (k *Keymap) Lookup(modes []string, keys string) (action string, prefix bool) {
+	for _, mode := range modes {
+		if action, ok := k.modes[mode][keys]; ok {
+			if action != "" {
+				return action, false
+			}
+			break
+		}
+	}
+	for _, mode := range modes {
+		for bound, action := range k.modes[mode] {
+			if action != "" && len(bound) > len(keys) && strings.HasPrefix(bound, keys) {
+				return "", true
+			}
+		}
+	}
+	return "", false
+}
+
+// Get the bindings of a mode, or of every mode when mode is empty
+func (k *Keymap) Bindings(mode string) []Binding {
+	bindings := []Binding{}
+	for _, m := range KeymapModes {
+		if mode != "" && m != mode {
+			continue
+		}
+		for keys, action := range k.modes[m] {
+			if action != "" {
+				bindings = append(bindings, Binding{m, keys, action})
+			}
+		}
+	}
+	sort.SliceStable(bindings, func(i, j int) bool {
+		a, b := bindings[i], bindings[j]
+		if a.Mode != b.Mode {
+			return slices.Index(KeymapModes, a.Mode) < slices.Index(KeymapModes, b.Mode)
+		}
+		return a.Keys < b.Keys
+	})
+	return bindings
+}
+
+// Names of the special keys, as written between < and >
+var keyNames = map[tcell.Key]string{
+	tcell.KeyEscape:     "Esc",
+	tcell.KeyEnter:      "CR",
+	tcell.KeyTab:        "Tab",
+	tcell.KeyBacktab:    "S-Tab",
+	tcell.KeyBackspace2: "BS",
+	tcell.KeyUp:         "Up",
+	tcell.KeyDown:       "Down",
+	tcell.KeyLeft:       "Left",
+	tcell.KeyRight:      "Right",
+	tcell.KeyHome:       "Home",
+	tcell.KeyEnd:        "End",
+	tcell.KeyPgUp:       "PgUp",
+	tcell.KeyPgDn:       "PgDn",
+	tcell.KeyInsert:     "Insert",
+	tcell.KeyDelete:     "Del",
+	tcell.KeyF1:         "F1",
+	tcell.KeyF2:         "F2",
+	tcell.KeyF3:         "F3",
+	tcell.KeyF4:         "F4",
+	tcell.KeyF5:         "F5",
+	tcell.KeyF6:         "F6",
+	tcell.KeyF7:         "F7",
+	tcell.KeyF8:         "F8",
+	tcell.KeyF9:         "F9",
+	tcell.KeyF10:        "F10",
+	tcell.KeyF11:        "F11",
+	tcell.KeyF12:        "F12",
+}
+
+// Other ways to write the names of special keys
+var keyAliases = map[string]string{
+	"enter":     "CR",
+	"return":    "CR",
+	"backspace": "BS",
+	"pageup":    "PgUp",
+	"pagedown":  "PgDn",
+	"delete":    "Del",
+	"escape":    "Esc",
+}
+
+// Get the name of a pressed key, like "x", "<Space>", "<C-w>" or "<C-PgDn>"
+func KeyName(ev *tcell.EventKey) string {
+	mods := ev.Modifiers()
+	switch key := ev.Key(); {
+	case key == tcell.KeyRune:
+		name := string(ev.Rune())
+		switch ev.Rune() {
+		case ' ':
+			name = "Space"
+		case '<':
+			name = "lt"
+		}
+		return formatKey(mods&^tcell.ModShift, name)
+	case key >= tcell.KeyCtrlA && key <= tcell.KeyCtrlZ && key != tcell.KeyTab && key != tcell.KeyEnter:
+		return formatKey(mods|tcell.ModCtrl, string(rune('a'+key-tcell.KeyCtrlA)))
+	case key == tcell.KeyCtrlSpace:
+		return formatKey(mods|tcell.ModCtrl, "Space")
+	case key == tcell.KeyBacktab:
+		return "<S-Tab>"
+	default:
+		if name, ok := keyNames[key]; ok {
+			return formatKey(mods, name)
+		}
+		return fmt.Sprintf("<Key%d>", key)
+	}
+}
+
+func formatKey(mods tcell.ModMask, name string) string {
+	prefix := ""
+	if mods&tcell.ModCtrl != 0 {
+		prefix += "C-"
+	}
+	if mods&tcell.ModAlt != 0 {
+		prefix += "A-"
+	}
+	if mods&tcell.ModShift != 0 {
+		prefix += "S-"
+	}
+	if prefix == "" && utf8.RuneCountInString(name) == 1 {
+		return name
+	}
+	return "<" + prefix + name + ">"
+}
+
+// Turn keys written by the user, like "<c-W>H" or "<C-PageDown>", into the
+// form KeyName gives
+func ParseKeys(keys string) (string, error) {
+	if keys == "" {
+		return "", fmt.Errorf("No keys given")
+	}
+	var out strings.Builder
+	for keys != "" {
+		end := strings.IndexByte(keys, '>')
+		if keys[0] != '<' || end == -1 || strings.ContainsAny(keys[1:end], " <") {
+			r, size := utf8.DecodeRuneInString(keys)
+			name := string(r)
+			switch r {
+			case ' ':
+				name = "Space"
+			case '<':
+				name = "lt"
+			}
+			out.WriteString(formatKey(0, name))
+			keys = keys[size:]
+			continue
+		}
+		key, err := parseKey(keys[1:end])
+		if err != nil {
+			return "", err
+		}
+		out.WriteString(key)
+		keys = keys[end+1:]
+	}
+	return out.String(), nil
+}
+
+// Parse a key written between < and >
+func parseKey(spec string) (string, error) {
+	var mods tcell.ModMask
+	name := spec
+	// The key itself can be a dash, as in <C-->
+	for len(name) > 2 && name[1] == '-' {
+		switch name[0] {
+		case 'c', 'C':
+			mods |= tcell.ModCtrl
+		case 'a', 'A', 'm', 'M':
+			mods |= tcell.ModAlt
+		case 's', 'S':
+			mods |= tcell.ModShift
+		default:
+			return "", fmt.Errorf("Unknown modifier in <%s>", spec)
+		}
+		name = name[2:]
+	}
+	lower := strings.ToLower(name)
+	if alias, ok := keyAliases[lower]; ok {
+		name = alias
+	} else if lower == "space" {
+		name = "Space"
+	} else if lower == "lt" {
+		name = "lt"
+	} else if utf8.RuneCountInString(name) == 1 {
+		if mods&tcell.ModCtrl != 0 {
+			// Terminals don't tell Ctrl+W from Ctrl+Shift+W
+			name = lower
+		}
+		if mods&tcell.ModShift != 0 && mods&tcell.ModCtrl == 0 {
+			name = strings.ToUpper(name)
+			mods &^= tcell.ModShift
+		}
+	} else {
+		found := false
+		for _, known := range keyNames {
+			if strings.EqualFold(known, name) {
+				name, found = known, true
+				break
+			}
+		}
+		if !found {
+			return "", fmt.Errorf("Unknown key: <%s>", spec)
+		}
+	}
+	if name == "S-Tab" || name == "Tab" && mods == tcell.ModShift {
+		return "<S-Tab>", nil
+	}
+	return formatKey(mods, name), nil
+}
@@ -0,0 +1,90 @@
+package editor
+
+import (
+	"testing"
+
+	"github.com/gdamore/tcell/v2"
+)
+
+func TestParseKeys(t *testing.T) {
+	tests := []struct {
+		keys     string
+		expected string
+	}{
+		{"dd", "dd"},
+		{"<c-W>H", "<C-w>H"},
+		{"<C-PageDown>", "<C-PgDn>"},
+		{"<esc><Enter>", "<Esc><CR>"},
+		{"< >", "<lt><Space>>"},
+		{"<S-a><s-tab>", "A<S-Tab>"},
+		{"<C-->", "<C-->"},
+	}
+	for _, tt := range tests {
+		keys, err := ParseKeys(tt.keys)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if keys != tt.expected {
+			t.Fatalf("Wrong keys for %q. Expected=%q, got=%q", tt.keys, tt.expected, keys)
+		}
+	}
+	for _, keys := range []string{"", "<Nope>", "<X-a>"} {
+		if _, err := ParseKeys(keys); err == nil {
+			t.Fatalf("Invalid keys %q gave no error", keys)
+		}
+	}
+}
+
+func TestKeyName(t *testing.T) {
+	tests := []struct {
+		ev       *tcell.EventKey
+		expected string
+	}{
+		{tcell.NewEventKey(tcell.KeyRune, 'x', 0), "x"},
+		{tcell.NewEventKey(tcell.KeyRune, 'X', tcell.ModShift), "X"},
+		{tcell.NewEventKey(tcell.KeyRune, ' ', 0), "<Space>"},
+		{tcell.NewEventKey(tcell.KeyRune, 'x', tcell.ModAlt), "<A-x>"},
+		{tcell.NewEventKey(tcell.KeyCtrlW, 0, tcell.ModCtrl), "<C-w>"},
+		{tcell.NewEventKey(tcell.KeyPgDn, 0, tcell.ModCtrl), "<C-PgDn>"},
+		{tcell.NewEventKey(tcell.KeyEnter, 0, 0), "<CR>"},
+		{tcell.NewEventKey(tcell.KeyBackspace2, 0, 0), "<BS>"},
+	}
+	for _, tt := range tests {
+		if name := KeyName(tt.ev); name != tt.expected {
+			t.Fatalf("Wrong key name. Expected=%q, got=%q", tt.expected, name)
+		}
+	}
+}
+
+func TestKeymap(t *testing.T) {
+	k := NewKeymap()
+	k.Bind("all", "<C-s>", "buffer.save")
+	k.Bind("normal", "dd", "edit.delete-line")
+	k.Bind("normal", "<C-w>h", "window.left")
+	if err := k.Bind("nope", "x", "edit.undo"); err == nil {
+		t.Fatalf("Unknown mode gave no error")
+	}
+
+	modes := []string{"normal", "all"}
+	if action, prefix := k.Lookup(modes, "d"); action != "" || !prefix {
+		t.Fatalf("d should be the start of dd")
+	}
+	if action, _ := k.Lookup(modes, "dd"); action != "edit.delete-line" {
+		t.Fatalf("Wrong action for dd, got=%q", action)
+	}
+	if action, _ := k.Lookup(modes, "<C-s>"); action != "buffer.save" {
+		t.Fatalf("Binding of all not used in normal mode")
+	}
+	if action, prefix := k.Lookup([]string{"insert", "all"}, "d"); action != "" || prefix {
+		t.Fatalf("Normal mode binding used in insert mode")
+	}
+
+	// Unbinding in a mode hides the binding of all
+	k.Bind("normal", "<C-s>", "")
+	if action, _ := k.Lookup(modes, "<C-s>"); action != "" {
+		t.Fatalf("Unbound keys still run %s", action)
+	}
+	if len(k.Bindings("normal")) != 2 || len(k.Bindings("")) != 3 {
+		t.Fatalf("Wrong bindings: %v", k.Bindings(""))
+	}
+}
@@ -0,0 +1,267 @@
+package editor
+
+import (
+	"NutCode/rope"
+	"NutCode/text"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"unicode/utf8"
+
+	"golang.org/x/text/transform"
+)
+
+// Size of the blocks large files are read in
+const largeFileBlock = 64 << 10
+
+// Large buffers keep the text around the cursors as a string, this much on
+// each side of them, see LoadAround
+const loadMargin = 1 << 20
+
+// Create a buffer for a file too big to be handled like others. The rope
+// reads its text from the file when it needs it, so only edited regions
+// live in it, and the buffer is not highlighted. The file is taken to be
+// UTF-8 and stays open until the buffer is closed.
+func newLargeBuffer(path string, options Options) (*Buffer, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	counts, starts, err := scanLineEndings(f, int(info.Size()))
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	format, mixed := counts.format()
+	b := &Buffer{
+		Path:    path,
+		Options: options,
+		Large:   true,
+		file:    f,
+
+		LineEndings:      format,
+		MixedLineEndings: mixed,
+		Encoding:         "utf-8",
+	}
+	b.Options.FileEncoding = "utf-8"
+	if format != "" {
+		b.Options.FileFormat = format
+	}
+	if counts["dos"] == 0 && counts["mac"] == 0 {
+		b.Content = text.NewRopeReader(f, int(info.Size()))
+	} else {
+		// Offsets in the file don't match the text, the rope reads the
+		// text through the blocks
+		r := &normalizedFile{file: f, starts: starts, size: int(info.Size()) - counts["dos"]}
+		b.Content = text.NewRopeReader(r, r.size)
+	}
+	b.loadText(0, loadMargin)
+	return b, nil
+}
+
+// Go through a file block by block, counting its line endings. Also gives
+// where each block starts in the text, once line endings are turned into \n.
+func scanLineEndings(f io.ReaderAt, size int) (lineEndings, []int, error) {
+	counts := lineEndings{}
+	var starts []int
+	buf := make([]byte, largeFileBlock+1)
+	offset, split := 0, false
+	for i := 0; i*largeFileBlock < size; i++ {
+		block, err := readBlock(f, i, buf)
+		if err != nil {
+			return nil, nil, err
+		}
+		blockCounts := countLineEndings(block)
+		if split {
+			// The \n starting the block ends the \r\n of the one before
+			blockCounts["unix"]--
+			blockCounts["dos"]++
+		}
+		for format, n := range blockCounts {
+			counts[format] += n
+		}
+		starts = append(starts, offset)
+		offset += len(block) - strings.Count(block, "\r\n")
+		split = len(block) < largeFileBlock
+	}
+	return counts, starts, nil
+}
+
+// Read a block of a file into buf, as text. A \r ending the block is left
+// out when the next one starts with \n, so that no \r\n is cut in two.
+func readBlock(f io.ReaderAt, i int, buf []byte) (string, error) {
+	n, err := f.ReadAt(buf[:largeFileBlock+1], int64(i)*largeFileBlock)
+	if err != nil && err != io.EOF {
+		return "", err
+	}
+	block := string(buf[:min(n, largeFileBlock)])
+	if n > largeFileBlock && buf[largeFileBlock] == '\n' {
+		block = strings.TrimSuffix(block, "\r")
+	}
+	return block, nil
+}
+
+// A file with \r\n or \r line endings, read as if they were \n
+type normalizedFile struct {
+	file io.ReaderAt
+	// Where each block of the file starts in the text
+	starts []int
+	// Length of the text
+	size int
+}
+
+func (r *normalizedFile) ReadAt(p []byte, off int64) (int, error) {
+	buf := make([]byte, largeFileBlock+1)
+	read := 0
+	for read < len(p) {
+		at := int(off) + read
+		i := sort.SearchInts(r.starts, at+1) - 1
+		if at >= r.size || i < 0 {
+			return read, io.EOF
+		}
+		block, err := readBlock(r.file, i, buf)
+		if err != nil {
+			return read, err
+		}
+		block = normalizeLineEndings(block)
+		if at-r.starts[i] >= len(block) {
+			// The file got shorter
+			return read, io.EOF
+		}
+		read += copy(p[read:], block[at-r.starts[i]:])
+	}
+	return read, nil
+}
+
+// Load the text of a large buffer around the range from start to end, where
+// the cursors and the selection are, unless it already is with some margin.
+// Returns true if Text changed.
+func (b *Buffer) LoadAround(start, end int) bool {
+	if !b.Large {
+		return false
+	}
+	textEnd := b.textStart + len(b.text)
+	before := b.textStart == 0 || start-b.textStart >= loadMargin/2
+	after := textEnd == b.Content.Len() || textEnd-end >= loadMargin/2
+	if start >= b.textStart && end <= textEnd && before && after {
+		return false
+	}
+	b.loadText(start-loadMargin, end+loadMargin)
+	return true
+}
+
+// Load the text between two offsets, taking in whole lines unless they are
+// very long
+func (b *Buffer) loadText(start, end int) {
+	c := b.Content
+	start, end = max(start, 0), min(end, c.Len())
+	if lineStart := c.LineStart(c.LineAt(start)); start-lineStart < loadMargin {
+		start = lineStart
+	}
+	if lineEnd := c.LineStart(c.LineAt(end) + 1); lineEnd-end < loadMargin {
+		end = lineEnd
+	}
+	text := c.Slice(start, end)
+	// Leave out the characters cut in two
+	for len(text) > 0 && !utf8.RuneStart(text[0]) {
+		text = text[1:]
+		start++
+	}
+	for i := len(text) - 1; i >= max(len(text)-utf8.UTFMax, 0); i-- {
+		if utf8.RuneStart(text[i]) {
+			if !utf8.FullRuneInString(text[i:]) {
+				text = text[:i]
+			}
+			break
+		}
+	}
+	b.text, b.textStart = text, start
+}
+
+// Make a change to the loaded text of a large buffer too, or load it again
+// when the change goes beyond it
+func (b *Buffer) applyLoadedText(t rope.Transaction) {
+	end := b.textStart + len(b.text)
+	for _, e := range t {
+		if e.Offset < b.textStart || e.Offset+e.Length > end {
+			b.loadText(t.MapOffset(b.textStart), t.MapOffset(end))
+			return
+		}
+	}
+	b.text = t.Shift(-b.textStart).ApplyToString(b.text)
+}
+
+// Write the content of a large buffer to a file without having all of it in
+// memory. The rope may still read the old file, so the new one is written
+// next to it and put in its place.
+func (b *Buffer) writeLargeFile(path string) error {
+	mode := os.FileMode(0644)
+	if info, err := os.Stat(path); err == nil {
+		mode = info.Mode()
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*")
+	if err != nil {
+		return err
+	}
+	err = b.writeText(tmp)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Chmod(tmp.Name(), mode)
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), path)
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+	}
+	return err
+}
+
+// Write the content chunk by chunk, with the line endings and character set
+// of the options
+func (b *Buffer) writeText(w io.Writer) error {
+	e, err := findEncoding(b.Options.FileEncoding)
+	if err != nil {
+		return err
+	}
+	var encoder *transform.Writer
+	if e != nil {
+		encoder = transform.NewWriter(w, e.NewEncoder())
+		w = encoder
+	} else if b.Options.FileEncoding == "utf-8-bom" {
+		if _, err := io.WriteString(w, "\uFEFF"); err != nil {
+			return err
+		}
+	}
+	for chunk := range b.Content.Chunks() {
+		if b.Options.FileFormat != "unix" {
+			chunk = strings.ReplaceAll(chunk, "\n", FileFormats[b.Options.FileFormat])
+		}
+		if _, err := io.WriteString(w, chunk); err != nil {
+			return err
+		}
+	}
+	if encoder != nil {
+		return encoder.Close()
+	}
+	return nil
+}
+
+// Close the file a large buffer reads from
+func (b *Buffer) Close() error {
+	if b.file == nil {
+		return nil
+	}
+	err := b.file.Close()
+	b.file = nil
+	return err
+}
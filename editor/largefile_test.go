@@ -0,0 +1,81 @@
+package editor
+
+import (
+	"NutCode/rope"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestLargeBuffer(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "big.go")
+	text := strings.Repeat("package main // a line of a big file\n", 30000)
+	os.WriteFile(path, []byte(text), 0600)
+	options := DefaultOptions()
+	options.LargeFileSize = 1
+	b, err := NewBuffer(path, options)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer b.Close()
+	if !b.Large || b.Syntax() != nil {
+		t.Fatalf("Expected a large buffer without highlighting")
+	}
+	if b.Content.String() != text {
+		t.Fatalf("Content of a large buffer differs")
+	}
+	// Only the text around the cursor is loaded, in whole lines
+	if loaded := b.Text(); len(loaded) >= len(text) || !strings.HasPrefix(text, loaded) || !strings.HasSuffix(loaded, "\n") {
+		t.Fatalf("Expected the start of the file loaded, got %d bytes", len(loaded))
+	}
+	if !b.LoadAround(len(text), len(text)) || b.TextStart() == 0 || b.TextStart()+len(b.Text()) != len(text) || text[b.TextStart()-1] != '\n' {
+		t.Fatalf("Expected the end of the file loaded, got %d bytes from %d", len(b.Text()), b.TextStart())
+	}
+	if b.LoadAround(len(text)-10, len(text)) {
+		t.Fatalf("Expected the text to stay loaded")
+	}
+
+	b.Apply(rope.Transaction{{Offset: 0, Length: 7, Text: "module"}}, 0)
+	if err := b.Save(""); err != nil {
+		t.Fatal(err)
+	}
+	data, _ := os.ReadFile(path)
+	if string(data) != "module"+text[7:] || b.Content.String() != string(data) {
+		t.Fatalf("Large buffer not saved right")
+	}
+	if info, _ := os.Stat(path); info.Mode().Perm() != 0600 {
+		t.Fatalf("File mode not kept, got=%v", info.Mode())
+	}
+	if b.Undo(); b.Content.String() != text || !strings.HasSuffix(text, b.Text()) {
+		t.Fatalf("Undo failed after saving")
+	}
+}
+
+func TestLargeBufferLineEndings(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "big.txt")
+	// A \r\n is cut by the end of the first block
+	text := strings.Repeat("x", largeFileBlock-1) + "\r\n" + strings.Repeat("a line\r\n", 30000) + "mac\rend"
+	os.WriteFile(path, []byte(text), 0600)
+	options := DefaultOptions()
+	options.LargeFileSize = 1
+	b, err := NewBuffer(path, options)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer b.Close()
+	normalized := normalizeLineEndings(text)
+	if b.Content.String() != normalized || b.Text() != normalized {
+		t.Fatalf("Expected \\n line endings")
+	}
+	if b.Options.FileFormat != "dos" || !b.MixedLineEndings {
+		t.Fatalf("Expected mixed dos line endings, got=%s", b.Options.FileFormat)
+	}
+	if err := b.Save(""); err != nil {
+		t.Fatal(err)
+	}
+	data, _ := os.ReadFile(path)
+	if string(data) != strings.ReplaceAll(normalized, "\n", "\r\n") {
+		t.Fatalf("Large buffer not saved with its line endings")
+	}
+}
@@ -0,0 +1,435 @@
+package editor
+
+import (
+	"errors"
+
+	"github.com/gdamore/tcell/v2"
+)
+
+// Directions for moving between windows
+const (
+	LEFT = iota
+	RIGHT
+	UP
+	DOWN
+)
+
+// Smallest size a window can be resized to
+const (
+	minWindowWidth  = 10
+	minWindowHeight = 2
+)
+
+// Windows arranged on the screen. The layout is a tree where every node is
+// either a window or a split holding two or more nodes, next to each other
+// (vertical split) or stacked on top of each other (horizontal split).
+type Layout struct {
+	screen  tcell.Screen
+	root    *layoutNode
+	current *EditorWindow
+	x       int
+	y       int
+	width   int
+	height  int
+}
+
+type layoutNode struct {
+	parent   *layoutNode
+	window   *EditorWindow
+	vertical bool
+	children []*layoutNode
+	// Width (vertical split) or height (horizontal split) given by the parent
+	size int
+}
+
+// Create a layout filling the screen with a single window
+func NewLayout(s tcell.Screen, ew *EditorWindow) *Layout {
+	w, h := s.Size()
+	l := &Layout{
+		screen:  s,
+		root:    &layoutNode{window: ew},
+		current: ew,
+	}
+	l.SetArea(0, 0, w, h)
+	return l
+}
+
+// Set the part of the screen used by the layout, windows keep their
+// proportions
+func (l *Layout) SetArea(x, y, width, height int) {
+	l.x, l.y, l.width, l.height = x, y, width, height
+	l.arrange(l.root, x, y, width, height)
+}
+
+// Get the window with the focus
+func (l *Layout) Current() *EditorWindow {
+	return l.current
+}
+
+// Get all windows, from top left to bottom right
+func (l *Layout) Windows() []*EditorWindow {
+	windows := []*EditorWindow{}
+	l.root.walk(func(n *layoutNode) {
+		windows = append(windows, n.window)
+	})
+	return windows
+}
+
+// Give a window the focus
+func (l *Layout) SetCurrent(ew *EditorWindow) {
+	if l.find(ew) == nil {
+		return
+	}
+	l.current.active = false
+	l.current = ew
+	ew.active = true
+}
+
+// Split the current window in two, vertical puts the new window to the
+// right instead of below. The new window shows the same buffer at the same
+// position, and gets the focus.
+func (l *Layout) Split(vertical bool) *EditorWindow {
+	old := l.current
+	ew := New(l.screen, old.startRow, old.StartCol, old.lineNumberWidth, old.contentOffset)
+	if old.Buffer != nil {
+		ew.Buffer = old.Buffer
+		ew.Cursor.offset = old.Cursor.offset
+		old.Buffer.trackWindow(ew)
+	}
+
+	node := l.find(old)
+	parent := node.parent
+	newNode := &layoutNode{window: ew}
+	if parent == nil || parent.vertical != vertical {
+		// Turn the window into a split holding it and the new window
+		parent = &layoutNode{parent: node.parent, vertical: vertical, size: node.size}
+		if node.parent == nil {
+			l.root = parent
+		} else {
+			node.parent.replace(node, parent)
+		}
+		node.parent = parent
+		newNode.parent = parent
+		parent.children = []*layoutNode{node, newNode}
+		if vertical {
+			parent.equalizeTo(old.width - 1)
+		} else {
+			parent.equalizeTo(old.height)
+		}
+	} else {
+		// Share the space of the old window
+		newNode.parent = parent
+		newNode.size = node.size / 2
+		node.size -= newNode.size
+		parent.insertAfter(node, newNode)
+	}
+
+	l.arrange(l.root, l.x, l.y, l.width, l.height)
+	l.SetCurrent(ew)
+	return ew
+}
+
+// Close the current window, the focus goes to a neighbour
+func (l *Layout) Close() error {
+	node := l.find(l.current)
+	parent := node.parent
+	if parent == nil {
+		return errors.New("Cannot close last window")
+	}
+	i := parent.index(node)
+	parent.children = append(parent.children[:i], parent.children[i+1:]...)
+	// Give the space to the neighbour
+	neighbour := parent.children[max(i-1, 0)]
+	neighbour.size += node.size
+	focus := neighbour.first().window
+	if len(parent.children) == 1 {
+		// A split of one is just the node
+		grandparent := parent.parent
+		neighbour.parent = grandparent
+		neighbour.size = parent.size
+		if grandparent == nil {
+			l.root = neighbour
+		} else if neighbour.window == nil && neighbour.vertical == grandparent.vertical {
+			// Splits in the same direction are merged
+			j := grandparent.index(parent)
+			children := append([]*layoutNode{}, grandparent.children[:j]...)
+			children = append(children, neighbour.children...)
+			grandparent.children = append(children, grandparent.children[j+1:]...)
+			for _, child := range neighbour.children {
+				child.parent = grandparent
+			}
+		} else {
+			grandparent.replace(parent, neighbour)
+		}
+	}
+	if l.current.Buffer != nil {
+		l.current.StoreView(l.current.Buffer)
+		l.current.Buffer.untrackWindow(l.current)
+	}
+
+	l.arrange(l.root, l.x, l.y, l.width, l.height)
+	l.SetCurrent(focus)
+	return nil
+}
+
+// Close every window except the current one
+func (l *Layout) Only() {
+	for _, ew := range l.Windows() {
+		if ew != l.current && ew.Buffer != nil {
+			ew.Buffer.untrackWindow(ew)
+		}
+	}
+	l.root = &layoutNode{window: l.current}
+	l.arrange(l.root, l.x, l.y, l.width, l.height)
+}
+
+// Move the focus to the window next to the current one in a direction
+func (l *Layout) Focus(direction int) {
+	x, y, w, h := l.current.Rect()
+	// Prefer the window next to the cursor
+	cx := x + l.current.contentOffset + l.current.cursorX
+	cy := y + l.current.cursorY
+	var best *EditorWindow
+	for _, ew := range l.Windows() {
+		ox, oy, ow, oh := ew.Rect()
+		adjacent := false
+		overlaps := false
+		switch direction {
+		case LEFT:
+			// Windows next to each other have a border between them
+			adjacent = ox+ow+1 == x
+			overlaps = oy <= cy && cy < oy+oh
+		case RIGHT:
+			adjacent = x+w+1 == ox
+			overlaps = oy <= cy && cy < oy+oh
+		case UP:
+			adjacent = oy+oh == y
+			overlaps = ox <= cx && cx < ox+ow
+		case DOWN:
+			adjacent = y+h == oy
+			overlaps = ox <= cx && cx < ox+ow
+		}
+		if !adjacent {
+			continue
+		}
+		if best == nil || overlaps {
+			best = ew
+		}
+		if overlaps {
+			break
+		}
+	}
+	if best != nil {
+		l.SetCurrent(best)
+	}
+}
+
+// Move the focus to the next window, wrapping around
+func (l *Layout) FocusNext() {
+	windows := l.Windows()
+	for i, ew := range windows {
+		if ew == l.current {
+			l.SetCurrent(windows[(i+1)%len(windows)])
+			return
+		}
+	}
+}
+
+// Make the current window larger or smaller, in width if vertical and
+// otherwise in height
+func (l *Layout) Resize(delta int, vertical bool) {
+	node := l.find(l.current)
+	// Find the split the window is part of in that direction
+	for node.parent != nil && node.parent.vertical != vertical {
+		node = node.parent
+	}
+	parent := node.parent
+	if parent == nil {
+		return
+	}
+	minSize := minWindowHeight
+	if vertical {
+		minSize = minWindowWidth
+	}
+	// Take the space from the next node, or the previous for the last one
+	i := parent.index(node)
+	other := parent.children[len(parent.children)-2]
+	if i < len(parent.children)-1 {
+		other = parent.children[i+1]
+	}
+	delta = min(delta, other.size-minSize)
+	delta = max(delta, minSize-node.size)
+	node.size += delta
+	other.size -= delta
+	l.arrange(l.root, l.x, l.y, l.width, l.height)
+}
+
+// Give all windows the same size
+func (l *Layout) Equalize() {
+	l.root.walkSplits(func(n *layoutNode) {
+		n.equalize()
+	})
+	l.arrange(l.root, l.x, l.y, l.width, l.height)
+}
+
+// Draw all windows and the borders between them. Windows are drawn with
+// their own buffer, the mode is shown in the current window.
+func (l *Layout) Draw(mode int) {
+	border := overlay(theme.Default, theme.Border)
+	l.root.walkSplits(func(n *layoutNode) {
+		if !n.vertical {
+			return
+		}
+		// Draw a line left of every window but the first
+		for _, child := range n.children[1:] {
+			x, y, _, h := child.first().window.Rect()
+			for row := y; row < y+h; row++ {
+				l.screen.SetContent(x-1, row, tcell.RuneVLine, nil, border)
+			}
+		}
+	})
+	for _, ew := range l.Windows() {
+		if ew != l.current {
+			ew.drawBuffer(mode)
+		}
+	}
+	// Last, so that the cursor ends up in the current window
+	l.current.drawBuffer(mode)
+}
+
+func (ew *EditorWindow) drawBuffer(mode int) {
+	if ew.Buffer == nil {
+		ew.DrawFull("", false, mode)
+		return
+	}
+	ew.useOptions(ew.Buffer.Options)
+	ew.DrawFull(ew.Buffer.Name(), ew.Buffer.Dirty, mode)
+}
+
+// Split up an area between the node and its children
+func (l *Layout) arrange(n *layoutNode, x, y, width, height int) {
+	if n.window != nil {
+		n.window.SetRect(x, y, width, height)
+		return
+	}
+	total := height
+	if n.vertical {
+		total = width
+	}
+	// One cell between windows next to each other is used by the border
+	gaps := 0
+	if n.vertical {
+		gaps = len(n.children) - 1
+	}
+	n.fit(total - gaps)
+	for _, child := range n.children {
+		if n.vertical {
+			l.arrange(child, x, y, child.size, height)
+			x += child.size + 1
+		} else {
+			l.arrange(child, x, y, width, child.size)
+			y += child.size
+		}
+	}
+}
+
+// Scale the sizes of the children so that they add up to total
+func (n *layoutNode) fit(total int) {
+	sum := 0
+	for _, child := range n.children {
+		sum += child.size
+	}
+	if sum == total {
+		return
+	}
+	if sum <= 0 {
+		n.equalizeTo(total)
+		return
+	}
+	used := 0
+	for i, child := range n.children {
+		if i == len(n.children)-1 {
+			child.size = total - used
+		} else {
+			child.size = max(child.size*total/sum, 1)
+			used += child.size
+		}
+	}
+}
+
+func (n *layoutNode) equalize() {
+	total := 0
+	for _, child := range n.children {
+		total += child.size
+	}
+	n.equalizeTo(total)
+}
+
+func (n *layoutNode) equalizeTo(total int) {
+	for i, child := range n.children {
+		child.size = total / len(n.children)
+		if i < total%len(n.children) {
+			child.size++
+		}
+	}
+}
+
+// Call f for every window below the node
+func (n *layoutNode) walk(f func(*layoutNode)) {
+	if n.window != nil {
+		f(n)
+		return
+	}
+	for _, child := range n.children {
+		child.walk(f)
+	}
+}
+
+// Call f for every split below the node, including itself
+func (n *layoutNode) walkSplits(f func(*layoutNode)) {
+	if n.window != nil {
+		return
+	}
+	f(n)
+	for _, child := range n.children {
+		child.walkSplits(f)
+	}
+}
+
+// Get the top left window below the node
+func (n *layoutNode) first() *layoutNode {
+	for n.window == nil {
+		n = n.children[0]
+	}
+	return n
+}
+
+func (n *layoutNode) index(child *layoutNode) int {
+	for i, c := range n.children {
+		if c == child {
+			return i
+		}
+	}
+	return -1
+}
+
+func (n *layoutNode) replace(old, new *layoutNode) {
+	n.children[n.index(old)] = new
+}
+
+func (n *layoutNode) insertAfter(after, node *layoutNode) {
+	i := n.index(after) + 1
+	n.children = append(n.children, nil)
+	copy(n.children[i+1:], n.children[i:])
+	n.children[i] = node
+}
+
+func (l *Layout) find(ew *EditorWindow) *layoutNode {
+	var found *layoutNode
+	l.root.walk(func(n *layoutNode) {
+		if n.window == ew {
+			found = n
+		}
+	})
+	return found
+}
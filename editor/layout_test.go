@@ -0,0 +1,94 @@
+package editor
+
+import (
+	"NutCode/rope"
+	"testing"
+
+	"github.com/gdamore/tcell/v2"
+)
+
+func newTestLayout(t *testing.T, width, height int) *Layout {
+	s := tcell.NewSimulationScreen("")
+	if err := s.Init(); err != nil {
+		t.Fatal(err)
+	}
+	s.SetSize(width, height)
+	ew := New(s, 0, 0, 5, 7)
+	ew.ShowBuffer(NewBufferFromString("", "hello\nworld\n"))
+	return NewLayout(s, ew)
+}
+
+func checkRect(t *testing.T, ew *EditorWindow, x, y, width, height int) {
+	t.Helper()
+	ox, oy, ow, oh := ew.Rect()
+	if ox != x || oy != y || ow != width || oh != height {
+		t.Fatalf("Wrong window area. Expected=%d,%d %dx%d, got=%d,%d %dx%d", x, y, width, height, ox, oy, ow, oh)
+	}
+}
+
+func TestLayoutSplit(t *testing.T) {
+	l := newTestLayout(t, 81, 40)
+	first := l.Current()
+
+	right := l.Split(true)
+	if l.Current() != right || right.Buffer != first.Buffer {
+		t.Fatalf("New window should get the focus and show the same buffer")
+	}
+	checkRect(t, first, 0, 0, 40, 40)
+	checkRect(t, right, 41, 0, 40, 40)
+
+	below := l.Split(false)
+	checkRect(t, right, 41, 0, 40, 20)
+	checkRect(t, below, 41, 20, 40, 20)
+
+	l.Focus(LEFT)
+	if l.Current() != first {
+		t.Fatalf("Focus left did not reach the first window")
+	}
+	l.Focus(RIGHT)
+	if l.Current() != right {
+		t.Fatalf("Focus right should pick the window next to the cursor")
+	}
+
+	l.Resize(5, false)
+	checkRect(t, right, 41, 0, 40, 25)
+	checkRect(t, below, 41, 25, 40, 15)
+	l.Equalize()
+	checkRect(t, right, 41, 0, 40, 20)
+
+	if err := l.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if l.Current() != below {
+		t.Fatalf("Focus should move to the neighbour of the closed window")
+	}
+	checkRect(t, below, 41, 0, 40, 40)
+
+	l.Only()
+	checkRect(t, below, 0, 0, 81, 40)
+	if err := l.Close(); err == nil {
+		t.Fatalf("Closed the last window")
+	}
+}
+
+func TestLayoutSharedBuffer(t *testing.T) {
+	l := newTestLayout(t, 80, 40)
+	first := l.Current()
+	first.Cursor.Set(6)
+	second := l.Split(false)
+	first.AddCursor(2)
+
+	second.Buffer.Apply(rope.Transaction{{Offset: 0, Text: "oh "}}, 0)
+	if first.Cursor.Offset() != 9 || second.Cursor.Offset() != 9 {
+		t.Fatalf("Cursors did not follow the edit, got=%d and %d", first.Cursor.Offset(), second.Cursor.Offset())
+	}
+	if cursors := first.Cursors(); len(cursors) != 1 || cursors[0] != 5 {
+		t.Fatalf("Extra cursors did not follow the edit, got=%v", cursors)
+	}
+
+	l.Close()
+	second.Buffer.Apply(rope.Transaction{{Offset: 0, Text: "x"}}, 0)
+	if second.Cursor.Offset() != 9 {
+		t.Fatalf("Closed window is still tracked")
+	}
+}
@@ -0,0 +1,184 @@
+package editor
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// Marks are places in a buffer named by a letter, set with m and jumped to
+// with ' or ` like in vim. The buffer keeps them in step with its edits, so
+// they stay on the same text.
+
+func ValidMark(name rune) bool {
+	return name >= 'a' && name <= 'z'
+}
+
+// Put a mark at an offset, moving it if it is already set
+func (b *Buffer) SetMark(name rune, offset int) {
+	if b.marks == nil {
+		b.marks = make(map[rune]*int)
+	}
+	if mark, ok := b.marks[name]; ok {
+		*mark = offset
+		return
+	}
+	mark := &offset
+	b.marks[name] = mark
+	b.Track(mark)
+}
+
+// Get the offset of a mark, false if it is not set
+func (b *Buffer) Mark(name rune) (int, bool) {
+	mark, ok := b.marks[name]
+	if !ok {
+		return 0, false
+	}
+	return min(*mark, b.Content.Len()), true
+}
+
+func (b *Buffer) DeleteMark(name rune) {
+	if mark, ok := b.marks[name]; ok {
+		b.Untrack(mark)
+		delete(b.marks, name)
+	}
+}
+
+// Get the names of the marks set, in order
+func (b *Buffer) MarkNames() []rune {
+	names := make([]rune, 0, len(b.marks))
+	for name := range b.marks {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	return names
+}
+
+// Find the lines with a mark, for the gutter. A line with several marks
+// shows the first one.
+func (b *Buffer) markLines() map[int]rune {
+	lines := make(map[int]rune, len(b.marks))
+	for _, name := range b.MarkNames() {
+		offset, _ := b.Mark(name)
+		line := b.Content.LineAt(offset)
+		if _, ok := lines[line]; !ok {
+			lines[line] = name
+		}
+	}
+	return lines
+}
+
+// Marks of every file, kept in a state file from one session to the next
+type MarkStore struct {
+	path string
+	// Marks by the absolute path of their file
+	files map[string]map[string]markPosition
+	// Files whose marks were changed in this session
+	changed map[string]bool
+}
+
+// Where a mark is in a file. Lines and columns are kept rather than offsets,
+// so that marks stay close when the file is changed by something else.
+type markPosition struct {
+	Line int `json:"line"`
+	Col  int `json:"col"`
+}
+
+// Read the marks saved in a state file, there are none if it doesn't exist
+func LoadMarkStore(path string) (*MarkStore, error) {
+	ms := &MarkStore{path: path, changed: make(map[string]bool)}
+	files, err := readMarks(path)
+	ms.files = files
+	return ms, err
+}
+
+func readMarks(path string) (map[string]map[string]markPosition, error) {
+	files := make(map[string]map[string]markPosition)
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return files, nil
+	}
+	if err != nil {
+		return files, err
+	}
+	if err := json.Unmarshal(data, &files); err != nil {
+		return make(map[string]map[string]markPosition), fmt.Errorf("Invalid marks file %s: %w", path, err)
+	}
+	return files, nil
+}
+
+func markFile(b *Buffer) string {
+	if b.Path == "" {
+		return ""
+	}
+	path, err := filepath.Abs(b.Path)
+	if err != nil {
+		return ""
+	}
+	return path
+}
+
+// Set the marks of a buffer from the ones saved for its file
+func (ms *MarkStore) Restore(b *Buffer) {
+	for name, pos := range ms.files[markFile(b)] {
+		r := []rune(name)
+		if len(r) != 1 || !ValidMark(r[0]) || pos.Line >= b.Content.LineCount() {
+			continue
+		}
+		start := b.Content.LineStart(pos.Line)
+		line := strings.TrimSuffix(b.Content.Slice(start, b.Content.LineStart(pos.Line+1)), "\n")
+		b.SetMark(r[0], start+min(pos.Col, len(line)))
+	}
+}
+
+// Keep the marks of a buffer, to save them with the others. Buffers with
+// unsaved changes are left out, their marks may be on text never saved.
+func (ms *MarkStore) Remember(b *Buffer) {
+	path := markFile(b)
+	if path == "" || b.Dirty {
+		return
+	}
+	ms.changed[path] = true
+	if len(b.marks) == 0 {
+		delete(ms.files, path)
+		return
+	}
+	marks := make(map[string]markPosition, len(b.marks))
+	for _, name := range b.MarkNames() {
+		offset, _ := b.Mark(name)
+		line := b.Content.LineAt(offset)
+		marks[string(name)] = markPosition{Line: line, Col: offset - b.Content.LineStart(line)}
+	}
+	ms.files[path] = marks
+}
+
+// Write the marks to the state file. Only the files whose marks changed are
+// written over, other editors may have saved marks of other files since.
+func (ms *MarkStore) Save() error {
+	if ms.path == "" || len(ms.changed) == 0 {
+		return nil
+	}
+	files, err := readMarks(ms.path)
+	if err != nil {
+		return err
+	}
+	for path := range ms.changed {
+		if marks, ok := ms.files[path]; ok {
+			files[path] = marks
+		} else {
+			delete(files, path)
+		}
+	}
+	data, err := json.MarshalIndent(files, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(ms.path), 0755); err != nil {
+		return err
+	}
+	return os.WriteFile(ms.path, data, 0644)
+}
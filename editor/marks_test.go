@@ -0,0 +1,90 @@
+package editor
+
+import (
+	"NutCode/rope"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestMarksFollowEdits(t *testing.T) {
+	b := NewBufferFromString("", "one\ntwo\nthree\n")
+	b.SetMark('a', 4)
+	b.SetMark('b', 10)
+	b.Apply(rope.Transaction{{Offset: 0, Text: "zero\n"}, {Offset: 5, Length: 2}}, 0)
+	if offset, _ := b.Mark('a'); offset != 9 {
+		t.Fatalf("Mark a did not follow the insert, got=%d", offset)
+	}
+	if offset, _ := b.Mark('b'); offset != 13 {
+		t.Fatalf("Mark b did not follow the edits, got=%d", offset)
+	}
+	if lines := b.markLines(); lines[2] != 'a' || lines[3] != 'b' {
+		t.Fatalf("Wrong lines with marks, got=%v", lines)
+	}
+
+	b.SetMark('a', 0)
+	b.DeleteMark('b')
+	if _, ok := b.Mark('b'); ok {
+		t.Fatalf("Expected mark b to be deleted")
+	}
+	if names := b.MarkNames(); len(names) != 1 || names[0] != 'a' {
+		t.Fatalf("Expected only mark a, got=%q", names)
+	}
+}
+
+func TestMarkStore(t *testing.T) {
+	dir := t.TempDir()
+	statePath := filepath.Join(dir, "state", "marks.json")
+	path := filepath.Join(dir, "file.txt")
+	os.WriteFile(path, []byte("one\n  two\nthree\n"), 0644)
+
+	ms, err := LoadMarkStore(statePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, _ := NewBuffer(path, DefaultOptions())
+	b.SetMark('a', 8)
+	b.SetMark('z', 14)
+	ms.Remember(b)
+
+	// Another editor saves the marks of another file in the meantime
+	other, _ := LoadMarkStore(statePath)
+	o := NewBufferFromString(filepath.Join(dir, "other.txt"), "other")
+	o.SetMark('c', 2)
+	other.Remember(o)
+	if err := other.Save(); err != nil {
+		t.Fatal(err)
+	}
+	if err := ms.Save(); err != nil {
+		t.Fatal(err)
+	}
+
+	ms, err = LoadMarkStore(statePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, _ = NewBuffer(path, DefaultOptions())
+	ms.Restore(b)
+	if offset, ok := b.Mark('a'); !ok || offset != 8 {
+		t.Fatalf("Mark a not restored, got=%d", offset)
+	}
+	if offset, ok := b.Mark('z'); !ok || offset != 14 {
+		t.Fatalf("Mark z not restored, got=%d", offset)
+	}
+	o = NewBufferFromString(filepath.Join(dir, "other.txt"), "other")
+	ms.Restore(o)
+	if _, ok := o.Mark('c'); !ok {
+		t.Fatalf("Marks of the other file were lost")
+	}
+
+	// Marks past the end of a shorter file are dropped, columns are clamped
+	os.WriteFile(path, []byte("one\n  t"), 0644)
+	b, _ = NewBuffer(path, DefaultOptions())
+	ms.Restore(b)
+	if offset, _ := b.Mark('a'); offset != 7 {
+		t.Fatalf("Expected mark a at the end of its line, got=%d", offset)
+	}
+	if _, ok := b.Mark('z'); ok {
+		t.Fatalf("Expected mark z to be dropped")
+	}
+}
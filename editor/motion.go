@@ -0,0 +1,206 @@
+package editor
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/mattn/go-runewidth"
+)
+
+// A way of moving a cursor: where it goes from an offset in the content.
+// Every cursor is moved with the same motions, the main one and the extra
+// ones alike.
+type Motion func(content string, offset int) int
+
+// Find the start of the line containing offset, and the offset right after
+// its newline (or the end of the content for the last line)
+func LineBounds(content string, offset int) (int, int) {
+	offset = min(offset, len(content))
+	start := strings.LastIndexByte(content[:offset], '\n') + 1
+	end := strings.IndexByte(content[offset:], '\n')
+	if end == -1 {
+		return start, len(content)
+	}
+	return start, offset + end + 1
+}
+
+// Check if a character is part of words: letters, digits and the word
+// characters of the options
+func IsWordChar(r rune, wordChars string) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r) || strings.ContainsRune(wordChars, r)
+}
+
+// Move to the previous character of the line
+func Left(content string, offset int) int {
+	start, _ := LineBounds(content, offset)
+	if offset <= start {
+		return offset
+	}
+	_, size := utf8.DecodeLastRuneInString(content[:offset])
+	return offset - size
+}
+
+// Move to the next character of the line, up to its end
+func Right(content string, offset int) int {
+	if offset >= len(content) || content[offset] == '\n' {
+		return offset
+	}
+	_, size := utf8.DecodeRuneInString(content[offset:])
+	return offset + size
+}
+
+func LineStart(content string, offset int) int {
+	start, _ := LineBounds(content, offset)
+	return start
+}
+
+// Move to the end of the line, before its newline
+func LineEnd(content string, offset int) int {
+	_, end := LineBounds(content, offset)
+	if end > 0 && content[end-1] == '\n' {
+		end--
+	}
+	return max(end, offset)
+}
+
+// Move to the first character of the line that is not a space
+func FirstNonBlank(content string, offset int) int {
+	start, end := LineBounds(content, offset)
+	return start + len(content[start:end]) - len(strings.TrimLeft(content[start:end], " \t"))
+}
+
+// Move to the first character of the line that is not a space, or to the
+// start of the line when already there
+func SmartHome(content string, offset int) int {
+	indent := FirstNonBlank(content, offset)
+	if indent == offset || indent == len(content) || content[indent] == '\n' {
+		return LineStart(content, offset)
+	}
+	return indent
+}
+
+// Find the start of the line n lines below the one containing offset, or
+// above when n is negative, as far as the content goes
+func lineBelow(content string, offset, n int) int {
+	start, _ := LineBounds(content, offset)
+	for ; n > 0; n-- {
+		next := strings.IndexByte(content[start:], '\n')
+		if next == -1 {
+			break
+		}
+		start += next + 1
+	}
+	for ; n < 0 && start > 0; n++ {
+		start, _ = LineBounds(content, start-1)
+	}
+	return start
+}
+
+// Width of a character on screen when it starts at a column: tabs go up to
+// the next tab stop, wide characters take two columns
+func charWidth(r rune, col, tabSize int) int {
+	if r == '\t' {
+		return tabSize - col%tabSize
+	}
+	return max(runewidth.RuneWidth(r), 1)
+}
+
+// Find the width on screen of the start of a line
+func textWidth(s string, tabSize int) int {
+	col := 0
+	for _, r := range s {
+		col += charWidth(r, col, tabSize)
+	}
+	return col
+}
+
+// Find the column on screen of an offset, counted from the start of its line
+func VisualColumn(content string, offset, tabSize int) int {
+	start, _ := LineBounds(content, offset)
+	return textWidth(content[start:offset], tabSize)
+}
+
+// Find the offset of the character at a column on screen in the line
+// containing offset, or of the end of the line when it is too short
+func OffsetAtColumn(content string, offset, col, tabSize int) int {
+	start, _ := LineBounds(content, offset)
+	x := 0
+	for i, r := range content[start:] {
+		if r == '\n' {
+			return start + i
+		}
+		x += charWidth(r, x, tabSize)
+		if x > col {
+			return start + i
+		}
+	}
+	return len(content)
+}
+
+// Kinds of characters, words end where the kind changes
+const (
+	spaceChar = iota
+	wordChar
+	otherChar
+)
+
+func charKind(r rune, wordChars string) int {
+	switch {
+	case unicode.IsSpace(r):
+		return spaceChar
+	case IsWordChar(r, wordChars):
+		return wordChar
+	}
+	return otherChar
+}
+
+// Move to the start of the next word, or of the next run of punctuation
+func NextWord(wordChars string) Motion {
+	return func(content string, offset int) int {
+		if offset >= len(content) {
+			return offset
+		}
+		r, _ := utf8.DecodeRuneInString(content[offset:])
+		kind := charKind(r, wordChars)
+		// Skip the rest of the word, then the spaces after it
+		for _, skip := range []int{kind, spaceChar} {
+			for offset < len(content) {
+				r, size := utf8.DecodeRuneInString(content[offset:])
+				if charKind(r, wordChars) != skip {
+					break
+				}
+				offset += size
+			}
+		}
+		return offset
+	}
+}
+
+// Move to the start of the word before the cursor, or of the run of
+// punctuation
+func PrevWord(wordChars string) Motion {
+	return func(content string, offset int) int {
+		// Skip the spaces before the cursor, then the word before them
+		for offset > 0 {
+			r, size := utf8.DecodeLastRuneInString(content[:offset])
+			if charKind(r, wordChars) != spaceChar {
+				break
+			}
+			offset -= size
+		}
+		if offset == 0 {
+			return 0
+		}
+		r, _ := utf8.DecodeLastRuneInString(content[:offset])
+		kind := charKind(r, wordChars)
+		for offset > 0 {
+			r, size := utf8.DecodeLastRuneInString(content[:offset])
+			if charKind(r, wordChars) != kind {
+				break
+			}
+			offset -= size
+		}
+		return offset
+	}
+}
@@ -0,0 +1,123 @@
+package editor
+
+import (
+	"testing"
+
+	"github.com/gdamore/tcell/v2"
+)
+
+func TestMotions(t *testing.T) {
+	content := "  héllo wörld\n\tfoo_bar(x, y)\n\nend"
+	tests := []struct {
+		name     string
+		motion   Motion
+		offset   int
+		expected int
+	}{
+		{"left at line start", Left, 16, 16},
+		{"left over é", Left, 5, 3},
+		{"right over é", Right, 3, 5},
+		{"right at line end", Right, 15, 15},
+		{"line start", LineStart, 20, 16},
+		{"line end", LineEnd, 17, 30},
+		{"line end on an empty line", LineEnd, 31, 31},
+		{"smart home to the indent", SmartHome, 10, 2},
+		{"smart home from the indent", SmartHome, 2, 0},
+		{"smart home on an empty line", SmartHome, 31, 31},
+		{"next word", NextWord("_"), 2, 9},
+		{"next word over a newline", NextWord("_"), 9, 17},
+		{"next word to punctuation", NextWord("_"), 17, 24},
+		{"next word without word chars", NextWord(""), 17, 20},
+		{"previous word", PrevWord("_"), 9, 2},
+		{"previous word over a newline", PrevWord("_"), 17, 9},
+		{"previous word at the start", PrevWord("_"), 1, 0},
+	}
+	for _, tt := range tests {
+		if got := tt.motion(content, tt.offset); got != tt.expected {
+			t.Fatalf("%s from %d. Expected=%d, got=%d", tt.name, tt.offset, tt.expected, got)
+		}
+	}
+}
+
+func TestLineBelow(t *testing.T) {
+	content := "one\ntwo\n\nfour"
+	tests := []struct{ offset, n, expected int }{
+		{1, 1, 4},
+		{1, 2, 8},
+		{1, 10, 9},
+		{6, -1, 0},
+		{10, -5, 0},
+		{10, 0, 9},
+	}
+	for _, tt := range tests {
+		if got := lineBelow(content, tt.offset, tt.n); got != tt.expected {
+			t.Fatalf("%d lines from %d. Expected=%d, got=%d", tt.n, tt.offset, tt.expected, got)
+		}
+	}
+}
+
+func TestVisualColumn(t *testing.T) {
+	content := "a\tb\n日本語x\n\t\tz"
+	tests := []struct{ offset, col int }{
+		{0, 0},
+		{1, 1},
+		{2, 4},
+		{3, 5},
+		{7, 2},
+		{13, 6},
+		{14, 7},
+		{16, 4},
+		{17, 8},
+	}
+	for _, tt := range tests {
+		if got := VisualColumn(content, tt.offset, 4); got != tt.col {
+			t.Fatalf("Column of %d. Expected=%d, got=%d", tt.offset, tt.col, got)
+		}
+	}
+	for _, tt := range []struct{ col, offset int }{
+		{0, 0}, {2, 1}, {4, 2}, {9, 3},
+	} {
+		if got := OffsetAtColumn(content, 0, tt.col, 4); got != tt.offset {
+			t.Fatalf("Offset at column %d. Expected=%d, got=%d", tt.col, tt.offset, got)
+		}
+	}
+	// A column in the middle of a wide character is on the character
+	if got := OffsetAtColumn(content, 5, 3, 4); got != 7 {
+		t.Fatalf("Expected the second wide character, got=%d", got)
+	}
+}
+
+func TestMoveLinesKeepsColumn(t *testing.T) {
+	s := tcell.NewSimulationScreen("")
+	if err := s.Init(); err != nil {
+		t.Fatal(err)
+	}
+	ew := New(s, 0, 0, 5, 7)
+	content := "long line\nab\n\tx\n日本語です\nlong line"
+	ew.ShowBuffer(NewBufferFromString("", content))
+	ew.Buffer.Options.TabSize = 4
+
+	// Through short lines, the cursor comes back to column 6
+	ew.Cursor.Set(6)
+	for _, step := range []struct{ n, expected int }{
+		{1, 12},
+		{1, 15},
+		{1, 25},
+		{1, 38},
+		{-1, 25},
+		{-3, 6},
+	} {
+		ew.MoveLines(step.n)
+		if c := ew.Cursor.Offset(); c != step.expected {
+			t.Fatalf("Moving %d lines. Expected=%d, got=%d", step.n, step.expected, c)
+		}
+	}
+	// Moving sideways forgets the column
+	ew.MoveLines(1)
+	ew.Move(Right)
+	ew.Move(Left)
+	ew.MoveLines(2)
+	if c := ew.Cursor.Offset(); c != 16 {
+		t.Fatalf("Expected the column of the short line, got=%d", c)
+	}
+}
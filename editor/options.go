@@ -0,0 +1,235 @@
+package editor
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/gdamore/tcell/v2"
+)
+
+// Settings of a buffer, from the config file, .editorconfig files and :set
+type Options struct {
+	TabSize         int
+	LineNumberWidth int
+	ContentOffset   int
+	CursorStyle     string
+	// Tab inserts IndentSize spaces instead of a tab
+	ExpandTab  bool
+	IndentSize int
+	// Enter keeps the indent of the line, deeper after an opener
+	AutoIndent bool
+	// Characters that are part of words besides letters and digits
+	WordChars string
+	// Brackets matched by % and highlighted, as pairs like "(:),[:]"
+	MatchPairs string
+	// Typing an opening bracket or a quote inserts its closer too
+	AutoPairs bool
+	// Line endings and character set the file is saved with
+	FileFormat   string
+	FileEncoding string
+	// Character set of files that are not valid UTF-8 and have no byte order mark
+	FallbackEncoding string
+	// Files from this many MB on are read lazily and not highlighted, 0 for never
+	LargeFileSize int
+	// Cleaned up when saving
+	TrimTrailingWhitespace bool
+	InsertFinalNewline     bool
+}
+
+// Line endings of the file formats
+var FileFormats = map[string]string{
+	"unix": "\n",
+	"dos":  "\r\n",
+	"mac":  "\r",
+}
+
+// Cursor styles by the names used for the cursorStyle option
+var CursorStyles = map[string]tcell.CursorStyle{
+	"default":            tcell.CursorStyleDefault,
+	"block":              tcell.CursorStyleSteadyBlock,
+	"blinking-block":     tcell.CursorStyleBlinkingBlock,
+	"underline":          tcell.CursorStyleSteadyUnderline,
+	"blinking-underline": tcell.CursorStyleBlinkingUnderline,
+	"bar":                tcell.CursorStyleSteadyBar,
+	"blinking-bar":       tcell.CursorStyleBlinkingBar,
+}
+
+func DefaultOptions() Options {
+	return Options{
+		TabSize:         4,
+		LineNumberWidth: 5,
+		ContentOffset:   7,
+		CursorStyle:     "blinking-bar",
+		ExpandTab:       true,
+		IndentSize:      4,
+		AutoIndent:      true,
+		WordChars:       "_",
+		MatchPairs:      "(:),[:],{:}",
+		FileFormat:      "unix",
+		FileEncoding:    "utf-8",
+
+		FallbackEncoding: "latin1",
+		LargeFileSize:    64,
+	}
+}
+
+// Names of all options
+func OptionNames() []string {
+	return []string{
+		"tabSize", "lineNumberWidth", "contentOffset", "cursorStyle", "expandTab", "indentSize", "autoIndent", "wordChars",
+		"matchPairs", "autoPairs",
+		"fileFormat", "fileEncoding", "fallbackEncoding", "largeFileSize",
+		"trimTrailingWhitespace", "insertFinalNewline",
+	}
+}
+
+// Get the name of an option, which can be written in any case like in vim's
+// :set fileformat
+func optionName(name string) string {
+	for _, option := range OptionNames() {
+		if strings.EqualFold(option, name) {
+			return option
+		}
+	}
+	return name
+}
+
+// Set an option by name from its text value
+func (o *Options) Set(name, value string) error {
+	switch name = optionName(name); name {
+	case "tabSize":
+		return setInt(&o.TabSize, name, value, 1, 16)
+	case "lineNumberWidth":
+		return setInt(&o.LineNumberWidth, name, value, 0, 20)
+	case "contentOffset":
+		return setInt(&o.ContentOffset, name, value, 0, 40)
+	case "cursorStyle":
+		if _, ok := CursorStyles[value]; !ok {
+			names := []string{}
+			for n := range CursorStyles {
+				names = append(names, n)
+			}
+			sort.Strings(names)
+			return fmt.Errorf("Invalid value for %s: %s (one of %s)", name, value, strings.Join(names, ", "))
+		}
+		o.CursorStyle = value
+		return nil
+	case "expandTab":
+		return setBool(&o.ExpandTab, name, value)
+	case "indentSize":
+		return setInt(&o.IndentSize, name, value, 1, 16)
+	case "autoIndent":
+		return setBool(&o.AutoIndent, name, value)
+	case "wordChars":
+		o.WordChars = value
+		return nil
+	case "matchPairs":
+		if _, err := parsePairs(value); err != nil {
+			return fmt.Errorf("Invalid value for %s: %w", name, err)
+		}
+		o.MatchPairs = value
+		return nil
+	case "autoPairs":
+		return setBool(&o.AutoPairs, name, value)
+	case "fileFormat":
+		if _, ok := FileFormats[value]; !ok {
+			return fmt.Errorf("Invalid value for %s: %s (one of dos, mac, unix)", name, value)
+		}
+		o.FileFormat = value
+		return nil
+	case "fileEncoding", "fallbackEncoding":
+		value = strings.ToLower(value)
+		if _, err := findEncoding(value); err != nil {
+			return fmt.Errorf("Unsupported %s: %s", name, value)
+		}
+		if name == "fileEncoding" {
+			o.FileEncoding = value
+		} else {
+			o.FallbackEncoding = value
+		}
+		return nil
+	case "largeFileSize":
+		return setInt(&o.LargeFileSize, name, value, 0, 1<<20)
+	case "trimTrailingWhitespace":
+		return setBool(&o.TrimTrailingWhitespace, name, value)
+	case "insertFinalNewline":
+		return setBool(&o.InsertFinalNewline, name, value)
+	}
+	return fmt.Errorf("Unknown option: %s", name)
+}
+
+// Get the value of an option by name
+func (o *Options) Get(name string) (string, error) {
+	switch name = optionName(name); name {
+	case "tabSize":
+		return strconv.Itoa(o.TabSize), nil
+	case "lineNumberWidth":
+		return strconv.Itoa(o.LineNumberWidth), nil
+	case "contentOffset":
+		return strconv.Itoa(o.ContentOffset), nil
+	case "cursorStyle":
+		return o.CursorStyle, nil
+	case "expandTab":
+		return strconv.FormatBool(o.ExpandTab), nil
+	case "indentSize":
+		return strconv.Itoa(o.IndentSize), nil
+	case "autoIndent":
+		return strconv.FormatBool(o.AutoIndent), nil
+	case "wordChars":
+		return o.WordChars, nil
+	case "matchPairs":
+		return o.MatchPairs, nil
+	case "autoPairs":
+		return strconv.FormatBool(o.AutoPairs), nil
+	case "fileFormat":
+		return o.FileFormat, nil
+	case "fileEncoding":
+		return o.FileEncoding, nil
+	case "fallbackEncoding":
+		return o.FallbackEncoding, nil
+	case "largeFileSize":
+		return strconv.Itoa(o.LargeFileSize), nil
+	case "trimTrailingWhitespace":
+		return strconv.FormatBool(o.TrimTrailingWhitespace), nil
+	case "insertFinalNewline":
+		return strconv.FormatBool(o.InsertFinalNewline), nil
+	}
+	return "", fmt.Errorf("Unknown option: %s", name)
+}
+
+// Check the options that depend on each other
+func (o *Options) Validate() error {
+	if o.ContentOffset < o.LineNumberWidth {
+		return fmt.Errorf("contentOffset (%d) must be at least lineNumberWidth (%d)", o.ContentOffset, o.LineNumberWidth)
+	}
+	return nil
+}
+
+func setInt(target *int, name, value string, minValue, maxValue int) error {
+	n, err := strconv.Atoi(value)
+	if err != nil {
+		return fmt.Errorf("Invalid number for %s: %s", name, value)
+	}
+	if n < minValue || n > maxValue {
+		return fmt.Errorf("%s must be between %d and %d, got %d", name, minValue, maxValue, n)
+	}
+	*target = n
+	return nil
+}
+
+func setBool(target *bool, name, value string) error {
+	b, err := strconv.ParseBool(value)
+	if err != nil {
+		return fmt.Errorf("Invalid value for %s: %s (true or false)", name, value)
+	}
+	*target = b
+	return nil
+}
+
+// Take the gutter size from the options of the buffer shown
+func (ew *EditorWindow) useOptions(o Options) {
+	ew.lineNumberWidth = o.LineNumberWidth
+	ew.contentOffset = o.ContentOffset
+}
@@ -0,0 +1,125 @@
+package editor
+
+import "unicode"
+
+// Names of the special registers
+const (
+	UnnamedRegister   = '"'
+	YankRegister      = '0'
+	BlackHoleRegister = '_'
+	ClipboardRegister = '+'
+	SelectionRegister = '*'
+)
+
+// A single register, remembering if it was yanked by character or by line
+type Register struct {
+	Content  string
+	Linewise bool
+}
+
+// Vi-style registers: the unnamed register, the yank register "0, the named
+// registers a-z, the black hole "_ and the clipboard registers "+ and "*
+type Registers struct {
+	regs      map[rune]Register
+	clipboard Clipboard
+	// Send every yank to the clipboard, not only yanks into "+ and "*
+	SyncClipboard bool
+}
+
+func NewRegisters(clipboard Clipboard) *Registers {
+	return &Registers{
+		regs:      map[rune]Register{},
+		clipboard: clipboard,
+	}
+}
+
+// Check if the name can be used with the " prefix
+func ValidRegister(name rune) bool {
+	switch name {
+	case UnnamedRegister, YankRegister, BlackHoleRegister, ClipboardRegister, SelectionRegister:
+		return true
+	}
+	return unicode.IsLetter(name) && name < unicode.MaxASCII
+}
+
+// Store yanked text. Without a name the text goes to "" and "0.
+func (r *Registers) Yank(name rune, reg Register) {
+	if name == UnnamedRegister {
+		r.regs[YankRegister] = reg
+	}
+	if r.store(name, reg) && (r.SyncClipboard || isClipboard(name)) {
+		r.toClipboard(name, reg)
+	}
+}
+
+// Store deleted text. Unlike Yank, this leaves "0 untouched.
+func (r *Registers) Delete(name rune, reg Register) {
+	if r.store(name, reg) && isClipboard(name) {
+		r.toClipboard(name, reg)
+	}
+}
+
+// Get the content of a register, the clipboard registers are read from the
+// system clipboard when possible
+func (r *Registers) Get(name rune) (Register, bool) {
+	if name == BlackHoleRegister {
+		return Register{}, false
+	}
+	if isClipboard(name) && r.clipboard != nil {
+		text, err := r.clipboard.Paste()
+		if err == nil {
+			// Keep the linewise flag if the clipboard still holds our own yank
+			if reg, ok := r.regs[name]; ok && reg.Content == text {
+				return reg, true
+			}
+			return Register{Content: text}, true
+		}
+	}
+	reg, ok := r.regs[unicode.ToLower(name)]
+	return reg, ok
+}
+
+// Write the register, returns false if the text was thrown away
+func (r *Registers) store(name rune, reg Register) bool {
+	if name == BlackHoleRegister {
+		return false
+	}
+	if unicode.IsUpper(name) {
+		// Uppercase names append to the register
+		name = unicode.ToLower(name)
+		if old, ok := r.regs[name]; ok {
+			reg = appendRegister(old, reg)
+		}
+	}
+	r.regs[name] = reg
+	if name != UnnamedRegister {
+		r.regs[UnnamedRegister] = reg
+	}
+	return true
+}
+
+func (r *Registers) toClipboard(name rune, reg Register) {
+	if r.clipboard == nil {
+		return
+	}
+	// Failing to reach the clipboard should not lose the yank
+	_ = r.clipboard.Copy(reg.Content)
+	if !isClipboard(name) {
+		r.regs[ClipboardRegister] = reg
+	}
+}
+
+func isClipboard(name rune) bool {
+	return name == ClipboardRegister || name == SelectionRegister
+}
+
+// Append to a register, a linewise part makes the result linewise
+func appendRegister(old, reg Register) Register {
+	if reg.Linewise && !old.Linewise && len(old.Content) > 0 {
+		old.Content += "\n"
+	}
+	return Register{
+		Content:  old.Content + reg.Content,
+		Linewise: old.Linewise || reg.Linewise,
+	}
+}
@@ -0,0 +1,118 @@
+package editor
+
+import (
+	"bytes"
+	"encoding/base64"
+	"strings"
+	"testing"
+)
+
+type fakeClipboard struct {
+	content string
+}
+
+func (c *fakeClipboard) Copy(text string) error {
+	c.content = text
+	return nil
+}
+
+func (c *fakeClipboard) Paste() (string, error) {
+	return c.content, nil
+}
+
+func TestRegistersYank(t *testing.T) {
+	clip := &fakeClipboard{}
+	regs := NewRegisters(clip)
+
+	regs.Yank(UnnamedRegister, Register{Content: "hi"})
+	if clip.content != "" {
+		t.Fatalf("Yank reached the clipboard without SyncClipboard, got=%s", clip.content)
+	}
+	regs.SyncClipboard = true
+	regs.Yank(UnnamedRegister, Register{Content: "hello"})
+	for _, name := range []rune{UnnamedRegister, YankRegister, ClipboardRegister} {
+		reg, ok := regs.Get(name)
+		if !ok || reg.Content != "hello" {
+			t.Fatalf("Register %c: expected=hello, got=%s", name, reg.Content)
+		}
+	}
+
+	regs.Delete(UnnamedRegister, Register{Content: "line\n", Linewise: true})
+	reg, _ := regs.Get(UnnamedRegister)
+	if reg.Content != "line\n" || !reg.Linewise {
+		t.Fatalf("Unnamed register not updated by delete, got=%+v", reg)
+	}
+	reg, _ = regs.Get(YankRegister)
+	if reg.Content != "hello" {
+		t.Fatalf("Yank register changed by delete, got=%s", reg.Content)
+	}
+	if clip.content != "hello" {
+		t.Fatalf("Delete reached the clipboard, got=%s", clip.content)
+	}
+}
+
+func TestRegistersNamed(t *testing.T) {
+	regs := NewRegisters(nil)
+
+	regs.Yank('a', Register{Content: "foo"})
+	regs.Yank('A', Register{Content: "bar"})
+	reg, _ := regs.Get('a')
+	if reg.Content != "foobar" || reg.Linewise {
+		t.Fatalf("Append to charwise register failed, got=%+v", reg)
+	}
+	regs.Yank('A', Register{Content: "baz\n", Linewise: true})
+	reg, _ = regs.Get('a')
+	if reg.Content != "foobar\nbaz\n" || !reg.Linewise {
+		t.Fatalf("Append of linewise text failed, got=%+v", reg)
+	}
+
+	regs.Yank(BlackHoleRegister, Register{Content: "gone"})
+	reg, _ = regs.Get(UnnamedRegister)
+	if reg.Content != "foobar\nbaz\n" {
+		t.Fatalf("Black hole register wrote to unnamed register, got=%s", reg.Content)
+	}
+	if _, ok := regs.Get(BlackHoleRegister); ok {
+		t.Fatalf("Black hole register should always be empty")
+	}
+	if _, ok := regs.Get('q'); ok {
+		t.Fatalf("Unused register should be empty")
+	}
+}
+
+func TestRegistersClipboardLinewise(t *testing.T) {
+	clip := &fakeClipboard{}
+	regs := NewRegisters(clip)
+
+	regs.Yank(ClipboardRegister, Register{Content: "line\n", Linewise: true})
+	reg, _ := regs.Get(ClipboardRegister)
+	if !reg.Linewise {
+		t.Fatalf("Own yank read back from clipboard lost linewise flag")
+	}
+
+	// Someone else copied something
+	clip.content = "other"
+	reg, _ = regs.Get(ClipboardRegister)
+	if reg.Content != "other" || reg.Linewise {
+		t.Fatalf("Expected charwise clipboard content, got=%+v", reg)
+	}
+}
+
+func TestOSC52Copy(t *testing.T) {
+	t.Setenv("TMUX", "")
+	var out bytes.Buffer
+	clip := NewOSC52Clipboard(&out)
+	if err := clip.Copy("hi there"); err != nil {
+		t.Fatal(err)
+	}
+	expected := "\x1b]52;c;" + base64.StdEncoding.EncodeToString([]byte("hi there")) + "\a"
+	if out.String() != expected {
+		t.Fatalf("Wrong escape sequence. Expected=%q, got=%q", expected, out.String())
+	}
+
+	t.Setenv("TMUX", "/tmp/tmux-0/default,1,0")
+	out.Reset()
+	clip.Copy("hi")
+	if !strings.HasPrefix(out.String(), "\x1bPtmux;\x1b\x1b]52;") {
+		t.Fatalf("Sequence not wrapped for tmux, got=%q", out.String())
+	}
+}
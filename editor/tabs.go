@@ -0,0 +1,221 @@
+package editor
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+
+	"github.com/gdamore/tcell/v2"
+)
+
+// Tab pages, each holding its own layout of windows. When there is more than
+// one tab, the top row of the screen shows the tab bar.
+type TabPages struct {
+	screen  tcell.Screen
+	tabs    []*Layout
+	current int
+	width   int
+	height  int
+	// Where each tab is drawn in the tab bar, as [start, end) columns
+	labels [][2]int
+}
+
+func NewTabPages(s tcell.Screen, layout *Layout) *TabPages {
+	w, h := s.Size()
+	tp := &TabPages{
+		screen: s,
+		tabs:   []*Layout{layout},
+	}
+	tp.SetSize(w, h)
+	return tp
+}
+
+// Get the layout of the current tab
+func (tp *TabPages) Current() *Layout {
+	return tp.tabs[tp.current]
+}
+
+func (tp *TabPages) Tabs() []*Layout {
+	return tp.tabs
+}
+
+// Get the index of the current tab
+func (tp *TabPages) Index() int {
+	return tp.current
+}
+
+// Get the windows of all tabs
+func (tp *TabPages) Windows() []*EditorWindow {
+	windows := []*EditorWindow{}
+	for _, tab := range tp.tabs {
+		windows = append(windows, tab.Windows()...)
+	}
+	return windows
+}
+
+// Open a new tab after the current one, with a window showing a buffer
+func (tp *TabPages) New(b *Buffer) *Layout {
+	old := tp.Current().Current()
+	ew := New(tp.screen, 0, 0, old.lineNumberWidth, old.contentOffset)
+	ew.ShowBuffer(b)
+	layout := NewLayout(tp.screen, ew)
+	tp.current++
+	tp.tabs = append(tp.tabs, nil)
+	copy(tp.tabs[tp.current+1:], tp.tabs[tp.current:])
+	tp.tabs[tp.current] = layout
+	tp.SetSize(tp.width, tp.height)
+	return layout
+}
+
+// Close the current tab and all its windows
+func (tp *TabPages) Close() error {
+	if len(tp.tabs) == 1 {
+		return errors.New("Cannot close last tab page")
+	}
+	for _, ew := range tp.Current().Windows() {
+		if ew.Buffer != nil {
+			ew.StoreView(ew.Buffer)
+			ew.Buffer.untrackWindow(ew)
+		}
+	}
+	tp.tabs = append(tp.tabs[:tp.current], tp.tabs[tp.current+1:]...)
+	tp.current = min(tp.current, len(tp.tabs)-1)
+	tp.SetSize(tp.width, tp.height)
+	return nil
+}
+
+// Close every tab except the current one
+func (tp *TabPages) Only() {
+	current := tp.Current()
+	for _, tab := range tp.tabs {
+		if tab == current {
+			continue
+		}
+		for _, ew := range tab.Windows() {
+			if ew.Buffer != nil {
+				ew.Buffer.untrackWindow(ew)
+			}
+		}
+	}
+	tp.tabs = []*Layout{current}
+	tp.current = 0
+	tp.SetSize(tp.width, tp.height)
+}
+
+// Go to the next tab, wrapping around
+func (tp *TabPages) Next() {
+	tp.current = (tp.current + 1) % len(tp.tabs)
+}
+
+// Go to the previous tab, wrapping around
+func (tp *TabPages) Prev() {
+	tp.current = (tp.current - 1 + len(tp.tabs)) % len(tp.tabs)
+}
+
+// Go to a tab, counting from 1
+func (tp *TabPages) Go(n int) error {
+	if n < 1 || n > len(tp.tabs) {
+		return fmt.Errorf("Tab page %d does not exist", n)
+	}
+	tp.current = n - 1
+	return nil
+}
+
+// Move the current tab to a position, counting from 0, the position is
+// clamped to the existing tabs
+func (tp *TabPages) Move(to int) {
+	to = max(min(to, len(tp.tabs)-1), 0)
+	tab := tp.tabs[tp.current]
+	tp.tabs = append(tp.tabs[:tp.current], tp.tabs[tp.current+1:]...)
+	tp.tabs = append(tp.tabs[:to], append([]*Layout{tab}, tp.tabs[to:]...)...)
+	tp.current = to
+}
+
+// Set the size of the screen, the tab bar takes the top row when shown
+func (tp *TabPages) SetSize(width, height int) {
+	tp.width = width
+	tp.height = height
+	top := 0
+	if tp.showBar() {
+		top = 1
+	}
+	for _, tab := range tp.tabs {
+		tab.SetArea(0, top, width, height-top)
+	}
+}
+
+func (tp *TabPages) showBar() bool {
+	return len(tp.tabs) > 1
+}
+
+// Find the tab drawn at a position of the screen, -1 if there is none
+func (tp *TabPages) TabAt(x, y int) int {
+	if !tp.showBar() || y != 0 {
+		return -1
+	}
+	for i, label := range tp.labels {
+		if x >= label[0] && x < label[1] {
+			return i
+		}
+	}
+	return -1
+}
+
+// Draw the tab bar and the windows of the current tab
+func (tp *TabPages) Draw(mode int) {
+	if tp.showBar() {
+		tp.drawBar()
+	}
+	tp.Current().Draw(mode)
+}
+
+func (tp *TabPages) drawBar() {
+	style := overlay(theme.Default, theme.TabBar)
+	activeStyle := overlay(theme.Default, theme.TabBarActive)
+	fill := overlay(theme.Default, theme.TabBarFill)
+
+	tp.labels = tp.labels[:0]
+	x := 0
+	for i, tab := range tp.tabs {
+		s := style
+		if i == tp.current {
+			s = activeStyle
+		}
+		start := x
+		for _, r := range " " + tabLabel(i, tab) + " " {
+			if x < tp.width {
+				tp.screen.SetContent(x, 0, r, nil, s)
+			}
+			x++
+		}
+		tp.labels = append(tp.labels, [2]int{start, x})
+		if x < tp.width {
+			tp.screen.SetContent(x, 0, ' ', nil, fill)
+		}
+		x++
+	}
+	for ; x < tp.width; x++ {
+		tp.screen.SetContent(x, 0, ' ', nil, fill)
+	}
+}
+
+// Label of a tab: its number, the number of windows if more than one, a +
+// if a buffer has unsaved changes and the name of the current buffer
+func tabLabel(i int, tab *Layout) string {
+	label := fmt.Sprint(i + 1)
+	windows := tab.Windows()
+	if len(windows) > 1 {
+		label += fmt.Sprintf(" [%d]", len(windows))
+	}
+	for _, ew := range windows {
+		if ew.Buffer != nil && ew.Buffer.Dirty {
+			label += " +"
+			break
+		}
+	}
+	name := "[No Name]"
+	if b := tab.Current().Buffer; b != nil && b.Path != "" {
+		name = filepath.Base(b.Path)
+	}
+	return label + " " + name
+}
@@ -0,0 +1,40 @@
+package editor
+
+import "testing"
+
+func TestTabPages(t *testing.T) {
+	l := newTestLayout(t, 80, 40)
+	tabs := NewTabPages(l.screen, l)
+	checkRect(t, l.Current(), 0, 0, 80, 40)
+
+	second := tabs.New(NewBufferFromString("b.txt", "bee\n"))
+	if tabs.Current() != second || tabs.Index() != 1 {
+		t.Fatalf("New tab should become current")
+	}
+	// The tab bar takes the top row
+	checkRect(t, l.Current(), 0, 1, 80, 39)
+	checkRect(t, second.Current(), 0, 1, 80, 39)
+
+	tabs.Move(0)
+	if tabs.Tabs()[0] != second || tabs.Index() != 0 {
+		t.Fatalf("Tab was not moved")
+	}
+	tabs.Draw(0)
+	if i := tabs.TabAt(tabs.labels[1][0], 0); i != 1 {
+		t.Fatalf("Wrong tab at position. Expected=1, got=%d", i)
+	}
+	if i := tabs.TabAt(0, 1); i != -1 {
+		t.Fatalf("Found a tab outside the tab bar")
+	}
+
+	if err := tabs.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if tabs.Current() != l {
+		t.Fatalf("Wrong tab after closing")
+	}
+	checkRect(t, l.Current(), 0, 0, 80, 40)
+	if err := tabs.Close(); err == nil {
+		t.Fatalf("Closed the last tab")
+	}
+}
@@ -0,0 +1,371 @@
+package editor
+
+import (
+	"NutCode/syntax"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	"github.com/gdamore/tcell/v2"
+)
+
+// Colors and attributes of everything drawn. Styles are drawn on top of
+// each other, like a selection on the current line on a keyword, and a
+// style only changes what it sets.
+type Theme struct {
+	Name              string
+	Default           tcell.Style
+	LineNumber        tcell.Style
+	CurrentLineNumber tcell.Style
+	Mark              tcell.Style
+	CurrentLine       tcell.Style
+	Selection         tcell.Style
+	ExtraCursor       tcell.Style
+	SearchMatch       tcell.Style
+	MatchBracket      tcell.Style
+	Menu              tcell.Style
+	MenuSelected      tcell.Style
+	DiagnosticError   tcell.Style
+	DiagnosticWarning tcell.Style
+	DiagnosticInfo    tcell.Style
+	DiagnosticHint    tcell.Style
+	StatusBar         tcell.Style
+	StatusBarInactive tcell.Style
+	Border            tcell.Style
+	TabBar            tcell.Style
+	TabBarActive      tcell.Style
+	TabBarFill        tcell.Style
+	Error             tcell.Style
+	Syntax            map[syntax.Kind]tcell.Style
+}
+
+// Theme used for drawing, and the number of colors the terminal has
+var (
+	theme  = darkTheme()
+	colors = 256
+)
+
+func darkTheme() *Theme {
+	s := tcell.StyleDefault
+	return &Theme{
+		Name:              "dark",
+		Default:           s.Foreground(tcell.ColorReset).Background(tcell.ColorReset),
+		LineNumber:        s.Foreground(tcell.Color140),
+		CurrentLineNumber: s.Foreground(tcell.ColorReset),
+		Mark:              s.Foreground(tcell.Color215).Bold(true),
+		CurrentLine:       s.Background(tcell.Color24),
+		Selection:         s.Background(tcell.Color240),
+		ExtraCursor:       s.Reverse(true),
+		SearchMatch:       s.Background(tcell.Color94),
+		MatchBracket:      s.Background(tcell.Color30).Bold(true),
+		Menu:              s.Background(tcell.Color236).Foreground(tcell.ColorReset),
+		MenuSelected:      s.Background(tcell.Color31).Foreground(tcell.ColorReset),
+		DiagnosticError:   s.Foreground(tcell.Color203).Underline(true),
+		DiagnosticWarning: s.Foreground(tcell.Color221).Underline(true),
+		DiagnosticInfo:    s.Foreground(tcell.Color75).Underline(true),
+		DiagnosticHint:    s.Foreground(tcell.Color245).Underline(true),
+		StatusBar:         s.Background(tcell.Color18).Foreground(tcell.ColorReset),
+		StatusBarInactive: s.Background(tcell.Color236).Foreground(tcell.ColorReset),
+		Border:            s.Foreground(tcell.Color240),
+		TabBar:            s.Background(tcell.Color236).Foreground(tcell.ColorReset),
+		TabBarActive:      s.Background(tcell.Color24).Foreground(tcell.ColorReset),
+		TabBarFill:        s.Background(tcell.Color234),
+		Error:             s.Foreground(tcell.ColorRed),
+		Syntax: map[syntax.Kind]tcell.Style{
+			syntax.Comment:  s.Foreground(tcell.Color245),
+			syntax.Keyword:  s.Foreground(tcell.Color176),
+			syntax.Type:     s.Foreground(tcell.Color80),
+			syntax.String:   s.Foreground(tcell.Color114),
+			syntax.Number:   s.Foreground(tcell.Color215),
+			syntax.Constant: s.Foreground(tcell.Color215),
+			syntax.Function: s.Foreground(tcell.Color75),
+			syntax.Operator: s.Foreground(tcell.Color180),
+			syntax.Variable: s.Foreground(tcell.Color210),
+			syntax.Property: s.Foreground(tcell.Color110),
+			syntax.Heading:  s.Foreground(tcell.Color75).Bold(true),
+			syntax.Emphasis: s.Italic(true),
+			syntax.Link:     s.Foreground(tcell.Color80).Underline(true),
+		},
+	}
+}
+
+func lightTheme() *Theme {
+	s := tcell.StyleDefault
+	return &Theme{
+		Name:              "light",
+		Default:           s.Foreground(tcell.Color234).Background(tcell.Color231),
+		LineNumber:        s.Foreground(tcell.Color245),
+		CurrentLineNumber: s.Foreground(tcell.Color234).Bold(true),
+		Mark:              s.Foreground(tcell.Color166).Bold(true),
+		CurrentLine:       s.Background(tcell.Color254),
+		Selection:         s.Background(tcell.Color153),
+		ExtraCursor:       s.Reverse(true),
+		SearchMatch:       s.Background(tcell.Color222),
+		MatchBracket:      s.Background(tcell.Color116).Bold(true),
+		Menu:              s.Background(tcell.Color252).Foreground(tcell.Color234),
+		MenuSelected:      s.Background(tcell.Color110).Foreground(tcell.Color234),
+		DiagnosticError:   s.Foreground(tcell.Color160).Underline(true),
+		DiagnosticWarning: s.Foreground(tcell.Color130).Underline(true),
+		DiagnosticInfo:    s.Foreground(tcell.Color25).Underline(true),
+		DiagnosticHint:    s.Foreground(tcell.Color244).Underline(true),
+		StatusBar:         s.Background(tcell.Color110).Foreground(tcell.Color234),
+		StatusBarInactive: s.Background(tcell.Color250).Foreground(tcell.Color234),
+		Border:            s.Foreground(tcell.Color248),
+		TabBar:            s.Background(tcell.Color252).Foreground(tcell.Color234),
+		TabBarActive:      s.Background(tcell.Color110).Foreground(tcell.Color234),
+		TabBarFill:        s.Background(tcell.Color254),
+		Error:             s.Foreground(tcell.Color160),
+		Syntax: map[syntax.Kind]tcell.Style{
+			syntax.Comment:  s.Foreground(tcell.Color244),
+			syntax.Keyword:  s.Foreground(tcell.Color127),
+			syntax.Type:     s.Foreground(tcell.Color30),
+			syntax.String:   s.Foreground(tcell.Color28),
+			syntax.Number:   s.Foreground(tcell.Color166),
+			syntax.Constant: s.Foreground(tcell.Color166),
+			syntax.Function: s.Foreground(tcell.Color25),
+			syntax.Operator: s.Foreground(tcell.Color94),
+			syntax.Variable: s.Foreground(tcell.Color124),
+			syntax.Property: s.Foreground(tcell.Color24),
+			syntax.Heading:  s.Foreground(tcell.Color25).Bold(true),
+			syntax.Emphasis: s.Italic(true),
+			syntax.Link:     s.Foreground(tcell.Color30).Underline(true),
+		},
+	}
+}
+
+// Get a theme that comes with the editor, nil if there is none by that name
+func BuiltinTheme(name string) *Theme {
+	switch name {
+	case "dark":
+		return darkTheme()
+	case "light":
+		return lightTheme()
+	}
+	return nil
+}
+
+// Pick the built-in theme matching the background of the terminal, which
+// some terminals tell through $COLORFGBG
+func DefaultTheme() *Theme {
+	fgbg := os.Getenv("COLORFGBG")
+	bg := fgbg[strings.LastIndex(fgbg, ";")+1:]
+	if bg == "7" || bg == "15" {
+		return lightTheme()
+	}
+	return darkTheme()
+}
+
+// Get the theme used for drawing
+func CurrentTheme() *Theme {
+	return theme
+}
+
+// Draw with a theme. Colors the terminal lacks are replaced by the closest
+// ones it has.
+func SetTheme(t *Theme) {
+	theme = t.reduce(colors)
+}
+
+// Find out how many colors the screen can show, 256 or more means the
+// terminal also supports the 256 color palette, 1<<24 means truecolor
+func DetectColors(s tcell.Screen) int {
+	colors = s.Colors()
+	if colorterm := os.Getenv("COLORTERM"); colorterm == "truecolor" || colorterm == "24bit" {
+		colors = max(colors, 1<<24)
+	}
+	theme = theme.reduce(colors)
+	return colors
+}
+
+// Get the number of colors the terminal has
+func Colors() int {
+	return colors
+}
+
+// Load a theme file. The file sets styles by name, the other styles come
+// from the theme named by inherit, or the dark theme.
+//
+//	inherit = "light"
+//	[styles]
+//	linenumber = "#8a8a8a"
+//	currentline = "bg:254"
+//	"syntax.keyword" = "fg:magenta bold"
+func LoadTheme(path string) (*Theme, error) {
+	var file struct {
+		Inherit string            `toml:"inherit"`
+		Styles  map[string]string `toml:"styles"`
+	}
+	if _, err := toml.DecodeFile(path, &file); err != nil {
+		return nil, err
+	}
+	t := darkTheme()
+	if file.Inherit != "" {
+		if t = BuiltinTheme(file.Inherit); t == nil {
+			return nil, fmt.Errorf("Unknown theme to inherit: %s", file.Inherit)
+		}
+	}
+	t.Name = strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+	styles := t.styles()
+	names := make([]string, 0, len(file.Styles))
+	for name := range file.Styles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		style, err := ParseStyle(file.Styles[name])
+		if err != nil {
+			return nil, fmt.Errorf("Style %s: %w", name, err)
+		}
+		if kindName, ok := strings.CutPrefix(name, "syntax."); ok {
+			kind, ok := syntax.KindByName(kindName)
+			if !ok {
+				return nil, fmt.Errorf("Unknown token kind: %s", kindName)
+			}
+			t.Syntax[kind] = style
+			continue
+		}
+		target, ok := styles[name]
+		if !ok {
+			return nil, fmt.Errorf("Unknown style: %s", name)
+		}
+		*target = style
+	}
+	return t, nil
+}
+
+// Styles by the names used in theme files
+func (t *Theme) styles() map[string]*tcell.Style {
+	return map[string]*tcell.Style{
+		"default":            &t.Default,
+		"linenumber":         &t.LineNumber,
+		"linenumber.current": &t.CurrentLineNumber,
+		"mark":               &t.Mark,
+		"currentline":        &t.CurrentLine,
+		"selection":          &t.Selection,
+		"cursor.extra":       &t.ExtraCursor,
+		"search":             &t.SearchMatch,
+		"bracket.match":      &t.MatchBracket,
+		"menu":               &t.Menu,
+		"menu.selected":      &t.MenuSelected,
+		"diagnostic.error":   &t.DiagnosticError,
+		"diagnostic.warning": &t.DiagnosticWarning,
+		"diagnostic.info":    &t.DiagnosticInfo,
+		"diagnostic.hint":    &t.DiagnosticHint,
+		"statusbar":          &t.StatusBar,
+		"statusbar.inactive": &t.StatusBarInactive,
+		"border":             &t.Border,
+		"tabbar":             &t.TabBar,
+		"tabbar.active":      &t.TabBarActive,
+		"tabbar.fill":        &t.TabBarFill,
+		"error":              &t.Error,
+	}
+}
+
+// Parse a style like "fg:#d0d0d0 bg:236 bold". A color on its own is the
+// foreground. Colors are names, #rrggbb, numbers of the 256 color palette
+// or default for the terminal's own color.
+func ParseStyle(spec string) (tcell.Style, error) {
+	style := tcell.StyleDefault
+	for _, word := range strings.Fields(spec) {
+		switch word {
+		case "bold":
+			style = style.Bold(true)
+		case "italic":
+			style = style.Italic(true)
+		case "underline":
+			style = style.Underline(true)
+		case "reverse":
+			style = style.Reverse(true)
+		case "dim":
+			style = style.Dim(true)
+		case "blink":
+			style = style.Blink(true)
+		case "strikethrough":
+			style = style.StrikeThrough(true)
+		default:
+			where, name, ok := strings.Cut(word, ":")
+			if !ok {
+				where, name = "fg", word
+			}
+			color, err := parseColor(name)
+			if err != nil {
+				return style, err
+			}
+			switch where {
+			case "fg":
+				style = style.Foreground(color)
+			case "bg":
+				style = style.Background(color)
+			default:
+				return style, fmt.Errorf("Unknown style attribute: %s", word)
+			}
+		}
+	}
+	return style, nil
+}
+
+func parseColor(name string) (tcell.Color, error) {
+	name = strings.ToLower(name)
+	if name == "default" || name == "reset" {
+		return tcell.ColorReset, nil
+	}
+	if n, err := strconv.Atoi(name); err == nil {
+		if n < 0 || n > 255 {
+			return tcell.ColorDefault, fmt.Errorf("Color out of the 256 color palette: %d", n)
+		}
+		return tcell.PaletteColor(n), nil
+	}
+	color := tcell.GetColor(name)
+	if color == tcell.ColorDefault {
+		return color, fmt.Errorf("Unknown color: %s", name)
+	}
+	return color, nil
+}
+
+// Copy the theme with colors the terminal can show
+func (t *Theme) reduce(colors int) *Theme {
+	palette := []tcell.Color{}
+	for i := 0; i < min(colors, 256); i++ {
+		palette = append(palette, tcell.PaletteColor(i))
+	}
+	reduce := func(c tcell.Color) tcell.Color {
+		if !c.Valid() || len(palette) == 0 {
+			return c
+		}
+		if c.IsRGB() && colors >= 1<<24 || !c.IsRGB() && int(c-tcell.ColorValid) < len(palette) {
+			return c
+		}
+		return tcell.FindColor(c, palette)
+	}
+	reduceStyle := func(s tcell.Style) tcell.Style {
+		fg, bg, _ := s.Decompose()
+		return s.Foreground(reduce(fg)).Background(reduce(bg))
+	}
+
+	reduced := *t
+	for _, style := range reduced.styles() {
+		*style = reduceStyle(*style)
+	}
+	reduced.Syntax = map[syntax.Kind]tcell.Style{}
+	for kind, style := range t.Syntax {
+		reduced.Syntax[kind] = reduceStyle(style)
+	}
+	return &reduced
+}
+
+// Draw a style on top of another, what top leaves unset comes from base
+func overlay(base, top tcell.Style) tcell.Style {
+	fg, bg, attrs := top.Decompose()
+	baseFg, baseBg, baseAttrs := base.Decompose()
+	if fg == tcell.ColorDefault {
+		fg = baseFg
+	}
+	if bg == tcell.ColorDefault {
+		bg = baseBg
+	}
+	return tcell.StyleDefault.Foreground(fg).Background(bg).Attributes(baseAttrs | attrs)
+}
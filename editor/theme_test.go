@@ -0,0 +1,60 @@
+package editor
+
+import (
+	"NutCode/syntax"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/gdamore/tcell/v2"
+)
+
+func TestParseStyle(t *testing.T) {
+	style, err := ParseStyle("#ff0000 bg:236 bold")
+	if err != nil {
+		t.Fatal(err)
+	}
+	fg, bg, attrs := style.Decompose()
+	if fg != tcell.NewHexColor(0xff0000) || bg != tcell.Color236 || attrs != tcell.AttrBold {
+		t.Fatalf("Wrong style. Got fg=%v bg=%v attrs=%v", fg, bg, attrs)
+	}
+	for _, spec := range []string{"nocolor", "bg:300", "under:red"} {
+		if _, err := ParseStyle(spec); err == nil {
+			t.Fatalf("Invalid style %q gave no error", spec)
+		}
+	}
+}
+
+func TestLoadTheme(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "mine.toml")
+	os.WriteFile(path, []byte(`
+inherit = "light"
+[styles]
+linenumber = "fg:#8a8a8a"
+"syntax.keyword" = "red bold"
+`), 0644)
+	theme, err := LoadTheme(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if theme.Name != "mine" || theme.Selection != lightTheme().Selection {
+		t.Fatalf("Styles not set by the file should come from the light theme")
+	}
+	if fg, _, _ := theme.Syntax[syntax.Keyword].Decompose(); fg != tcell.ColorRed {
+		t.Fatalf("Keyword style not loaded, got fg=%v", fg)
+	}
+
+	// Without truecolor, colors are picked from the palette
+	reduced := theme.reduce(256)
+	if fg, _, _ := reduced.LineNumber.Decompose(); fg.IsRGB() || fg != tcell.Color245 {
+		t.Fatalf("Expected the closest palette color, got=%v", fg)
+	}
+	if fg, _, _ := theme.reduce(1 << 24).LineNumber.Decompose(); !fg.IsRGB() {
+		t.Fatalf("Truecolor terminals should keep the color")
+	}
+
+	os.WriteFile(path, []byte("[styles]\nlinenumbers = \"red\"\n"), 0644)
+	if _, err := LoadTheme(path); err == nil {
+		t.Fatalf("Unknown style name gave no error")
+	}
+}
@@ -0,0 +1,297 @@
+package lsp
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+// A language server the editor talks to
+type Client struct {
+	conn *Conn
+	// The server process, nil for a server reached some other way
+	cmd *exec.Cmd
+	// What the server said it can do when it started
+	Capabilities ServerCapabilities
+	Name         string
+}
+
+// What the server sends on its own. The functions run in the goroutine
+// reading from the server.
+type Notifications struct {
+	Diagnostics func(PublishDiagnosticsParams)
+	// Messages for the user
+	Message func(text string, isError bool)
+}
+
+// The standard input and output of a server process, as one connection
+type pipe struct {
+	io.ReadCloser
+	io.WriteCloser
+}
+
+func (p pipe) Close() error {
+	return errors.Join(p.WriteCloser.Close(), p.ReadCloser.Close())
+}
+
+// Start a language server for the project in root
+func Start(ctx context.Context, command []string, root string, n Notifications) (*Client, error) {
+	if len(command) == 0 {
+		return nil, errors.New("No language server command")
+	}
+	cmd := exec.Command(command[0], command[1:]...)
+	cmd.Dir = root
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, err
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, err
+	}
+	c, err := NewClient(ctx, pipe{stdout, stdin}, root, n)
+	if err != nil {
+		cmd.Process.Kill()
+		cmd.Wait()
+		return nil, err
+	}
+	c.cmd = cmd
+	if c.Name == "" {
+		c.Name = command[0]
+	}
+	return c, nil
+}
+
+// Start talking to a language server over a connection, like a pipe to a
+// server running in the same program
+func NewClient(ctx context.Context, rwc io.ReadWriteCloser, root string, n Notifications) (*Client, error) {
+	c := &Client{conn: NewConn(rwc, handler(n))}
+	var result struct {
+		Capabilities ServerCapabilities `json:"capabilities"`
+		ServerInfo   struct {
+			Name string `json:"name"`
+		} `json:"serverInfo"`
+	}
+	if err := c.conn.Call(ctx, "initialize", initializeParams(root), &result); err != nil {
+		c.conn.Close()
+		return nil, err
+	}
+	c.Capabilities = result.Capabilities
+	c.Name = result.ServerInfo.Name
+	if err := c.conn.Notify("initialized", struct{}{}); err != nil {
+		c.conn.Close()
+		return nil, err
+	}
+	return c, nil
+}
+
+// What the editor tells the server when it starts: where the project is
+// and what the editor can do
+func initializeParams(root string) any {
+	rootURI := PathToURI(root)
+	return map[string]any{
+		"processId":  os.Getpid(),
+		"clientInfo": map[string]string{"name": "nutcode"},
+		"rootUri":    rootURI,
+		"workspaceFolders": []map[string]string{
+			{"uri": rootURI, "name": root},
+		},
+		"capabilities": map[string]any{
+			"general": map[string]any{"positionEncodings": []string{"utf-16"}},
+			"textDocument": map[string]any{
+				"synchronization":    map[string]any{"didSave": true},
+				"hover":              map[string]any{"contentFormat": []string{"plaintext", "markdown"}},
+				"completion":         map[string]any{"completionItem": map[string]any{"snippetSupport": false}},
+				"definition":         map[string]any{},
+				"references":         map[string]any{},
+				"rename":             map[string]any{},
+				"publishDiagnostics": map[string]any{},
+			},
+			"workspace": map[string]any{"workspaceFolders": true, "configuration": true},
+		},
+	}
+}
+
+// Answer what the server sends on its own. Requests the editor has nothing
+// to say to get an empty answer, so that the server doesn't wait for one.
+func handler(n Notifications) Handler {
+	return func(method string, params json.RawMessage) (any, error) {
+		switch method {
+		case "textDocument/publishDiagnostics":
+			var p PublishDiagnosticsParams
+			if err := json.Unmarshal(params, &p); err == nil && n.Diagnostics != nil {
+				n.Diagnostics(p)
+			}
+		case "window/showMessage":
+			var p struct {
+				Type    int    `json:"type"`
+				Message string `json:"message"`
+			}
+			if err := json.Unmarshal(params, &p); err == nil && n.Message != nil {
+				n.Message(p.Message, p.Type == 1)
+			}
+		case "workspace/configuration":
+			var p struct {
+				Items []json.RawMessage `json:"items"`
+			}
+			json.Unmarshal(params, &p)
+			return make([]any, len(p.Items)), nil
+		case "workspace/applyEdit":
+			return map[string]bool{"applied": false}, nil
+		case "window/workDoneProgress/create", "client/registerCapability", "client/unregisterCapability":
+		default:
+			if !strings.HasPrefix(method, "$/") {
+				return nil, &ResponseError{Code: CodeMethodNotFound, Message: "Method not found: " + method}
+			}
+		}
+		return nil, nil
+	}
+}
+
+func (c *Client) DidOpen(uri, languageID string, version int, text string) error {
+	return c.conn.Notify("textDocument/didOpen", map[string]any{
+		"textDocument": TextDocumentItem{URI: uri, LanguageID: languageID, Version: version, Text: text},
+	})
+}
+
+func (c *Client) DidChange(uri string, version int, changes []TextDocumentContentChangeEvent) error {
+	return c.conn.Notify("textDocument/didChange", map[string]any{
+		"textDocument":   VersionedTextDocumentIdentifier{URI: uri, Version: version},
+		"contentChanges": changes,
+	})
+}
+
+func (c *Client) DidSave(uri string) error {
+	return c.conn.Notify("textDocument/didSave", map[string]any{"textDocument": TextDocumentIdentifier{URI: uri}})
+}
+
+func (c *Client) DidClose(uri string) error {
+	return c.conn.Notify("textDocument/didClose", map[string]any{"textDocument": TextDocumentIdentifier{URI: uri}})
+}
+
+func positionParams(uri string, pos Position) TextDocumentPositionParams {
+	return TextDocumentPositionParams{TextDocument: TextDocumentIdentifier{URI: uri}, Position: pos}
+}
+
+// Get the documentation of what is at a position, empty if there is none
+func (c *Client) Hover(ctx context.Context, uri string, pos Position) (string, error) {
+	var result *struct {
+		Contents json.RawMessage `json:"contents"`
+	}
+	if err := c.conn.Call(ctx, "textDocument/hover", positionParams(uri, pos), &result); err != nil || result == nil {
+		return "", err
+	}
+	return hoverText(result.Contents), nil
+}
+
+// Find where what is at a position is defined
+func (c *Client) Definition(ctx context.Context, uri string, pos Position) ([]Location, error) {
+	var result json.RawMessage
+	if err := c.conn.Call(ctx, "textDocument/definition", positionParams(uri, pos), &result); err != nil {
+		return nil, err
+	}
+	return locations(result), nil
+}
+
+// Get the locations of a definition result: a location, a list of them, or
+// a list of links
+func locations(result json.RawMessage) []Location {
+	var one Location
+	if json.Unmarshal(result, &one) == nil && one.URI != "" {
+		return []Location{one}
+	}
+	var links []struct {
+		Location
+		TargetURI            string `json:"targetUri"`
+		TargetSelectionRange Range  `json:"targetSelectionRange"`
+	}
+	json.Unmarshal(result, &links)
+	locs := make([]Location, 0, len(links))
+	for _, link := range links {
+		if link.TargetURI != "" {
+			locs = append(locs, Location{URI: link.TargetURI, Range: link.TargetSelectionRange})
+		} else {
+			locs = append(locs, link.Location)
+		}
+	}
+	return locs
+}
+
+// Find everything that refers to what is at a position, its declaration
+// included
+func (c *Client) References(ctx context.Context, uri string, pos Position) ([]Location, error) {
+	params := map[string]any{
+		"textDocument": TextDocumentIdentifier{URI: uri},
+		"position":     pos,
+		"context":      map[string]bool{"includeDeclaration": true},
+	}
+	var result []Location
+	err := c.conn.Call(ctx, "textDocument/references", params, &result)
+	return result, err
+}
+
+// Get the edits renaming what is at a position everywhere it is used
+func (c *Client) Rename(ctx context.Context, uri string, pos Position, newName string) (WorkspaceEdit, error) {
+	params := map[string]any{
+		"textDocument": TextDocumentIdentifier{URI: uri},
+		"position":     pos,
+		"newName":      newName,
+	}
+	var result WorkspaceEdit
+	err := c.conn.Call(ctx, "textDocument/rename", params, &result)
+	return result, err
+}
+
+// Get the completions at a position
+func (c *Client) Completion(ctx context.Context, uri string, pos Position) ([]CompletionItem, error) {
+	var result json.RawMessage
+	if err := c.conn.Call(ctx, "textDocument/completion", positionParams(uri, pos), &result); err != nil {
+		return nil, err
+	}
+	// A list of items, or a list that may be incomplete
+	var items []CompletionItem
+	if json.Unmarshal(result, &items) == nil {
+		return items, nil
+	}
+	var list struct {
+		Items []CompletionItem `json:"items"`
+	}
+	err := json.Unmarshal(result, &list)
+	return list.Items, err
+}
+
+// Stop the server, killing it if it doesn't stop before ctx is done
+func (c *Client) Shutdown(ctx context.Context) error {
+	err := c.conn.Call(ctx, "shutdown", nil, nil)
+	if err == nil {
+		err = c.conn.Notify("exit", nil)
+	}
+	c.conn.Close()
+	if c.cmd == nil {
+		return err
+	}
+	exited := make(chan struct{})
+	go func() {
+		c.cmd.Wait()
+		close(exited)
+	}()
+	select {
+	case <-exited:
+	case <-ctx.Done():
+		c.cmd.Process.Kill()
+		<-exited
+	}
+	return err
+}
+
+// Closed when the connection to the server is lost, like when it crashed
+func (c *Client) Done() <-chan struct{} {
+	return c.conn.Done()
+}
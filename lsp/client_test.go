@@ -0,0 +1,134 @@
+package lsp
+
+import (
+	"NutCode/rope"
+	"NutCode/text"
+	"context"
+	"slices"
+	"testing"
+	"time"
+)
+
+const testURI = "file:///project/main.go"
+
+func testContext(t *testing.T) context.Context {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	t.Cleanup(cancel)
+	return ctx
+}
+
+// Wait for the next diagnostics the server publishes
+func nextDiagnostics(t *testing.T, diagnostics chan PublishDiagnosticsParams) PublishDiagnosticsParams {
+	t.Helper()
+	select {
+	case p := <-diagnostics:
+		return p
+	case <-time.After(5 * time.Second):
+		t.Fatal("No diagnostics")
+		return PublishDiagnosticsParams{}
+	}
+}
+
+func TestSync(t *testing.T) {
+	diagnostics := make(chan PublishDiagnosticsParams, 10)
+	c, s := startFake(t, Notifications{Diagnostics: func(p PublishDiagnosticsParams) { diagnostics <- p }})
+	if c.Name != "fake" || c.Capabilities.SyncKind() != SyncIncremental {
+		t.Fatalf("Wrong server. Got name=%q, sync=%d", c.Name, c.Capabilities.SyncKind())
+	}
+
+	content := text.NewRope("héllo 𝄞 wörld\n")
+	if err := c.DidOpen(testURI, "go", 1, content.String()); err != nil {
+		t.Fatal(err)
+	}
+	if p := nextDiagnostics(t, diagnostics); len(p.Diagnostics) != 0 {
+		t.Fatalf("Wrong diagnostics. Expected none, got=%v", p.Diagnostics)
+	}
+
+	transactions := []rope.Transaction{
+		{{Offset: 11, Length: 0, Text: "TODO "}},
+		{{Offset: 0, Length: 6, Text: "hi"}, {Offset: 16, Length: 0, Text: "\nx"}},
+		{{Offset: 0, Length: 0, Text: "a\nb"}, {Offset: 3, Length: 4, Text: ""}},
+	}
+	for version, tr := range transactions {
+		changes := ContentChanges(content, tr)
+		text.Apply(content, tr)
+		if err := c.DidChange(testURI, version+2, changes); err != nil {
+			t.Fatal(err)
+		}
+		p := nextDiagnostics(t, diagnostics)
+		if s.text(testURI) != content.String() {
+			t.Fatalf("Wrong text on the server. Expected=%q, got=%q", content.String(), s.text(testURI))
+		}
+		// The diagnostics are where the text is
+		for _, d := range p.Diagnostics {
+			start, end := RangeToOffsets(content, d.Range)
+			if word := content.Slice(start, end); word != "TODO" {
+				t.Fatalf("Wrong diagnostic range. Expected=%q, got=%q", "TODO", word)
+			}
+		}
+	}
+}
+
+func TestRequests(t *testing.T) {
+	diagnostics := make(chan PublishDiagnosticsParams, 10)
+	c, _ := startFake(t, Notifications{Diagnostics: func(p PublishDiagnosticsParams) { diagnostics <- p }})
+	ctx := testContext(t)
+	content := text.NewRope("x := \"é\"; count := 1\ncount++\ncounter()\n")
+	c.DidOpen(testURI, "go", 1, content.String())
+	nextDiagnostics(t, diagnostics)
+	// On the second count
+	at := OffsetToPosition(content, 24)
+
+	hover, err := c.Hover(ctx, testURI, at)
+	if err != nil || hover != "word count" {
+		t.Fatalf("Wrong hover. Expected=%q, got=%q (%v)", "word count", hover, err)
+	}
+
+	locs, err := c.Definition(ctx, testURI, at)
+	if err != nil || len(locs) != 1 || locs[0].URI != testURI {
+		t.Fatalf("Wrong definition. Got=%v (%v)", locs, err)
+	}
+	if start, _ := RangeToOffsets(content, locs[0].Range); start != 11 {
+		t.Fatalf("Wrong definition. Expected=11, got=%d", start)
+	}
+
+	locs, err = c.References(ctx, testURI, at)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var starts []int
+	for _, loc := range locs {
+		start, _ := RangeToOffsets(content, loc.Range)
+		starts = append(starts, start)
+	}
+	if expected := []int{11, 22}; !slices.Equal(starts, expected) {
+		t.Fatalf("Wrong references. Expected=%v, got=%v", expected, starts)
+	}
+
+	edit, err := c.Rename(ctx, testURI, at, "n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	text.Apply(content, EditsToTransaction(content, edit.Edits()[testURI]))
+	if expected := "x := \"é\"; n := 1\nn++\ncounter()\n"; content.String() != expected {
+		t.Fatalf("Wrong rename. Expected=%q, got=%q", expected, content.String())
+	}
+
+	items, err := c.Completion(ctx, testURI, Position{Line: 2, Character: 6})
+	if err != nil || len(items) != 1 || items[0].Text() != "counter()" {
+		t.Fatalf("Wrong completions. Got=%v (%v)", items, err)
+	}
+}
+
+func TestServerGone(t *testing.T) {
+	c, s := startFake(t, Notifications{})
+	s.conn.Close()
+	select {
+	case <-c.Done():
+	case <-time.After(5 * time.Second):
+		t.Fatal("The client didn't see the server go")
+	}
+	if _, err := c.Hover(testContext(t), testURI, Position{}); err == nil {
+		t.Fatal("Expected an error from a request to a closed server")
+	}
+}
@@ -0,0 +1,3 @@
+module NutCode/lsp
+
+go 1.23
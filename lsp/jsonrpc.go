@@ -0,0 +1,227 @@
+// Package lsp is a client for language servers, which tell an editor
+// about the code in its buffers: errors, documentation, definitions and
+// completions. They talk JSON-RPC 2.0 over the standard input and output of
+// the server (https://microsoft.github.io/language-server-protocol/).
+package lsp
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Error codes of JSON-RPC
+const (
+	CodeMethodNotFound = -32601
+	CodeInternalError  = -32603
+)
+
+var ErrClosed = errors.New("Connection to the language server closed")
+
+// A message of JSON-RPC: a request when it has a method and an ID, a
+// notification with only a method, and a response without a method
+type message struct {
+	JSONRPC string           `json:"jsonrpc"`
+	ID      *json.RawMessage `json:"id,omitempty"`
+	Method  string           `json:"method,omitempty"`
+	Params  json.RawMessage  `json:"params,omitempty"`
+	Result  json.RawMessage  `json:"result,omitempty"`
+	Error   *ResponseError   `json:"error,omitempty"`
+}
+
+type ResponseError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+func (e *ResponseError) Error() string {
+	return e.Message
+}
+
+// Answers the requests and notifications the other side sends. The result
+// is ignored for notifications.
+type Handler func(method string, params json.RawMessage) (any, error)
+
+// A JSON-RPC connection. Messages are read in their own goroutine, which
+// the handler runs in.
+type Conn struct {
+	rwc     io.ReadWriteCloser
+	handler Handler
+
+	writeMu sync.Mutex
+	mu      sync.Mutex
+	nextID  int
+	pending map[int]chan *message
+	// Closed when reading stops, err tells why
+	done chan struct{}
+	err  error
+}
+
+func NewConn(rwc io.ReadWriteCloser, handler Handler) *Conn {
+	c := &Conn{rwc: rwc, handler: handler, pending: make(map[int]chan *message), done: make(chan struct{})}
+	go c.read()
+	return c
+}
+
+// Send a request and wait for its response, which is decoded into result
+func (c *Conn) Call(ctx context.Context, method string, params, result any) error {
+	c.mu.Lock()
+	c.nextID++
+	id := c.nextID
+	response := make(chan *message, 1)
+	c.pending[id] = response
+	c.mu.Unlock()
+	defer func() {
+		c.mu.Lock()
+		delete(c.pending, id)
+		c.mu.Unlock()
+	}()
+
+	rawID := json.RawMessage(strconv.Itoa(id))
+	if err := c.send(&message{ID: &rawID, Method: method}, params); err != nil {
+		return err
+	}
+	select {
+	case m := <-response:
+		if m.Error != nil {
+			return m.Error
+		}
+		if result == nil || len(m.Result) == 0 {
+			return nil
+		}
+		return json.Unmarshal(m.Result, result)
+	case <-ctx.Done():
+		c.Notify("$/cancelRequest", map[string]int{"id": id})
+		return ctx.Err()
+	case <-c.done:
+		return c.err
+	}
+}
+
+// Send a notification, which gets no response
+func (c *Conn) Notify(method string, params any) error {
+	return c.send(&message{Method: method}, params)
+}
+
+func (c *Conn) Close() error {
+	return c.rwc.Close()
+}
+
+// Closed when the connection stops reading messages
+func (c *Conn) Done() <-chan struct{} {
+	return c.done
+}
+
+func (c *Conn) send(m *message, params any) error {
+	m.JSONRPC = "2.0"
+	if params != nil {
+		data, err := json.Marshal(params)
+		if err != nil {
+			return err
+		}
+		m.Params = data
+	}
+	return c.write(m)
+}
+
+// Write a message with its header
+func (c *Conn) write(m *message) error {
+	data, err := json.Marshal(m)
+	if err != nil {
+		return err
+	}
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+	if _, err := fmt.Fprintf(c.rwc, "Content-Length: %d\r\n\r\n%s", len(data), data); err != nil {
+		return fmt.Errorf("%w: %w", ErrClosed, err)
+	}
+	return nil
+}
+
+func (c *Conn) read() {
+	r := bufio.NewReader(c.rwc)
+	for {
+		m, err := readMessage(r)
+		if err != nil {
+			c.err = fmt.Errorf("%w: %w", ErrClosed, err)
+			close(c.done)
+			return
+		}
+		switch {
+		case m.Method == "" && m.ID != nil:
+			var id int
+			json.Unmarshal(*m.ID, &id)
+			c.mu.Lock()
+			if response, ok := c.pending[id]; ok {
+				response <- m
+			}
+			c.mu.Unlock()
+		case m.Method != "":
+			c.handle(m)
+		}
+	}
+}
+
+// Run the handler for a request or a notification, and answer requests
+func (c *Conn) handle(m *message) {
+	result, err := c.handler(m.Method, m.Params)
+	if m.ID == nil {
+		return
+	}
+	response := &message{JSONRPC: "2.0", ID: m.ID}
+	if err != nil {
+		var re *ResponseError
+		if !errors.As(err, &re) {
+			re = &ResponseError{Code: CodeInternalError, Message: err.Error()}
+		}
+		response.Error = re
+	} else {
+		data, err := json.Marshal(result)
+		if err != nil {
+			response.Error = &ResponseError{Code: CodeInternalError, Message: err.Error()}
+		} else {
+			response.Result = data
+		}
+	}
+	c.write(response)
+}
+
+// Read a message: headers up to an empty line, then as many bytes of JSON
+// as the Content-Length header says
+func readMessage(r *bufio.Reader) (*message, error) {
+	length := -1
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return nil, err
+		}
+		line = strings.TrimSpace(line)
+		if line == "" {
+			break
+		}
+		name, value, _ := strings.Cut(line, ":")
+		if strings.EqualFold(name, "Content-Length") {
+			if length, err = strconv.Atoi(strings.TrimSpace(value)); err != nil {
+				return nil, fmt.Errorf("Invalid Content-Length: %s", value)
+			}
+		}
+	}
+	if length < 0 {
+		return nil, errors.New("Message without Content-Length")
+	}
+	data := make([]byte, length)
+	if _, err := io.ReadFull(r, data); err != nil {
+		return nil, err
+	}
+	m := &message{}
+	if err := json.Unmarshal(data, m); err != nil {
+		return nil, fmt.Errorf("Invalid message: %w", err)
+	}
+	return m, nil
+}
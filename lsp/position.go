@@ -0,0 +1,91 @@
+package lsp
+
+import (
+	"NutCode/rope"
+	"NutCode/text"
+	"slices"
+	"strings"
+	"unicode/utf16"
+)
+
+// Count the UTF-16 code units of a string
+func utf16Len(s string) int {
+	n := 0
+	for _, r := range s {
+		n += utf16.RuneLen(r)
+	}
+	return n
+}
+
+// Find the position of a byte offset in a text
+func OffsetToPosition(t text.TextBuffer, offset int) Position {
+	offset = max(0, min(offset, t.Len()))
+	line := t.LineAt(offset)
+	return Position{Line: line, Character: utf16Len(t.Slice(t.LineStart(line), offset))}
+}
+
+// Find the byte offset of a position in a text. Positions inside a character
+// are at its start, past the end of their line at its end, and lines past
+// the end of the text at its end.
+func PositionToOffset(t text.TextBuffer, p Position) int {
+	if p.Line < 0 {
+		return 0
+	}
+	if p.Line >= t.LineCount() {
+		return t.Len()
+	}
+	start := t.LineStart(p.Line)
+	line := strings.TrimSuffix(t.Slice(start, t.LineStart(p.Line+1)), "\n")
+	n := 0
+	for i, r := range line {
+		n += utf16.RuneLen(r)
+		if n > p.Character {
+			return start + i
+		}
+	}
+	return start + len(line)
+}
+
+func RangeToOffsets(t text.TextBuffer, r Range) (int, int) {
+	return PositionToOffset(t, r.Start), PositionToOffset(t, r.End)
+}
+
+// Describe a transaction as changes to a document, for a text it was not
+// applied to yet. The edits are sent from the last one, so that making one
+// doesn't move the ones still to come.
+func ContentChanges(t text.TextBuffer, tr rope.Transaction) []TextDocumentContentChangeEvent {
+	// Edits at the same offset are made in the reverse order too, like
+	// rope.Transaction does
+	edits := slices.Clone(tr)
+	slices.SortStableFunc(edits, func(a, b rope.Edit) int {
+		return a.Offset - b.Offset
+	})
+	slices.Reverse(edits)
+	changes := make([]TextDocumentContentChangeEvent, 0, len(edits))
+	for _, e := range edits {
+		r := Range{Start: OffsetToPosition(t, e.Offset), End: OffsetToPosition(t, e.Offset+e.Length)}
+		changes = append(changes, TextDocumentContentChangeEvent{Range: &r, Text: e.Text})
+	}
+	return changes
+}
+
+// Turn the edits of a document into a transaction of a text. Edits that
+// overlap one before them are left out.
+func EditsToTransaction(t text.TextBuffer, edits []TextEdit) rope.Transaction {
+	var tr rope.Transaction
+	for _, e := range edits {
+		start, end := RangeToOffsets(t, e.Range)
+		tr = append(tr, rope.Edit{Offset: start, Length: max(end-start, 0), Text: e.NewText})
+	}
+	slices.SortStableFunc(tr, func(a, b rope.Edit) int {
+		return a.Offset - b.Offset
+	})
+	end := 0
+	return slices.DeleteFunc(tr, func(e rope.Edit) bool {
+		if e.Offset < end {
+			return true
+		}
+		end = e.Offset + e.Length
+		return false
+	})
+}
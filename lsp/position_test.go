@@ -0,0 +1,87 @@
+package lsp
+
+import (
+	"NutCode/rope"
+	"NutCode/text"
+	"testing"
+)
+
+func TestPositions(t *testing.T) {
+	// é is 2 bytes and 1 UTF-16 unit, 𝄞 is 4 bytes and 2 units
+	content := text.NewRope("ab\né𝄞x\n")
+	tests := []struct {
+		offset   int
+		position Position
+	}{
+		{0, Position{0, 0}},
+		{2, Position{0, 2}},
+		{3, Position{1, 0}},
+		{5, Position{1, 1}},
+		{9, Position{1, 3}},
+		{10, Position{1, 4}},
+		{11, Position{2, 0}},
+	}
+	for _, test := range tests {
+		if p := OffsetToPosition(content, test.offset); p != test.position {
+			t.Errorf("Wrong position of %d. Expected=%v, got=%v", test.offset, test.position, p)
+		}
+		if offset := PositionToOffset(content, test.position); offset != test.offset {
+			t.Errorf("Wrong offset of %v. Expected=%d, got=%d", test.position, test.offset, offset)
+		}
+	}
+	// Positions out of the text are moved into it
+	for _, test := range []struct {
+		position Position
+		offset   int
+	}{
+		{Position{0, 10}, 2},
+		{Position{1, 2}, 5},
+		{Position{5, 0}, 11},
+		{Position{-1, 3}, 0},
+	} {
+		if offset := PositionToOffset(content, test.position); offset != test.offset {
+			t.Errorf("Wrong offset of %v. Expected=%d, got=%d", test.position, test.offset, offset)
+		}
+	}
+}
+
+func TestContentChanges(t *testing.T) {
+	tests := []struct {
+		content string
+		tr      rope.Transaction
+	}{
+		{"héllo\nwörld\n", rope.Transaction{{Offset: 1, Length: 2, Text: "e"}, {Offset: 8, Length: 2, Text: "o"}}},
+		{"a𝄞b\n", rope.Transaction{{Offset: 5, Length: 0, Text: "\nc"}, {Offset: 0, Length: 1, Text: ""}}},
+		// An insertion and a replacement at the same offset
+		{"()", rope.Transaction{{Offset: 1, Length: 0, Text: "\n\t"}, {Offset: 1, Length: 1, Text: "\n)"}}},
+	}
+	for _, test := range tests {
+		expected := text.NewRope(test.content)
+		text.Apply(expected, test.tr)
+		changes := ContentChanges(text.NewRope(test.content), test.tr)
+		// Applying the changes one after the other gives the same text
+		result := text.NewRope(test.content)
+		for _, change := range changes {
+			start, end := RangeToOffsets(result, *change.Range)
+			result.Delete(start, end-start)
+			result.Insert(start, change.Text)
+		}
+		if result.String() != expected.String() {
+			t.Errorf("Wrong result of %q. Expected=%q, got=%q", test.content, expected.String(), result.String())
+		}
+	}
+}
+
+func TestEditsToTransaction(t *testing.T) {
+	content := text.NewRope("fóo := 1\nfóo++\n")
+	edits := []TextEdit{
+		{Range: Range{Position{1, 0}, Position{1, 3}}, NewText: "bar"},
+		{Range: Range{Position{0, 0}, Position{0, 3}}, NewText: "bar"},
+		// Overlaps the one before it
+		{Range: Range{Position{0, 1}, Position{0, 2}}, NewText: "x"},
+	}
+	text.Apply(content, EditsToTransaction(content, edits))
+	if expected := "bar := 1\nbar++\n"; content.String() != expected {
+		t.Fatalf("Wrong result. Expected=%q, got=%q", expected, content.String())
+	}
+}
@@ -0,0 +1,194 @@
+package lsp
+
+import (
+	"encoding/json"
+	"net/url"
+	"path/filepath"
+	"strings"
+)
+
+// The parts of the protocol the editor uses. Optional fields are left out.
+
+// A place in a document. Characters are counted in UTF-16 code units, like
+// JavaScript strings.
+type Position struct {
+	Line      int `json:"line"`
+	Character int `json:"character"`
+}
+
+type Range struct {
+	Start Position `json:"start"`
+	End   Position `json:"end"`
+}
+
+type Location struct {
+	URI   string `json:"uri"`
+	Range Range  `json:"range"`
+}
+
+type TextEdit struct {
+	Range   Range  `json:"range"`
+	NewText string `json:"newText"`
+}
+
+type TextDocumentIdentifier struct {
+	URI string `json:"uri"`
+}
+
+type VersionedTextDocumentIdentifier struct {
+	URI     string `json:"uri"`
+	Version int    `json:"version"`
+}
+
+type TextDocumentItem struct {
+	URI        string `json:"uri"`
+	LanguageID string `json:"languageId"`
+	Version    int    `json:"version"`
+	Text       string `json:"text"`
+}
+
+// A change to a document. Without a range the text is the whole new content.
+type TextDocumentContentChangeEvent struct {
+	Range *Range `json:"range,omitempty"`
+	Text  string `json:"text"`
+}
+
+type TextDocumentPositionParams struct {
+	TextDocument TextDocumentIdentifier `json:"textDocument"`
+	Position     Position               `json:"position"`
+}
+
+// How severe a diagnostic is
+const (
+	SeverityError = iota + 1
+	SeverityWarning
+	SeverityInformation
+	SeverityHint
+)
+
+type Diagnostic struct {
+	Range    Range  `json:"range"`
+	Severity int    `json:"severity,omitempty"`
+	Source   string `json:"source,omitempty"`
+	Message  string `json:"message"`
+}
+
+type PublishDiagnosticsParams struct {
+	URI         string       `json:"uri"`
+	Version     *int         `json:"version,omitempty"`
+	Diagnostics []Diagnostic `json:"diagnostics"`
+}
+
+type CompletionItem struct {
+	Label      string    `json:"label"`
+	Detail     string    `json:"detail,omitempty"`
+	InsertText string    `json:"insertText,omitempty"`
+	FilterText string    `json:"filterText,omitempty"`
+	TextEdit   *TextEdit `json:"textEdit,omitempty"`
+}
+
+// Get the text a completion inserts
+func (item CompletionItem) Text() string {
+	if item.TextEdit != nil {
+		return item.TextEdit.NewText
+	}
+	if item.InsertText != "" {
+		return item.InsertText
+	}
+	return item.Label
+}
+
+// Changes to several documents, like the ones of a rename
+type WorkspaceEdit struct {
+	Changes         map[string][]TextEdit `json:"changes,omitempty"`
+	DocumentChanges []TextDocumentEdit    `json:"documentChanges,omitempty"`
+}
+
+// Edits of a document. Creating, renaming and deleting files are changes
+// of documentChanges too, they have no text document and are left out.
+type TextDocumentEdit struct {
+	TextDocument *VersionedTextDocumentIdentifier `json:"textDocument"`
+	Edits        []TextEdit                       `json:"edits"`
+}
+
+// Edits of the workspace by document URI
+func (we WorkspaceEdit) Edits() map[string][]TextEdit {
+	edits := make(map[string][]TextEdit)
+	for uri, changes := range we.Changes {
+		edits[uri] = append(edits[uri], changes...)
+	}
+	for _, change := range we.DocumentChanges {
+		if change.TextDocument != nil {
+			uri := change.TextDocument.URI
+			edits[uri] = append(edits[uri], change.Edits...)
+		}
+	}
+	return edits
+}
+
+// How the server wants documents to be synced
+const (
+	SyncNone = iota
+	SyncFull
+	SyncIncremental
+)
+
+type ServerCapabilities struct {
+	// A sync kind, or options with one in change
+	TextDocumentSync json.RawMessage `json:"textDocumentSync,omitempty"`
+}
+
+// Get how the server wants changes to documents
+func (c ServerCapabilities) SyncKind() int {
+	var kind int
+	if json.Unmarshal(c.TextDocumentSync, &kind) == nil {
+		return kind
+	}
+	var options struct {
+		Change int `json:"change"`
+	}
+	json.Unmarshal(c.TextDocumentSync, &options)
+	return options.Change
+}
+
+// Turn a file path into a file:// URI
+func PathToURI(path string) string {
+	if abs, err := filepath.Abs(path); err == nil {
+		path = abs
+	}
+	return (&url.URL{Scheme: "file", Path: filepath.ToSlash(path)}).String()
+}
+
+// Get the path of a file:// URI, empty for other URIs
+func URIToPath(uri string) string {
+	u, err := url.Parse(uri)
+	if err != nil || u.Scheme != "file" {
+		return ""
+	}
+	return filepath.FromSlash(u.Path)
+}
+
+// Get the text of hover contents, which can be markup, a string, or a
+// list of strings and code blocks
+func hoverText(contents json.RawMessage) string {
+	var markup struct {
+		Kind     string `json:"kind"`
+		Language string `json:"language"`
+		Value    string `json:"value"`
+	}
+	var s string
+	var list []json.RawMessage
+	switch {
+	case json.Unmarshal(contents, &s) == nil:
+		return s
+	case json.Unmarshal(contents, &list) == nil:
+		parts := make([]string, 0, len(list))
+		for _, part := range list {
+			parts = append(parts, hoverText(part))
+		}
+		return strings.Join(parts, "\n")
+	case json.Unmarshal(contents, &markup) == nil:
+		return markup.Value
+	}
+	return ""
+}
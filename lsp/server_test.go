@@ -0,0 +1,188 @@
+package lsp
+
+import (
+	"context"
+	"encoding/json"
+	"net"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+	"unicode/utf16"
+)
+
+// A language server for tests, running in the same program. It keeps the
+// documents it is told about in UTF-16 like servers written in JavaScript
+// do, applying the changes it gets to them, and answers from their words.
+type fakeServer struct {
+	conn *Conn
+	mu   sync.Mutex
+	docs map[string][]uint16
+}
+
+// Start a fake server and a client talking to it
+func startFake(t *testing.T, n Notifications) (*Client, *fakeServer) {
+	clientSide, serverSide := net.Pipe()
+	s := &fakeServer{docs: make(map[string][]uint16)}
+	s.conn = NewConn(serverSide, s.handle)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	c, err := NewClient(ctx, clientSide, t.TempDir(), n)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+		c.Shutdown(ctx)
+		s.conn.Close()
+	})
+	return c, s
+}
+
+// Get the text of a document the server has
+func (s *fakeServer) text(uri string) string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return string(utf16.Decode(s.docs[uri]))
+}
+
+func (s *fakeServer) handle(method string, params json.RawMessage) (any, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var p struct {
+		TextDocument struct {
+			URI  string `json:"uri"`
+			Text string `json:"text"`
+		} `json:"textDocument"`
+		ContentChanges []TextDocumentContentChangeEvent `json:"contentChanges"`
+		Position       Position                         `json:"position"`
+		NewName        string                           `json:"newName"`
+	}
+	json.Unmarshal(params, &p)
+	uri := p.TextDocument.URI
+	doc := s.docs[uri]
+
+	switch method {
+	case "initialize":
+		return map[string]any{
+			"capabilities": map[string]any{"textDocumentSync": map[string]int{"openClose": 1, "change": SyncIncremental}},
+			"serverInfo":   map[string]string{"name": "fake"},
+		}, nil
+	case "shutdown":
+		return nil, nil
+	case "textDocument/didOpen":
+		s.docs[uri] = utf16.Encode([]rune(p.TextDocument.Text))
+		s.publishDiagnostics(uri)
+	case "textDocument/didChange":
+		for _, change := range p.ContentChanges {
+			start, end := index(doc, change.Range.Start), index(doc, change.Range.End)
+			doc = append(doc[:start:start], append(utf16.Encode([]rune(change.Text)), doc[end:]...)...)
+		}
+		s.docs[uri] = doc
+		s.publishDiagnostics(uri)
+	case "textDocument/hover":
+		start, end := wordAround(doc, index(doc, p.Position))
+		return map[string]any{"contents": map[string]string{"kind": "markdown", "value": "word " + string(utf16.Decode(doc[start:end]))}}, nil
+	case "textDocument/definition":
+		locs := s.occurrences(uri, doc, p.Position)
+		if len(locs) == 0 {
+			return nil, nil
+		}
+		return locs[0], nil
+	case "textDocument/references":
+		return s.occurrences(uri, doc, p.Position), nil
+	case "textDocument/rename":
+		var edits []TextEdit
+		for _, loc := range s.occurrences(uri, doc, p.Position) {
+			edits = append(edits, TextEdit{Range: loc.Range, NewText: p.NewName})
+		}
+		return map[string]any{"documentChanges": []TextDocumentEdit{{
+			TextDocument: &VersionedTextDocumentIdentifier{URI: uri}, Edits: edits,
+		}}}, nil
+	case "textDocument/completion":
+		i := index(doc, p.Position)
+		start, _ := wordAround(doc, i)
+		prefix := string(utf16.Decode(doc[start:i]))
+		var items []CompletionItem
+		seen := map[string]bool{prefix: true}
+		for j := 0; j < len(doc); j++ {
+			ws, we := wordAround(doc, j)
+			word := string(utf16.Decode(doc[ws:we]))
+			if ws == j && strings.HasPrefix(word, prefix) && !seen[word] {
+				seen[word] = true
+				items = append(items, CompletionItem{Label: word, InsertText: word + "()"})
+			}
+		}
+		return map[string]any{"isIncomplete": false, "items": items}, nil
+	}
+	return nil, nil
+}
+
+// Tell about every TODO in a document, as a warning
+func (s *fakeServer) publishDiagnostics(uri string) {
+	doc := s.docs[uri]
+	diagnostics := []Diagnostic{}
+	for i := 0; i+4 <= len(doc); i++ {
+		if string(utf16.Decode(doc[i:i+4])) == "TODO" {
+			r := Range{Start: position(doc, i), End: position(doc, i+4)}
+			diagnostics = append(diagnostics, Diagnostic{Range: r, Severity: SeverityWarning, Message: "Something to do"})
+		}
+	}
+	s.conn.Notify("textDocument/publishDiagnostics", PublishDiagnosticsParams{URI: uri, Diagnostics: diagnostics})
+}
+
+// Find the places of the word at a position
+func (s *fakeServer) occurrences(uri string, doc []uint16, p Position) []Location {
+	start, end := wordAround(doc, index(doc, p))
+	word := string(utf16.Decode(doc[start:end]))
+	var locs []Location
+	if word == "" {
+		return locs
+	}
+	for i := 0; i < len(doc); i++ {
+		if ws, we := wordAround(doc, i); ws == i && string(utf16.Decode(doc[ws:we])) == word {
+			locs = append(locs, Location{URI: uri, Range: Range{Start: position(doc, ws), End: position(doc, we)}})
+		}
+	}
+	return locs
+}
+
+func isWordUnit(u uint16) bool {
+	return u == '_' || u >= 'a' && u <= 'z' || u >= 'A' && u <= 'Z' || u >= '0' && u <= '9'
+}
+
+// Find the start and end of the word around an index
+func wordAround(doc []uint16, i int) (int, int) {
+	start, end := i, i
+	for start > 0 && isWordUnit(doc[start-1]) {
+		start--
+	}
+	for end < len(doc) && isWordUnit(doc[end]) {
+		end++
+	}
+	return start, end
+}
+
+// Find the index of a position in a document in UTF-16
+func index(doc []uint16, p Position) int {
+	i := 0
+	for line := 0; line < p.Line && i < len(doc); i++ {
+		if doc[i] == '\n' {
+			line++
+		}
+	}
+	return min(i+p.Character, len(doc))
+}
+
+func position(doc []uint16, i int) Position {
+	var p Position
+	for _, u := range doc[:i] {
+		p.Character++
+		if u == '\n' {
+			p.Line++
+			p.Character = 0
+		}
+	}
+	return p
+}
@@ -0,0 +1,668 @@
+package main
+
+import (
+	"NutCode/editor"
+	"NutCode/rope"
+	"fmt"
+	"slices"
+	"strings"
+	"unicode/utf8"
+)
+
+// Keys bound when the editor starts, the config file can change them
+var defaultBindings = []struct{ mode, keys, action string }{
+	{"all", "<Esc>", "mode.normal"},
+	{"all", "<C-c>", "editor.quit"},
+	{"all", "<C-l>", "editor.redraw"},
+	{"all", "<C-s>", "buffer.save"},
+	{"all", "<C-PgDn>", "tab.next"},
+	{"all", "<C-PgUp>", "tab.prev"},
+	{"all", "<C-Up>", "cursor.add-above"},
+	{"all", "<C-Down>", "cursor.add-below"},
+	{"all", "<Left>", "cursor.left"},
+	{"all", "<Right>", "cursor.right"},
+	{"all", "<Up>", "cursor.up"},
+	{"all", "<Down>", "cursor.down"},
+	{"all", "<C-Left>", "cursor.word-left"},
+	{"all", "<C-Right>", "cursor.word-right"},
+	{"all", "<Home>", "cursor.home"},
+	{"all", "<End>", "cursor.line-end"},
+	{"all", "<C-Home>", "cursor.buffer-start"},
+	{"all", "<C-End>", "cursor.buffer-end"},
+	{"all", "<PgUp>", "cursor.page-up"},
+	{"all", "<PgDn>", "cursor.page-down"},
+
+	{"normal", "i", "mode.insert"},
+	{"normal", ":", "mode.command"},
+	{"normal", "v", "mode.visual"},
+	{"normal", "V", "mode.visual-line"},
+	{"normal", "<C-v>", "mode.visual-block"},
+	{"normal", "\"", "edit.register"},
+	{"normal", "u", "edit.undo"},
+	{"normal", "<C-r>", "edit.redo"},
+	{"normal", "x", "edit.delete-char"},
+	{"normal", "p", "edit.paste-after"},
+	{"normal", "P", "edit.paste-before"},
+	{"normal", "yy", "edit.yank-line"},
+	{"normal", "dd", "edit.delete-line"},
+	{"normal", ">>", "edit.indent"},
+	{"normal", "<lt><lt>", "edit.outdent"},
+	{"normal", "%", "cursor.match-bracket"},
+	{"normal", "<C-n>", "cursor.add-next-match"},
+	{"normal", "<C-g>", "jump.line"},
+	{"normal", "<C-o>", "jump.back"},
+	{"normal", "<Tab>", "jump.forward"},
+	{"normal", "m", "mark.set"},
+	{"normal", "'", "mark.line"},
+	{"normal", "`", "mark.jump"},
+	{"normal", "K", "lsp.hover"},
+	{"normal", "gd", "lsp.definition"},
+	{"normal", "gr", "lsp.references"},
+	{"normal", "<F2>", "lsp.rename"},
+	{"normal", "]d", "diagnostic.next"},
+	{"normal", "[d", "diagnostic.prev"},
+	{"normal", "gt", "tab.next"},
+	{"normal", "gT", "tab.prev"},
+	{"normal", "<F1>", "help.describe-key"},
+	{"normal", "<C-w>s", "window.split"},
+	{"normal", "<C-w>S", "window.split"},
+	{"normal", "<C-w><C-s>", "window.split"},
+	{"normal", "<C-w>v", "window.vsplit"},
+	{"normal", "<C-w><C-v>", "window.vsplit"},
+	{"normal", "<C-w>h", "window.left"},
+	{"normal", "<C-w><Left>", "window.left"},
+	{"normal", "<C-w><C-h>", "window.left"},
+	{"normal", "<C-w>j", "window.down"},
+	{"normal", "<C-w><Down>", "window.down"},
+	{"normal", "<C-w><C-j>", "window.down"},
+	{"normal", "<C-w>k", "window.up"},
+	{"normal", "<C-w><Up>", "window.up"},
+	{"normal", "<C-w><C-k>", "window.up"},
+	{"normal", "<C-w>l", "window.right"},
+	{"normal", "<C-w><Right>", "window.right"},
+	{"normal", "<C-w><C-l>", "window.right"},
+	{"normal", "<C-w>w", "window.next"},
+	{"normal", "<C-w><C-w>", "window.next"},
+	{"normal", "<C-w>c", "window.close"},
+	{"normal", "<C-w>q", "window.close"},
+	{"normal", "<C-w>o", "window.only"},
+	{"normal", "<C-w>+", "window.taller"},
+	{"normal", "<C-w>-", "window.shorter"},
+	{"normal", "<C-w>>", "window.wider"},
+	{"normal", "<C-w><lt>", "window.narrower"},
+	{"normal", "<C-w>=", "window.equalize"},
+
+	{"visual", "v", "mode.visual"},
+	{"visual", "V", "mode.visual-line"},
+	{"visual", "<C-v>", "mode.visual-block"},
+	{"visual", "%", "cursor.match-bracket"},
+	{"visual", "\"", "edit.register"},
+	{"visual", "y", "edit.yank"},
+	{"visual", "d", "edit.delete"},
+	{"visual", "x", "edit.delete"},
+	{"visual", "p", "edit.paste-after"},
+	{"visual", "P", "edit.paste-before"},
+	{"visual", "I", "edit.block-insert"},
+	{"visual", "A", "edit.block-append"},
+	{"visual", ">", "edit.indent"},
+	{"visual", "<lt>", "edit.outdent"},
+	{"visual", "<Tab>", "edit.indent"},
+	{"visual", "<S-Tab>", "edit.outdent"},
+
+	{"insert", "<BS>", "edit.backspace"},
+	{"insert", "<C-h>", "edit.backspace"},
+	{"insert", "<CR>", "edit.newline"},
+	{"insert", "<Tab>", "edit.tab"},
+	{"insert", "<S-Tab>", "edit.outdent"},
+	{"insert", "<C-v><Tab>", "edit.literal-tab"},
+	{"insert", "<C-n>", "complete.next"},
+	{"insert", "<C-p>", "complete.prev"},
+	{"insert", "<C-x><C-o>", "lsp.complete"},
+
+	{"command", "<C-c>", "editor.quit"},
+	{"command", "<Esc>", "command.cancel"},
+	{"command", "<CR>", "command.run"},
+	{"command", "<BS>", "command.backspace"},
+	{"command", "<C-h>", "command.backspace"},
+}
+
+func (a *app) registerActions() {
+	r := a.actions.Register
+	r("editor.quit", "Quit without saving", func() { a.quit = true })
+	r("editor.redraw", "Redraw the screen", func() { a.screen.Sync() })
+	r("buffer.save", "Save the buffer to its file", a.save)
+	r("help.describe-key", "Show what the next keys do", func() {
+		a.describeKey = true
+		a.message = "Press keys to describe"
+	})
+
+	r("mode.normal", "Go back to normal mode, dropping extra cursors when already there, or close the completion menu", a.normalMode)
+	r("mode.insert", "Start typing text", func() {
+		a.mode = INSERT
+		a.buf.BeginGroup()
+	})
+	r("mode.command", "Type a command", func() { a.promptFor(":", nil) })
+	r("mode.visual", "Select characters", func() { a.visualMode(VISUAL) })
+	r("mode.visual-line", "Select lines", func() { a.visualMode(VISUAL_LINE) })
+	r("mode.visual-block", "Select a block, or stop selecting it", func() {
+		if a.mode == VISUAL_BLOCK {
+			a.mode = NORMAL
+			return
+		}
+		a.visualMode(VISUAL_BLOCK)
+	})
+
+	r("command.run", "Run the command typed", a.runCommandLine)
+	r("command.cancel", "Leave the command line", func() { a.mode = NORMAL })
+	r("command.backspace", "Delete the last character of the command, or leave the command line when empty", func() {
+		if a.commandLine == "" {
+			a.mode = NORMAL
+			return
+		}
+		_, size := utf8.DecodeLastRuneInString(a.commandLine)
+		a.commandLine = a.commandLine[:len(a.commandLine)-size]
+	})
+
+	r("cursor.left", "Move the cursors left", func() { a.move(editor.Left) })
+	r("cursor.right", "Move the cursors right", func() { a.move(editor.Right) })
+	r("cursor.up", "Move the cursors up", func() { a.moveLines(-1) })
+	r("cursor.down", "Move the cursors down", func() { a.moveLines(1) })
+	r("cursor.word-left", "Move the cursors to the start of the word on the left", func() {
+		a.move(editor.PrevWord(a.buf.Options.WordChars))
+	})
+	r("cursor.word-right", "Move the cursors to the start of the next word", func() {
+		a.move(editor.NextWord(a.buf.Options.WordChars))
+	})
+	r("cursor.home", "Move the cursors to the first non-blank character of the line, or to its start", func() {
+		a.move(editor.SmartHome)
+	})
+	r("cursor.line-start", "Move the cursors to the start of the line", func() { a.move(editor.LineStart) })
+	r("cursor.line-end", "Move the cursors to the end of the line", func() { a.move(editor.LineEnd) })
+	r("cursor.buffer-start", "Move the cursor to the start of the buffer", func() {
+		a.pushJump()
+		a.ew.ClearCursors()
+		// Set rather than moved to, large buffers only have the text
+		// around the cursor
+		a.ew.Cursor.Set(0)
+		a.ew.ForgetColumns()
+	})
+	r("cursor.buffer-end", "Move the cursor to the end of the buffer", func() {
+		a.pushJump()
+		a.ew.ClearCursors()
+		a.ew.Cursor.Set(a.buf.Content.Len())
+		a.ew.ForgetColumns()
+	})
+	r("cursor.match-bracket", "Jump to the bracket matching the one under the cursor, or after it on the line", func() {
+		a.pushJump()
+		a.move(a.matchBracket)
+	})
+	r("cursor.page-up", "Scroll up a page, moving the cursors along", func() { a.page(-1) })
+	r("cursor.page-down", "Scroll down a page, moving the cursors along", func() { a.page(1) })
+	r("jump.line", "Go to a line, or line:column, typed at a prompt", func() {
+		a.promptFor("Go to line: ", func(text string) error {
+			line, col, ok := parseLocation(text)
+			if !ok {
+				return fmt.Errorf("Not a line number: %s", text)
+			}
+			a.goTo(line, col)
+			return nil
+		})
+	})
+	r("mark.set", "Put the mark named by the next key at the cursor", func() {
+		a.readChar = func(r rune) {
+			if !editor.ValidMark(r) {
+				a.message, a.messageIsError = "Marks are named a to z", true
+				return
+			}
+			a.buf.SetMark(r, a.ew.Cursor.Offset())
+		}
+	})
+	r("mark.line", "Jump to the line of the mark named by the next key", func() {
+		a.readChar = func(r rune) { a.jumpToMark(r, true) }
+	})
+	r("mark.jump", "Jump to the mark named by the next key", func() {
+		a.readChar = func(r rune) { a.jumpToMark(r, false) }
+	})
+	r("jump.back", "Go back to where the cursor jumped from", func() {
+		if j, ok := a.ew.Jumps.Back(a.buf, a.ew.Cursor.Offset()); ok {
+			a.followJump(j)
+		}
+	})
+	r("jump.forward", "Go forth again in the jump list, Ctrl+I is the same key as Tab", func() {
+		if j, ok := a.ew.Jumps.Forward(); ok {
+			a.followJump(j)
+		}
+	})
+	r("cursor.add-above", "Add a cursor on the line above", func() {
+		addCursorVertical(a.ew, false)
+	})
+	r("cursor.add-below", "Add a cursor on the line below", func() {
+		addCursorVertical(a.ew, true)
+	})
+	r("cursor.add-next-match", "Add a cursor at the next match of the word under the cursor", func() {
+		addCursorAtNextMatch(a.ew, a.buf.Options.WordChars)
+	})
+
+	r("edit.register", "Use the register named by the next key", func() {
+		a.readChar = func(r rune) {
+			if editor.ValidRegister(r) {
+				a.register = r
+			}
+		}
+	})
+	r("edit.undo", "Undo the last change", a.undo)
+	r("edit.redo", "Redo the last change undone", a.redo)
+	r("edit.delete-char", "Delete the character under the cursors", a.deleteChar)
+	r("edit.paste-after", "Paste after the cursor, or over the selection", func() { a.paste(true) })
+	r("edit.paste-before", "Paste before the cursor, or over the selection", func() { a.paste(false) })
+	r("edit.yank-line", "Copy the current line", func() {
+		start, end := a.cursorLine()
+		yankRegion(a.registers, a.register, a.buf, start, end, true)
+	})
+	r("edit.delete-line", "Cut the current line", func() {
+		start, end := a.cursorLine()
+		a.ew.Cursor.Set(deleteRegion(a.buf, a.registers, a.register, a.ew.Cursor.Offset(), start, end, true))
+		a.ew.ClearCursors()
+		a.afterEdit()
+	})
+	r("edit.yank", "Copy the selection", func() { a.cutSelection(false) })
+	r("edit.delete", "Cut the selection", func() { a.cutSelection(true) })
+	r("edit.block-insert", "Type before every line of the block", func() { a.blockInsert(false) })
+	r("edit.block-append", "Type after every line of the block", func() { a.blockInsert(true) })
+	r("edit.backspace", "Delete the character before the cursors, or an indent level", func() {
+		a.backspace()
+		a.updateCompletion()
+	})
+	r("edit.newline", "Start a new line, indented like the current one, or take the completion selected", func() {
+		if a.acceptCompletion() {
+			return
+		}
+		colonOpens := slices.Contains([]string{"python", "yaml"}, strings.ToLower(syntaxName(a.buf)))
+		editsAtCursors(a.buf, a.ew, func(at int) (rope.Transaction, int) {
+			return editor.Newline(a.cachedContent, at, a.buf.Options, colonOpens), 0
+		})
+		a.afterEdit()
+	})
+	r("edit.tab", "Insert a tab, or spaces up to the next indent stop with expandTab, or take the completion selected", func() {
+		if a.acceptCompletion() {
+			return
+		}
+		editAtCursors(a.buf, a.ew, func(at int) (rope.Edit, bool) {
+			return rope.Edit{Offset: at, Text: editor.TabText(a.cachedContent, at, a.buf.Options)}, true
+		})
+		a.afterEdit()
+	})
+	r("edit.literal-tab", "Insert a tab character, even with expandTab", func() { a.insert("\t") })
+	r("complete.next", "Complete the word before the cursor from the open buffers, or select the next completion", func() { a.complete(1) })
+	r("complete.prev", "Complete the word before the cursor from the open buffers, or select the previous completion", func() { a.complete(-1) })
+	r("lsp.complete", "Complete the word before the cursor with the language server", a.completeFromServer)
+	r("lsp.hover", "Show the documentation of what is under the cursor, from the language server", a.hover)
+	r("lsp.definition", "Jump to the definition of what is under the cursor", a.definition)
+	r("lsp.references", "Pick from the places that refer to what is under the cursor", a.references)
+	r("lsp.rename", "Rename what is under the cursor everywhere, to a name typed at a prompt", func() {
+		a.promptFor("Rename to: ", func(text string) error {
+			a.rename(strings.TrimSpace(text))
+			return nil
+		})
+		// Starting from the name it has now
+		start, end := wordBounds(a.cachedContent, a.ew.Cursor.Offset()-a.cachedStart, a.buf.Options.WordChars)
+		a.commandLine = a.cachedContent[start:end]
+	})
+	r("diagnostic.next", "Go to the next problem the language server found", func() { a.nextDiagnostic(true) })
+	r("diagnostic.prev", "Go to the previous problem the language server found", func() { a.nextDiagnostic(false) })
+	r("edit.indent", "Indent the lines of the cursors or of the selection", func() { a.indent(false) })
+	r("edit.outdent", "Outdent the lines of the cursors or of the selection", func() { a.indent(true) })
+
+	r("tab.next", "Go to the next tab page", func() {
+		a.goToTab((a.tabs.Index()+1)%len(a.tabs.Tabs()) + 1)
+	})
+	r("tab.prev", "Go to the previous tab page", func() {
+		a.goToTab((a.tabs.Index()-1+len(a.tabs.Tabs()))%len(a.tabs.Tabs()) + 1)
+	})
+
+	window := func(f func(l *editor.Layout) error) func() {
+		return func() {
+			if err := f(a.tabs.Current()); err != nil {
+				a.message = err.Error()
+				a.messageIsError = true
+			}
+			a.switchWindow()
+		}
+	}
+	r("window.split", "Split the window in two, one above the other", window(func(l *editor.Layout) error {
+		l.Split(false)
+		return nil
+	}))
+	r("window.vsplit", "Split the window in two, side by side", window(func(l *editor.Layout) error {
+		l.Split(true)
+		return nil
+	}))
+	for _, dir := range []struct {
+		name, where string
+		direction   int
+	}{{"left", "on the left", editor.LEFT}, {"down", "below", editor.DOWN}, {"up", "above", editor.UP}, {"right", "on the right", editor.RIGHT}} {
+		r("window."+dir.name, "Go to the window "+dir.where, window(func(l *editor.Layout) error {
+			l.Focus(dir.direction)
+			return nil
+		}))
+	}
+	r("window.next", "Go to the next window", window(func(l *editor.Layout) error {
+		l.FocusNext()
+		return nil
+	}))
+	r("window.close", "Close the window", window(func(l *editor.Layout) error {
+		return l.Close()
+	}))
+	r("window.only", "Close all other windows", window(func(l *editor.Layout) error {
+		l.Only()
+		return nil
+	}))
+	resize := func(delta int, vertical bool) func() {
+		return window(func(l *editor.Layout) error {
+			l.Resize(delta, vertical)
+			return nil
+		})
+	}
+	r("window.taller", "Make the window taller", resize(1, false))
+	r("window.shorter", "Make the window shorter", resize(-1, false))
+	r("window.wider", "Make the window wider", resize(1, true))
+	r("window.narrower", "Make the window narrower", resize(-1, true))
+	r("window.equalize", "Make all windows the same size", window(func(l *editor.Layout) error {
+		l.Equalize()
+		return nil
+	}))
+}
+
+// Type text at the cursors
+func (a *app) insert(text string) {
+	insertAtCursors(a.buf, a.ew, text)
+	a.afterEdit()
+}
+
+// Type a character at the cursors. A closer typed first on a line outdents
+// it, and brackets and quotes are typed in pairs with autoPairs.
+func (a *app) typeText(text string) {
+	editsAtCursors(a.buf, a.ew, func(at int) (rope.Transaction, int) {
+		if t, move, ok := editor.AutoPair(a.cachedContent, at, text, a.buf.Options); ok {
+			return t, move
+		}
+		t := rope.Transaction{{Offset: at, Text: text}}
+		if e, ok := editor.OutdentCloser(a.cachedContent, at, text, a.buf.Options); ok {
+			t = append(t, e)
+		}
+		return t, 0
+	})
+	a.afterEdit()
+}
+
+// Indent the lines of the selection one level, or of every cursor outside
+// visual mode. Outdents them instead when outdent is true.
+func (a *app) indent(outdent bool) {
+	content, base, c := a.cachedContent, a.cachedStart, a.ew.Cursor.Offset()
+	var t rope.Transaction
+	switch a.mode {
+	case VISUAL, VISUAL_LINE, VISUAL_BLOCK:
+		start, end := a.visualRegion()
+		t = editor.IndentLines(content, start-base, end-base, a.buf.Options, outdent)
+		a.mode = NORMAL
+	default:
+		lines := make(map[int]bool)
+		for _, at := range append([]int{c}, a.ew.Cursors()...) {
+			at -= base
+			start, _ := editor.LineBounds(content, at)
+			if !lines[start] {
+				lines[start] = true
+				t = append(t, editor.IndentLines(content, at, at, a.buf.Options, outdent)...)
+			}
+		}
+	}
+	if len(t) == 0 {
+		return
+	}
+	t = t.Shift(base)
+	a.buf.Apply(t, c)
+	a.afterEdit()
+}
+
+func (a *app) save() {
+	if err := a.buf.Save(""); err != nil {
+		a.message = "Error writing to file: " + err.Error()
+		a.messageIsError = true
+	}
+	// Saving can clean up whitespace
+	a.afterEdit()
+}
+
+// Open the command line with a prompt, onPrompt runs the text typed instead
+// of an editor command
+func (a *app) promptFor(prompt string, onPrompt func(text string) error) {
+	a.mode = COMMAND
+	a.commandLine = ""
+	a.prompt = prompt
+	a.onPrompt = onPrompt
+}
+
+func (a *app) runCommandLine() {
+	a.mode = NORMAL
+	if a.onPrompt != nil {
+		if err := a.onPrompt(a.commandLine); err != nil {
+			a.message = err.Error()
+			a.messageIsError = true
+		}
+		return
+	}
+	msg, quit, err := runCommand(a.commandLine, a)
+	if err != nil {
+		a.message = err.Error()
+		a.messageIsError = true
+	} else if quit {
+		a.quit = true
+		return
+	} else {
+		a.message = msg
+	}
+	a.switchWindow()
+}
+
+// Back to normal mode, dropping extra cursors when already in it. Closes
+// the completion menu instead when it is open.
+func (a *app) normalMode() {
+	if a.ew.Menu != nil {
+		a.ew.Menu = nil
+		return
+	}
+	if a.mode == NORMAL {
+		a.ew.ClearCursors()
+	}
+	if a.mode == INSERT {
+		a.buf.EndGroup()
+	}
+	a.mode = NORMAL
+}
+
+// Start selecting, or switch to another kind of selection
+func (a *app) visualMode(mode int) {
+	if a.mode == NORMAL {
+		a.anchor = a.ew.Cursor.Offset()
+	}
+	a.mode = mode
+}
+
+func (a *app) undo() {
+	if offset, ok := a.buf.Undo(); ok {
+		a.ew.Cursor.Set(offset)
+		a.ew.ClearCursors()
+		a.afterEdit()
+	} else {
+		a.message = "Already at oldest change"
+	}
+}
+
+func (a *app) redo() {
+	if offset, ok := a.buf.Redo(); ok {
+		a.ew.Cursor.Set(offset)
+		a.ew.ClearCursors()
+		a.afterEdit()
+	} else {
+		a.message = "Already at newest change"
+	}
+}
+
+func (a *app) deleteChar() {
+	content, c := a.cachedContent, a.ew.Cursor.Offset()-a.cachedStart
+	// Length of the character at an offset, 0 at the end of a line
+	charLen := func(at int) int {
+		if at >= len(content) || content[at] == '\n' {
+			return 0
+		}
+		_, size := utf8.DecodeRuneInString(content[at:])
+		return size
+	}
+	// The characters of every cursor go in the register, a line for each
+	// like the text of a block
+	offsets := []int{c}
+	for _, at := range a.ew.Cursors() {
+		offsets = append(offsets, at-a.cachedStart)
+	}
+	slices.Sort(offsets)
+	var deleted []string
+	for _, at := range offsets {
+		if n := charLen(at); n > 0 {
+			deleted = append(deleted, content[at:at+n])
+		}
+	}
+	if len(deleted) > 0 {
+		a.registers.Delete(a.register, editor.Register{Content: strings.Join(deleted, "\n")})
+	}
+	editAtCursors(a.buf, a.ew, func(at int) (rope.Edit, bool) {
+		n := charLen(at)
+		return rope.Edit{Offset: at, Length: n}, n > 0
+	})
+	a.afterEdit()
+}
+
+// Paste the register at the cursor, or over the selection in visual mode
+func (a *app) paste(after bool) {
+	reg, ok := a.registers.Get(a.register)
+	switch a.mode {
+	case NORMAL:
+		if ok {
+			a.ew.Cursor.Set(pasteRegister(a.buf, a.ew.Cursor.Offset(), reg, after))
+			a.ew.ClearCursors()
+			a.afterEdit()
+		}
+	case VISUAL, VISUAL_LINE:
+		if ok {
+			start, end := a.visualRegion()
+			a.buf.BeginGroup()
+			a.ew.Cursor.Set(deleteRegion(a.buf, a.registers, editor.BlackHoleRegister, a.ew.Cursor.Offset(), start, end, a.mode == VISUAL_LINE))
+			a.ew.Cursor.Set(pasteRegister(a.buf, a.ew.Cursor.Offset(), reg, false))
+			a.buf.EndGroup()
+			a.afterEdit()
+		}
+		a.mode = NORMAL
+		a.ew.ClearCursors()
+	case VISUAL_BLOCK:
+		if ok {
+			a.pasteOverBlock(reg)
+		}
+		a.mode = NORMAL
+		a.ew.ClearCursors()
+	}
+}
+
+// Replace every line of the block selection with the register, or with one
+// line of it each when it has as many lines as the block, like after
+// yanking a block of the same size
+func (a *app) pasteOverBlock(reg editor.Register) {
+	regions := a.blockRegions()
+	lines := strings.Split(reg.Content, "\n")
+	if reg.Linewise || len(lines) > 1 && len(lines) != len(regions) {
+		a.message = "Can only paste one line, or one line for each line of the block"
+		a.messageIsError = true
+		return
+	}
+	var t rope.Transaction
+	for i, region := range regions {
+		line := lines[0]
+		if len(lines) > 1 {
+			line = lines[i]
+		}
+		t = append(t, rope.Edit{Offset: region[0], Length: region[1] - region[0], Text: line})
+	}
+	a.buf.Apply(t, a.ew.Cursor.Offset())
+	a.ew.Cursor.Set(regions[0][0])
+	a.afterEdit()
+}
+
+// Copy the selection into the register, and delete it when cutting
+func (a *app) cutSelection(cut bool) {
+	c := a.ew.Cursor.Offset()
+	if a.mode == VISUAL_BLOCK {
+		regions := a.blockRegions()
+		reg := editor.Register{Content: blockText(a.buf, regions)}
+		if cut {
+			a.registers.Delete(a.register, reg)
+			var t rope.Transaction
+			for _, region := range regions {
+				t = append(t, rope.Edit{Offset: region[0], Length: region[1] - region[0]})
+			}
+			a.buf.Apply(t, c)
+			a.afterEdit()
+		} else {
+			a.registers.Yank(a.register, reg)
+		}
+		a.ew.Cursor.Set(regions[0][0])
+		a.mode = NORMAL
+		return
+	}
+	linewise := a.mode == VISUAL_LINE
+	start, end := a.visualRegion()
+	if cut {
+		a.ew.Cursor.Set(deleteRegion(a.buf, a.registers, a.register, c, start, end, linewise))
+		a.afterEdit()
+	} else {
+		yankRegion(a.registers, a.register, a.buf, start, end, linewise)
+		a.ew.Cursor.Set(start)
+	}
+	a.mode = NORMAL
+	a.ew.ClearCursors()
+}
+
+// Type on every line of the block selection, before or after it
+func (a *app) blockInsert(after bool) {
+	if a.mode != VISUAL_BLOCK {
+		return
+	}
+	regions := a.blockRegions()
+	cursorsFromBlock(a.ew, regions, after)
+	a.mode = INSERT
+	a.buf.BeginGroup()
+}
+
+// Delete the character before the cursors, or back to the previous indent
+// stop in the spaces of an indent. An empty pair typed with autoPairs goes
+// at once.
+func (a *app) backspace() {
+	ew, buf, base := a.ew, a.buf, a.cachedStart
+	editAtCursors(buf, ew, func(at int) (rope.Edit, bool) {
+		if at == 0 {
+			// Nothing to delete before the start of the buffer. At the
+			// start of the text loaded in a large buffer, the character
+			// before is read from the content.
+			if base == 0 {
+				return rope.Edit{}, false
+			}
+			_, size := utf8.DecodeLastRuneInString(buf.Content.Slice(max(base-utf8.UTFMax, 0), base))
+			return rope.Edit{Offset: -size, Length: size}, true
+		}
+		if editor.InEmptyPair(a.cachedContent, at, buf.Options) {
+			_, before := utf8.DecodeLastRuneInString(a.cachedContent[:at])
+			_, after := utf8.DecodeRuneInString(a.cachedContent[at:])
+			return rope.Edit{Offset: at - before, Length: before + after}, true
+		}
+		start := editor.BackspaceStart(a.cachedContent, at, buf.Options)
+		return rope.Edit{Offset: start, Length: at - start}, start < at
+	})
+	a.afterEdit()
+}
@@ -0,0 +1,238 @@
+package main
+
+import (
+	"NutCode/editor"
+	"errors"
+	"fmt"
+
+	"github.com/gdamore/tcell/v2"
+)
+
+// Everything the editor keeps track of between key presses
+type app struct {
+	screen    tcell.Screen
+	buffers   *editor.BufferList
+	tabs      *editor.TabPages
+	ew        *editor.EditorWindow
+	buf       *editor.Buffer
+	registers *editor.Registers
+	cfg       *editor.Config
+	actions   *editor.Actions
+	keymap    *editor.Keymap
+
+	mode int
+	// Text typed on the command line, and the message shown after running it
+	commandLine    string
+	message        string
+	messageIsError bool
+	// Shown before the command line, and what runs the text typed at it,
+	// nil for editor commands
+	prompt   string
+	onPrompt func(text string) error
+	// State of a normal mode command being typed. readChar takes the next
+	// key, which names something like a register or a mark.
+	register rune
+	readChar func(r rune)
+	// Finds the completions of the menu that is open
+	completer func(prefix string) (items, texts []string)
+	// Runs the item picked from the menu, which takes the keys while set
+	picker func(i int)
+	// Language servers of the open buffers
+	servers *languageServers
+	// Keys typed so far of a binding made of several keys
+	pendingKeys   string
+	pendingEvents []*tcell.EventKey
+	// The next keys are described instead of being run
+	describeKey bool
+	// Where the visual selection started
+	anchor int
+	// Text of the buffer around the cursors, from cachedStart, see
+	// Buffer.LoadAround
+	cachedContent string
+	cachedStart   int
+	quit          bool
+}
+
+// Refresh everything derived from the content after an edit
+func (a *app) afterEdit() {
+	a.loadText()
+	a.ew.ForgetColumns()
+}
+
+// Keep the text around the cursors and the selection, loading it first in
+// large buffers
+func (a *app) loadText() {
+	first, last := a.ew.Cursor.Offset(), a.ew.Cursor.Offset()
+	for _, offset := range a.ew.Cursors() {
+		first, last = min(first, offset), max(last, offset)
+	}
+	if a.mode == VISUAL || a.mode == VISUAL_LINE || a.mode == VISUAL_BLOCK {
+		first, last = min(first, a.anchor), max(last, a.anchor)
+	}
+	a.buf.LoadAround(first, last)
+	a.cachedContent, a.cachedStart = a.buf.Text(), a.buf.TextStart()
+}
+
+// Continue in the window that has the focus now
+func (a *app) switchWindow() {
+	a.ew = a.tabs.Current().Current()
+	a.buf = a.ew.Buffer
+	a.buffers.Show(a.buf.ID)
+	a.afterEdit()
+}
+
+// Go to a tab, counting from 1. Typing goes on in the new tab, the
+// selection is dropped.
+func (a *app) goToTab(n int) {
+	if a.mode == INSERT {
+		a.buf.EndGroup()
+	}
+	a.tabs.Go(n)
+	a.switchWindow()
+	if a.mode == INSERT {
+		a.buf.BeginGroup()
+	} else if a.mode != COMMAND {
+		a.mode = NORMAL
+	}
+}
+
+func (a *app) draw() {
+	a.loadText()
+	ew := a.ew
+	if a.mode == VISUAL || a.mode == VISUAL_LINE {
+		ew.SetSelection(a.visualRegion())
+	} else if a.mode == VISUAL_BLOCK {
+		ew.ClearSelection()
+		for _, region := range a.blockRegions() {
+			ew.AddSelection(region[0], region[1])
+		}
+	} else {
+		ew.ClearSelection()
+	}
+	a.tabs.Draw(a.mode)
+	a.screen.SetCursorStyle(editor.CursorStyles[a.buf.Options.CursorStyle])
+	if a.mode == COMMAND {
+		ew.DrawCommandLine(a.prompt + a.commandLine)
+	} else if a.message != "" {
+		ew.DrawMessage(a.message, a.messageIsError)
+	} else if message, isError := a.diagnosticMessage(); message != "" && a.mode == NORMAL {
+		// What is wrong where the cursor is
+		ew.DrawMessage(message, isError)
+	}
+}
+
+// Modes whose key bindings are used, the first binding found wins
+func (a *app) keymapModes() []string {
+	switch a.mode {
+	case INSERT:
+		return []string{"insert", "all"}
+	case COMMAND:
+		return []string{"command"}
+	case VISUAL, VISUAL_LINE, VISUAL_BLOCK:
+		return []string{"visual", "all"}
+	}
+	return []string{"normal", "all"}
+}
+
+// Run the action bound to the keys typed. Keys without a binding type text
+// in insert mode and on the command line.
+func (a *app) handleKey(ev *tcell.EventKey) {
+	a.message = ""
+	a.messageIsError = false
+	if a.readChar != nil {
+		readChar := a.readChar
+		a.readChar = nil
+		if ev.Key() == tcell.KeyRune {
+			readChar(ev.Rune())
+		}
+		return
+	}
+	if a.picker != nil && a.pickKey(ev) {
+		return
+	}
+
+	keys := a.pendingKeys + editor.KeyName(ev)
+	name, prefix := a.keymap.Lookup(a.keymapModes(), keys)
+	if prefix {
+		a.pendingKeys = keys
+		a.pendingEvents = append(a.pendingEvents, ev)
+		return
+	}
+	pending := a.pendingEvents
+	a.pendingKeys = ""
+	a.pendingEvents = nil
+	if a.describeKey {
+		a.describeKey = false
+		a.message = a.describe(keys, name)
+		return
+	}
+	if name == "" {
+		if len(pending) > 0 {
+			if a.mode == INSERT || a.mode == COMMAND {
+				// The keys were text after all
+				for _, ev := range pending {
+					a.typeKey(ev)
+				}
+				a.handleKey(ev)
+			}
+			// Otherwise a half typed binding is dropped
+			return
+		}
+		a.typeKey(ev)
+		return
+	}
+
+	a.keepCompletion(name)
+	a.actions.Get(name).Run()
+	if a.readChar != nil {
+		return
+	}
+	a.register = editor.UnnamedRegister
+}
+
+// Type the character of a key in insert mode or on the command line
+func (a *app) typeKey(ev *tcell.EventKey) {
+	if ev.Key() != tcell.KeyRune {
+		return
+	}
+	switch a.mode {
+	case INSERT:
+		a.typeText(string(ev.Rune()))
+		a.updateCompletion()
+	case COMMAND:
+		a.commandLine += string(ev.Rune())
+	}
+}
+
+// Tell what keys do
+func (a *app) describe(keys, name string) string {
+	if name == "" {
+		return fmt.Sprintf("%s is not bound", keys)
+	}
+	return fmt.Sprintf("%s runs %s: %s", keys, name, a.actions.Get(name).Description)
+}
+
+// Set up the default key bindings, and the ones of the config file
+func (a *app) bindKeys() error {
+	a.keymap = editor.NewKeymap()
+	for _, b := range defaultBindings {
+		if err := a.keymap.Bind(b.mode, b.keys, b.action); err != nil {
+			panic(err)
+		}
+	}
+	errs := []error{}
+	for _, b := range a.cfg.Keys {
+		if err := a.bind(b); err != nil {
+			errs = append(errs, fmt.Errorf("keys.%s: %w", b.Mode, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Bind keys to an action, checking that the action exists
+func (a *app) bind(b editor.Binding) error {
+	if b.Action != "" && a.actions.Get(b.Action) == nil {
+		return fmt.Errorf("Unknown action: %s", b.Action)
+	}
+	return a.keymap.Bind(b.Mode, b.Keys, b.Action)
+}
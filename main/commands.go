@@ -0,0 +1,468 @@
+package main
+
+import (
+	"NutCode/editor"
+	"NutCode/syntax"
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// Run a line typed on the command line (without the leading ':').
+// Returns a message to show and whether the editor should quit.
+func runCommand(line string, a *app) (string, bool, error) {
+	buffers, tabs := a.buffers, a.tabs
+	msg, quit, err := runBufferCommand(line, a)
+	// Show the buffer picked by the command, and replace closed buffers
+	tabs.Current().Current().ShowBuffer(buffers.Current())
+	for _, ew := range tabs.Windows() {
+		if !slices.Contains(buffers.Buffers(), ew.Buffer) {
+			ew.ShowBuffer(buffers.Current())
+		}
+		ew.Jumps.Keep(buffers.Buffers())
+	}
+	return msg, quit, err
+}
+
+func runBufferCommand(line string, a *app) (string, bool, error) {
+	buffers, tabs, cfg := a.buffers, a.tabs, a.cfg
+	name, arg, _ := strings.Cut(strings.TrimSpace(line), " ")
+	arg = strings.TrimSpace(arg)
+	force := strings.HasSuffix(name, "!")
+	name = strings.TrimSuffix(name, "!")
+	buf := buffers.Current()
+	layout := tabs.Current()
+
+	switch name {
+	case "":
+		return "", false, nil
+	case "w", "write":
+		if err := buf.Save(arg); err != nil {
+			return "", false, err
+		}
+		return fmt.Sprintf("\"%s\" written", buf.Name()), false, nil
+	case "q", "quit":
+		if len(layout.Windows()) > 1 {
+			return "", false, closeWindow(layout, buffers)
+		}
+		if len(tabs.Tabs()) > 1 {
+			return "", false, closeTab(tabs, buffers)
+		}
+		fallthrough
+	case "qa", "qall", "quitall":
+		if !force {
+			if b := buffers.Modified(); b != nil {
+				return "", false, fmt.Errorf("No write since last change for buffer %d (add ! to override)", b.ID)
+			}
+		}
+		return "", true, nil
+	case "wq", "x":
+		if err := buf.Save(arg); err != nil {
+			return "", false, err
+		}
+		return runBufferCommand("quit"+bang(force), a)
+	case "e", "edit":
+		if arg == "" {
+			return "", false, errors.New("Argument required")
+		}
+		b, err := buffers.Open(arg)
+		if err != nil {
+			return "", false, err
+		}
+		return fmt.Sprintf("\"%s\"", b.Name()), false, nil
+	case "sp", "split", "vs", "vsplit", "new", "vnew":
+		layout.Split(name[0] == 'v')
+		if name == "new" || name == "vnew" {
+			buffers.Add(editor.NewBufferFromString("", ""))
+		} else if arg != "" {
+			if _, err := buffers.Open(arg); err != nil {
+				return "", false, err
+			}
+		} else {
+			// The new window shows the same buffer
+			buffers.Show(layout.Current().Buffer.ID)
+		}
+		return "", false, nil
+	case "clo", "close":
+		return "", false, closeWindow(layout, buffers)
+	case "on", "only":
+		layout.Only()
+		return "", false, nil
+	case "bn", "bnext":
+		buffers.Next()
+		return "", false, nil
+	case "bp", "bprevious", "bprev":
+		buffers.Prev()
+		return "", false, nil
+	case "b", "buffer":
+		id, err := bufferID(arg, buf)
+		if err != nil {
+			return "", false, err
+		}
+		return "", false, buffers.Show(id)
+	case "bd", "bdelete":
+		id, err := bufferID(arg, buf)
+		if err != nil {
+			return "", false, err
+		}
+		return "", false, buffers.Delete(id, force)
+	case "ls", "buffers":
+		return listBuffers(buffers), false, nil
+	case "se", "set":
+		msg, err := setOption(arg, buf, cfg)
+		return msg, false, err
+	case "config":
+		switch arg {
+		case "":
+			return configPath("config.toml"), false, nil
+		case "reload":
+			if err := reloadConfig(a); err != nil {
+				return "", false, err
+			}
+			return "Config reloaded", false, nil
+		}
+		return "", false, fmt.Errorf("Unknown config command: %s", arg)
+	case "map":
+		return mapKeys(arg, a)
+	case "unm", "unmap":
+		mode, keys, _ := strings.Cut(arg, " ")
+		if err := a.keymap.Bind(mode, strings.TrimSpace(keys), ""); err != nil {
+			return "", false, err
+		}
+		return "", false, nil
+	case "colo", "colorscheme":
+		if arg == "" {
+			return describeTheme(), false, nil
+		}
+		t, err := findTheme(arg)
+		if err != nil {
+			return "", false, err
+		}
+		editor.SetTheme(t)
+		return "", false, nil
+	case "tabnew", "tabe", "tabedit":
+		if arg == "" {
+			buffers.Add(editor.NewBufferFromString("", ""))
+		} else if _, err := buffers.Open(arg); err != nil {
+			return "", false, err
+		}
+		tabs.New(buffers.Current())
+		return "", false, nil
+	case "tabc", "tabclose":
+		return "", false, closeTab(tabs, buffers)
+	case "tabo", "tabonly":
+		tabs.Only()
+		return "", false, nil
+	case "tabn", "tabnext":
+		if arg == "" {
+			tabs.Next()
+		} else if n, err := strconv.Atoi(arg); err != nil {
+			return "", false, fmt.Errorf("Invalid tab page number: %s", arg)
+		} else if err := tabs.Go(n); err != nil {
+			return "", false, err
+		}
+		return "", false, showTab(tabs, buffers)
+	case "tabp", "tabprevious", "tabN", "tabNext":
+		tabs.Prev()
+		return "", false, showTab(tabs, buffers)
+	case "tabfir", "tabfirst", "tabr", "tabrewind":
+		tabs.Go(1)
+		return "", false, showTab(tabs, buffers)
+	case "tabl", "tablast":
+		tabs.Go(len(tabs.Tabs()))
+		return "", false, showTab(tabs, buffers)
+	case "tabm", "tabmove":
+		to, err := tabPosition(arg, tabs)
+		if err != nil {
+			return "", false, err
+		}
+		tabs.Move(to)
+		return "", false, nil
+	case "tabs":
+		return listTabs(tabs), false, nil
+	case "lsp":
+		switch arg {
+		case "":
+			return a.servers.status(), false, nil
+		case "restart":
+			a.servers.restart()
+			return "Restarting language servers", false, nil
+		}
+		return "", false, fmt.Errorf("Unknown lsp command: %s", arg)
+	case "hover":
+		a.hover()
+		return "", false, nil
+	case "def", "definition":
+		a.definition()
+		return "", false, nil
+	case "refs", "references":
+		a.references()
+		return "", false, nil
+	case "rename":
+		a.rename(arg)
+		return "", false, nil
+	case "diagnostics":
+		return "", false, a.listDiagnostics()
+	case "marks":
+		if len(buf.MarkNames()) == 0 {
+			return "No marks set", false, nil
+		}
+		return listMarks(buf), false, nil
+	case "delm", "delmarks":
+		return "", false, deleteMarks(arg, buf, force)
+	}
+	if line, col, ok := parseLocation(name); ok {
+		a.goTo(line, col)
+		return "", false, nil
+	}
+	return "", false, fmt.Errorf("Not an editor command: %s", line)
+}
+
+// Read a line number, or line:column like in compiler messages
+func parseLocation(s string) (line, col int, ok bool) {
+	lineText, colText, hasCol := strings.Cut(s, ":")
+	line, err := strconv.Atoi(lineText)
+	if err != nil || line < 0 || lineText[0] == '+' {
+		return 0, 0, false
+	}
+	if hasCol {
+		if col, err = strconv.Atoi(colText); err != nil || col < 0 || colText[0] == '+' {
+			return 0, 0, false
+		}
+	}
+	return line, col, true
+}
+
+// Close the current window, the buffer of the window getting the focus becomes current
+func closeWindow(layout *editor.Layout, buffers *editor.BufferList) error {
+	if err := layout.Close(); err != nil {
+		return err
+	}
+	return buffers.Show(layout.Current().Buffer.ID)
+}
+
+// Bind keys with "mode keys action", or describe the bindings of keys.
+// Without keys all bindings are listed in a new buffer.
+func mapKeys(arg string, a *app) (string, bool, error) {
+	fields := strings.Fields(arg)
+	switch len(fields) {
+	case 0:
+		b := editor.NewBufferFromString("", describeKeymap(a.keymap, a.actions))
+		b.Title = "[Keymap]"
+		a.buffers.Add(b)
+		return "", false, nil
+	case 1:
+		keys, err := editor.ParseKeys(fields[0])
+		if err != nil {
+			return "", false, err
+		}
+		lines := []string{}
+		for _, b := range a.keymap.Bindings("") {
+			if b.Keys == keys {
+				lines = append(lines, fmt.Sprintf("%s %s %s", b.Mode, b.Keys, b.Action))
+			}
+		}
+		if len(lines) == 0 {
+			return "", false, fmt.Errorf("No mapping found for %s", keys)
+		}
+		return strings.Join(lines, "\n"), false, nil
+	case 3:
+		return "", false, a.bind(editor.Binding{Mode: fields[0], Keys: fields[1], Action: fields[2]})
+	}
+	return "", false, errors.New("Usage: map [mode keys action]")
+}
+
+// List the key bindings along with what their actions do, and the actions
+// no key is bound to
+func describeKeymap(keymap *editor.Keymap, actions *editor.Actions) string {
+	lines := []string{}
+	bound := map[string]bool{}
+	for _, b := range keymap.Bindings("") {
+		lines = append(lines, fmt.Sprintf("%-8s %-12s %-24s %s", b.Mode, b.Keys, b.Action, actions.Get(b.Action).Description))
+		bound[b.Action] = true
+	}
+	for _, name := range actions.Names() {
+		if !bound[name] {
+			lines = append(lines, fmt.Sprintf("%-8s %-12s %-24s %s", "", "", name, actions.Get(name).Description))
+		}
+	}
+	return strings.Join(lines, "\n") + "\n"
+}
+
+// Set an option of the current buffer from "name=value", or show its value
+// when only the name is given. Without a name all options are shown.
+// Changing the syntax gives the buffer the options of its new file type.
+func setOption(arg string, buf *editor.Buffer, cfg *editor.Config) (string, error) {
+	name, value, hasValue := strings.Cut(arg, "=")
+	switch name {
+	case "":
+		values := []string{"syntax=" + syntaxName(buf)}
+		for _, option := range editor.OptionNames() {
+			value, _ := buf.Options.Get(option)
+			values = append(values, option+"="+value)
+		}
+		return strings.Join(values, "\n"), nil
+	case "syntax", "syn":
+		if !hasValue {
+			return "syntax=" + syntaxName(buf), nil
+		}
+		if value == "" || value == "off" || value == "none" {
+			buf.SetSyntax(nil)
+		} else if g := syntax.ByName(value); g != nil {
+			buf.SetSyntax(g)
+		} else {
+			return "", fmt.Errorf("Unknown syntax: %s", value)
+		}
+		buf.Options = bufferOptions(cfg, buf)
+		return "", nil
+	}
+	if !hasValue {
+		value, err := buf.Options.Get(name)
+		if err != nil {
+			return "", err
+		}
+		return name + "=" + value, nil
+	}
+	options := buf.Options
+	if err := options.Set(name, value); err != nil {
+		return "", err
+	}
+	if err := options.Validate(); err != nil {
+		return "", err
+	}
+	if options.FileFormat != buf.Options.FileFormat || options.FileEncoding != buf.Options.FileEncoding {
+		// The file changes when it is saved
+		buf.MarkDirty()
+	}
+	buf.Options = options
+	return "", nil
+}
+
+func syntaxName(buf *editor.Buffer) string {
+	if g, ok := buf.Syntax().(*syntax.Grammar); ok {
+		return g.Name
+	}
+	return "off"
+}
+
+// Close the current tab, the buffer of the tab getting the focus becomes current
+func closeTab(tabs *editor.TabPages, buffers *editor.BufferList) error {
+	if err := tabs.Close(); err != nil {
+		return err
+	}
+	return showTab(tabs, buffers)
+}
+
+// Make the buffer of the current tab's window the current buffer
+func showTab(tabs *editor.TabPages, buffers *editor.BufferList) error {
+	return buffers.Show(tabs.Current().Current().Buffer.ID)
+}
+
+// Parse where :tabmove puts the current tab. N counts from 0, +N and -N are
+// relative to the current tab and no argument means after the last tab.
+func tabPosition(arg string, tabs *editor.TabPages) (int, error) {
+	if arg == "" {
+		return len(tabs.Tabs()) - 1, nil
+	}
+	n, err := strconv.Atoi(arg)
+	if err != nil {
+		return 0, fmt.Errorf("Invalid tab position: %s", arg)
+	}
+	if arg[0] == '+' || arg[0] == '-' {
+		return tabs.Index() + n, nil
+	}
+	return n, nil
+}
+
+// Describe the tab pages and the buffers shown in their windows. > marks
+// the current window and + a buffer with unsaved changes.
+func listTabs(tabs *editor.TabPages) string {
+	lines := []string{}
+	for i, tab := range tabs.Tabs() {
+		lines = append(lines, fmt.Sprintf("Tab page %d", i+1))
+		for _, ew := range tab.Windows() {
+			flags := ' '
+			if i == tabs.Index() && ew == tab.Current() {
+				flags = '>'
+			}
+			dirty := ' '
+			if ew.Buffer.Dirty {
+				dirty = '+'
+			}
+			lines = append(lines, fmt.Sprintf("%c%c %s", flags, dirty, ew.Buffer.Name()))
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// Parse a buffer number, defaulting to the current buffer
+func bufferID(arg string, current *editor.Buffer) (int, error) {
+	if arg == "" {
+		return current.ID, nil
+	}
+	id, err := strconv.Atoi(arg)
+	if err != nil {
+		return 0, fmt.Errorf("Invalid buffer number: %s", arg)
+	}
+	return id, nil
+}
+
+// Describe the open buffers, one per line. % marks the current buffer and
+// + a buffer with unsaved changes.
+func listBuffers(buffers *editor.BufferList) string {
+	lines := []string{}
+	for _, b := range buffers.Buffers() {
+		flags := ' '
+		if b == buffers.Current() {
+			flags = '%'
+		}
+		dirty := ' '
+		if b.Dirty {
+			dirty = '+'
+		}
+		lines = append(lines, fmt.Sprintf("%3d %c%c \"%s\"", b.ID, flags, dirty, b.Name()))
+	}
+	return strings.Join(lines, "\n")
+}
+
+// List the marks of a buffer with their line, column and text
+func listMarks(buf *editor.Buffer) string {
+	lines := []string{"mark line  col text"}
+	for _, name := range buf.MarkNames() {
+		offset, _ := buf.Mark(name)
+		line := buf.Content.LineAt(offset)
+		start := buf.Content.LineStart(line)
+		text := strings.TrimRight(buf.Content.Slice(start, buf.Content.LineStart(line+1)), "\n")
+		lines = append(lines, fmt.Sprintf(" %c %6d %4d %s", name, line+1, offset-start, strings.TrimSpace(text)))
+	}
+	return strings.Join(lines, "\n")
+}
+
+// Delete the marks named, or all of them with !
+func deleteMarks(arg string, buf *editor.Buffer, force bool) error {
+	if force {
+		for _, name := range buf.MarkNames() {
+			buf.DeleteMark(name)
+		}
+		return nil
+	}
+	if arg == "" {
+		return errors.New("Argument required")
+	}
+	for _, name := range strings.ReplaceAll(arg, " ", "") {
+		if !editor.ValidMark(name) {
+			return fmt.Errorf("Invalid mark name: %c", name)
+		}
+		buf.DeleteMark(name)
+	}
+	return nil
+}
+
+func bang(force bool) string {
+	if force {
+		return "!"
+	}
+	return ""
+}
@@ -0,0 +1,138 @@
+package main
+
+import (
+	"NutCode/editor"
+	"NutCode/rope"
+	"slices"
+	"strings"
+
+	"github.com/gdamore/tcell/v2"
+)
+
+// Actions the completion menu stays open through, any other closes it
+var completionActions = []string{"complete.next", "complete.prev", "edit.backspace", "edit.newline", "edit.tab", "mode.normal"}
+
+// Open the completion menu with the words starting like the one before the
+// cursor, or move its selection by n items when it is open
+func (a *app) complete(n int) {
+	if a.ew.Menu != nil {
+		a.ew.Menu.Select(n)
+		return
+	}
+	a.openCompletion(a.wordCompletions)
+	if a.ew.Menu != nil && n < 0 {
+		a.ew.Menu.Selected = len(a.ew.Menu.Items) - 1
+	}
+}
+
+// Open the completion menu for the word before the cursor, with the
+// completions a completer finds for it
+func (a *app) openCompletion(completer func(prefix string) (items, texts []string)) {
+	c := a.ew.Cursor.Offset() - a.cachedStart
+	start := editor.WordStart(a.cachedContent, c, a.buf.Options.WordChars)
+	items, texts := completer(a.cachedContent[start:c])
+	if len(items) == 0 {
+		a.message = "No completions"
+		return
+	}
+	a.completer = completer
+	a.ew.Menu = &editor.Menu{Items: items, Texts: texts, Offset: a.cachedStart + start}
+}
+
+// Find the words completing a prefix, in the current buffer first and then
+// in the other open ones. Large files are left out, reading all their words
+// would take too long.
+func (a *app) wordCompletions(prefix string) ([]string, []string) {
+	buffers := []*editor.Buffer{a.buf}
+	for _, b := range a.buffers.Buffers() {
+		if b != a.buf && !b.Large {
+			buffers = append(buffers, b)
+		}
+	}
+	if a.buf.Large {
+		buffers = buffers[1:]
+	}
+	return editor.CompleteWord(buffers, prefix), nil
+}
+
+// Filter the completions after the word before the cursor changed. The menu
+// closes when the cursor left the word or nothing matches anymore.
+func (a *app) updateCompletion() {
+	m := a.ew.Menu
+	if m == nil {
+		return
+	}
+	c := a.ew.Cursor.Offset() - a.cachedStart
+	start := editor.WordStart(a.cachedContent, c, a.buf.Options.WordChars)
+	if a.cachedStart+start != m.Offset {
+		a.ew.Menu = nil
+		return
+	}
+	m.Items, m.Texts = a.completer(a.cachedContent[start:c])
+	m.Selected = 0
+	if len(m.Items) == 0 {
+		a.ew.Menu = nil
+	}
+}
+
+// Type the rest of the selected completion, false if the menu is not open.
+// A completion that doesn't start with the text typed replaces it.
+func (a *app) acceptCompletion() bool {
+	m := a.ew.Menu
+	if m == nil {
+		return false
+	}
+	a.ew.Menu = nil
+	c := a.ew.Cursor.Offset()
+	typed, text := a.cachedContent[m.Offset-a.cachedStart:c-a.cachedStart], m.Text()
+	if rest, ok := strings.CutPrefix(text, typed); ok {
+		a.insert(rest)
+		return true
+	}
+	a.buf.Apply(rope.Transaction{{Offset: m.Offset, Length: c - m.Offset, Text: text}}, c)
+	a.ew.Cursor.Set(m.Offset + len(text))
+	a.ew.ClearCursors()
+	a.afterEdit()
+	return true
+}
+
+// Close the completion menu, unless the action runs it
+func (a *app) keepCompletion(action string) {
+	if a.ew.Menu != nil && !slices.Contains(completionActions, action) {
+		a.ew.Menu = nil
+	}
+}
+
+// Show items in the menu to pick one from, picked runs with its index.
+// The menu takes the keys until it closes.
+func (a *app) pick(items []string, picked func(i int)) {
+	a.ew.Menu = &editor.Menu{Items: items, Offset: a.ew.Cursor.Offset()}
+	a.picker = picked
+}
+
+// Move through the items of a picker, or pick one. Other keys close the
+// picker, false tells they should still do what they do.
+func (a *app) pickKey(ev *tcell.EventKey) bool {
+	m, picked := a.ew.Menu, a.picker
+	if m == nil {
+		a.picker = nil
+		return false
+	}
+	switch editor.KeyName(ev) {
+	case "<Down>", "<C-n>", "<Tab>":
+		m.Select(1)
+		return true
+	case "<Up>", "<C-p>", "<S-Tab>":
+		m.Select(-1)
+		return true
+	}
+	a.ew.Menu, a.picker = nil, nil
+	switch editor.KeyName(ev) {
+	case "<CR>":
+		picked(m.Selected)
+		return true
+	case "<Esc>":
+		return true
+	}
+	return false
+}
@@ -0,0 +1,118 @@
+package main
+
+import (
+	"NutCode/editor"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Get the path of a file in the user's configuration directory,
+// $XDG_CONFIG_HOME/nutcode on Linux. Empty if there is no such directory.
+func configPath(name string) string {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(dir, "nutcode", name)
+}
+
+// Get the path of a file the editor keeps from one session to the next, in
+// $XDG_STATE_HOME/nutcode or ~/.local/state/nutcode. Empty if there is no
+// home directory.
+func statePath(name string) string {
+	dir := os.Getenv("XDG_STATE_HOME")
+	if dir == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return ""
+		}
+		dir = filepath.Join(home, ".local", "state")
+	}
+	return filepath.Join(dir, "nutcode", name)
+}
+
+// Find a theme by name, in the themes directory of the configuration or
+// else among the built-in ones
+func findTheme(name string) (*editor.Theme, error) {
+	if path := configPath(filepath.Join("themes", name+".toml")); path != "" {
+		t, err := editor.LoadTheme(path)
+		if err == nil || !errors.Is(err, os.ErrNotExist) {
+			return t, err
+		}
+	}
+	if t := editor.BuiltinTheme(name); t != nil {
+		return t, nil
+	}
+	return nil, fmt.Errorf("Cannot find color scheme '%s'", name)
+}
+
+// Name the current theme, and how many colors the terminal shows
+func describeTheme() string {
+	colors := fmt.Sprintf("%d colors", editor.Colors())
+	if editor.Colors() >= 1<<24 {
+		colors = "truecolor"
+	}
+	return fmt.Sprintf("%s (%s)", editor.CurrentTheme().Name, colors)
+}
+
+// Get the options of a buffer: the ones of the config file for its file
+// type, the line endings and character set its file has, and the ones
+// .editorconfig files give its file
+func bufferOptions(cfg *editor.Config, b *editor.Buffer) editor.Options {
+	options := cfg.OptionsFor(syntaxName(b))
+	if isMakefile(b.Path) {
+		// Recipes must start with a tab
+		options.ExpandTab = false
+	}
+	if b.LineEndings != "" {
+		options.FileFormat = b.LineEndings
+	}
+	if b.Encoding != "" {
+		options.FileEncoding = b.Encoding
+	}
+	if b.Path != "" {
+		if props, err := editor.EditorConfig(b.Path); err == nil {
+			options.ApplyEditorConfig(props)
+		}
+	}
+	return options
+}
+
+// Read the config file again and use it for all buffers. Options changed
+// with :set and keys bound with :map are lost.
+func reloadConfig(a *app) error {
+	cfg := a.cfg
+	loaded, err := editor.LoadConfig(configPath("config.toml"))
+	*cfg = *loaded
+	a.buffers.Options = cfg.Options
+	a.registers.SyncClipboard = cfg.SyncClipboard
+	err = errors.Join(err, a.bindKeys())
+	for _, b := range a.buffers.Buffers() {
+		b.Options = bufferOptions(cfg, b)
+	}
+	if cfg.ColorScheme != "" {
+		t, themeErr := findTheme(cfg.ColorScheme)
+		if themeErr == nil {
+			editor.SetTheme(t)
+		}
+		err = errors.Join(err, themeErr)
+	}
+	return configError(err)
+}
+
+// Put all problems with the config file on one line
+func configError(err error) error {
+	if err == nil {
+		return nil
+	}
+	return fmt.Errorf("Error in config file: %s", strings.ReplaceAll(err.Error(), "\n", "; "))
+}
+
+// Check if a file is a Makefile, by its name
+func isMakefile(path string) bool {
+	name := filepath.Base(path)
+	return name == "Makefile" || name == "makefile" || name == "GNUmakefile" || filepath.Ext(name) == ".mk"
+}
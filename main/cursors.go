@@ -0,0 +1,276 @@
+package main
+
+import (
+	"NutCode/editor"
+	"NutCode/rope"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+)
+
+// Make the same edit at the main cursor and at every extra cursor, in one
+// transaction. edit returns false for cursors where nothing should change.
+// The cursors are moved along with the edits. Offsets given to edit and
+// returned by it are in the text of the buffer, see Buffer.Text.
+func editAtCursors(buf *editor.Buffer, ew *editor.EditorWindow, edit func(at int) (rope.Edit, bool)) {
+	editsAtCursors(buf, ew, func(at int) (rope.Transaction, int) {
+		if e, ok := edit(at); ok {
+			return rope.Transaction{e}, 0
+		}
+		return nil, 0
+	})
+}
+
+// Like editAtCursors, for changes made of several edits at each cursor.
+// edits also tells how far the cursor moves from where the edits leave it.
+func editsAtCursors(buf *editor.Buffer, ew *editor.EditorWindow, edits func(at int) (rope.Transaction, int)) {
+	cursors := append([]int{ew.Cursor.Offset()}, ew.Cursors()...)
+	moves := make([]int, len(cursors))
+	base := buf.TextStart()
+	var t rope.Transaction
+	for i, at := range cursors {
+		e, move := edits(at - base)
+		t = append(t, e.Shift(base)...)
+		moves[i] = move
+	}
+	buf.Apply(t, cursors[0])
+	for i, at := range cursors {
+		cursors[i] = t.MapOffset(at) + moves[i]
+	}
+	ew.Cursor.Set(cursors[0])
+	ew.SetCursors(cursors[1:], cursors[0])
+}
+
+// Move the main cursor and the extra ones
+func (a *app) move(m editor.Motion) {
+	a.ew.Move(m)
+	a.loadText()
+}
+
+// Move the cursors n lines down, or up when negative, keeping their columns
+func (a *app) moveLines(n int) {
+	a.ew.MoveLines(n)
+	a.loadText()
+}
+
+// Scroll by pages, down or up when negative. The cursors move as many
+// lines, so they keep their place in the window.
+func (a *app) page(pages int) {
+	rows := pages * a.ew.Rows()
+	a.ew.Scroll(rows)
+	a.moveLines(rows)
+}
+
+// Motion to the bracket matching the one at an offset, or the first one
+// after it on the line that has a match, like vim's %
+func (a *app) matchBracket(content string, offset int) int {
+	base := a.buf.TextStart()
+	_, end := editor.LineBounds(content, offset)
+	for at := offset; at < end; at++ {
+		if match, ok := a.buf.MatchBracket(base + at); ok {
+			return match - base
+		}
+	}
+	return offset
+}
+
+// Find the bounds of the line of the main cursor
+func (a *app) cursorLine() (int, int) {
+	start, end := editor.LineBounds(a.cachedContent, a.ew.Cursor.Offset()-a.cachedStart)
+	return start + a.cachedStart, end + a.cachedStart
+}
+
+// Remember where the cursor is in the jump list, before it jumps away
+func (a *app) pushJump() {
+	a.ew.Jumps.Push(a.buf, a.ew.Cursor.Offset())
+}
+
+// Jump to a line and column, counted from 1
+func (a *app) goTo(line, col int) {
+	a.pushJump()
+	a.ew.GoTo(line, col)
+}
+
+// Jump to a mark, or to the first non-blank of its line
+func (a *app) jumpToMark(name rune, line bool) {
+	offset, ok := a.buf.Mark(name)
+	if !ok {
+		a.message, a.messageIsError = fmt.Sprintf("Mark not set: %c", name), true
+		return
+	}
+	a.pushJump()
+	a.ew.ClearCursors()
+	a.ew.Cursor.Set(offset)
+	if line {
+		a.move(editor.FirstNonBlank)
+	}
+	a.ew.ForgetColumns()
+}
+
+// Go to a place of the jump list, in the buffer it is in
+func (a *app) followJump(j *editor.Jump) {
+	if j.Buffer != a.buf {
+		a.buffers.Show(j.Buffer.ID)
+		a.ew.ShowBuffer(j.Buffer)
+		a.switchWindow()
+	}
+	a.ew.Cursor.Set(j.Offset())
+	a.ew.ClearCursors()
+	a.ew.ForgetColumns()
+}
+
+// Add a cursor on the line above the topmost cursor, or below the bottommost
+// one, in the same column as the main cursor
+func addCursorVertical(ew *editor.EditorWindow, below bool) {
+	content, base := ew.Buffer.Text(), ew.Buffer.TextStart()
+	c := ew.Cursor.Offset() - base
+	tabSize := ew.Buffer.Options.TabSize
+	col := editor.VisualColumn(content, c, tabSize)
+	edge := c
+	for _, offset := range ew.Cursors() {
+		offset -= base
+		if below {
+			edge = max(edge, offset)
+		} else {
+			edge = min(edge, offset)
+		}
+	}
+	start, end := editor.LineBounds(content, edge)
+	if below {
+		if end == len(content) && !strings.HasSuffix(content, "\n") {
+			return
+		}
+		ew.AddCursor(base + editor.OffsetAtColumn(content, end, col, tabSize))
+	} else if start > 0 {
+		ew.AddCursor(base + editor.OffsetAtColumn(content, start-1, col, tabSize))
+	}
+}
+
+// Add a cursor at the next occurrence of the word under the main cursor,
+// searching onwards from the last cursor and wrapping around the end
+func addCursorAtNextMatch(ew *editor.EditorWindow, wordChars string) {
+	content, base := ew.Buffer.Text(), ew.Buffer.TextStart()
+	c := ew.Cursor.Offset() - base
+	wordStart, wordEnd := wordBounds(content, c, wordChars)
+	if wordStart == wordEnd {
+		return
+	}
+	word := content[wordStart:wordEnd]
+	last := c
+	for _, offset := range ew.Cursors() {
+		last = max(last, offset-base)
+	}
+	_, from := wordBounds(content, last, wordChars)
+	for _, searchFrom := range []int{from, 0} {
+		for i := searchFrom; i < len(content); {
+			found := strings.Index(content[i:], word)
+			if found == -1 {
+				break
+			}
+			at := i + found
+			i = at + len(word)
+			if !isWholeWord(content, at, i, wordChars) || at == wordStart {
+				continue
+			}
+			if ew.AddCursor(base + at + c - wordStart) {
+				return
+			}
+		}
+	}
+}
+
+// Find the word containing offset c, returns an empty range if there is none
+func wordBounds(content string, c int, wordChars string) (int, int) {
+	start, end := c, c
+	for start > 0 {
+		r, size := utf8.DecodeLastRuneInString(content[:start])
+		if !editor.IsWordChar(r, wordChars) {
+			break
+		}
+		start -= size
+	}
+	for end < len(content) {
+		r, size := utf8.DecodeRuneInString(content[end:])
+		if !editor.IsWordChar(r, wordChars) {
+			break
+		}
+		end += size
+	}
+	return start, end
+}
+
+func isWholeWord(content string, start, end int, wordChars string) bool {
+	before, _ := utf8.DecodeLastRuneInString(content[:start])
+	after, _ := utf8.DecodeRuneInString(content[end:])
+	return (start == 0 || !editor.IsWordChar(before, wordChars)) && (end == len(content) || !editor.IsWordChar(after, wordChars))
+}
+
+// Compute the regions covered on each line by a block selection from anchor
+// to c. Its edges are columns on screen, so that lines with tabs or wide
+// characters line up.
+func blockRegions(content string, anchor, c, tabSize int) [][2]int {
+	anchorStart, _ := editor.LineBounds(content, anchor)
+	cStart, _ := editor.LineBounds(content, c)
+	anchorCol, cCol := editor.VisualColumn(content, anchor, tabSize), editor.VisualColumn(content, c, tabSize)
+	leftCol, rightCol := min(anchorCol, cCol), max(anchorCol, cCol)
+
+	var regions [][2]int
+	for start := min(anchorStart, cStart); start <= max(anchorStart, cStart); {
+		_, end := editor.LineBounds(content, start)
+		left := editor.OffsetAtColumn(content, start, leftCol, tabSize)
+		// The character at the right edge is in the block
+		right := editor.OffsetAtColumn(content, start, rightCol, tabSize)
+		if right < end && content[right] != '\n' {
+			_, size := utf8.DecodeRuneInString(content[right:])
+			right += size
+		}
+		regions = append(regions, [2]int{left, right})
+		if end == len(content) {
+			break
+		}
+		start = end
+	}
+	return regions
+}
+
+// Compute the regions of the block selection in the buffer
+func (a *app) blockRegions() [][2]int {
+	base := a.cachedStart
+	regions := blockRegions(a.cachedContent, a.anchor-base, a.ew.Cursor.Offset()-base, a.buf.Options.TabSize)
+	for i := range regions {
+		regions[i][0] += base
+		regions[i][1] += base
+	}
+	return regions
+}
+
+// Put a cursor on every line of a block selection, at its left or right edge.
+// The main cursor goes to the first line.
+func cursorsFromBlock(ew *editor.EditorWindow, regions [][2]int, right bool) {
+	edge := 0
+	if right {
+		edge = 1
+	}
+	offsets := make([]int, 0, len(regions))
+	for _, region := range regions {
+		offsets = append(offsets, region[edge])
+	}
+	ew.Cursor.Set(offsets[0])
+	ew.SetCursors(offsets[1:], offsets[0])
+}
+
+// Text of a block selection, one line per region
+func blockText(buf *editor.Buffer, regions [][2]int) string {
+	lines := make([]string, 0, len(regions))
+	for _, region := range regions {
+		lines = append(lines, buf.Content.Slice(region[0], region[1]))
+	}
+	return strings.Join(lines, "\n")
+}
+
+// Insert text at the main cursor and every extra cursor
+func insertAtCursors(buf *editor.Buffer, ew *editor.EditorWindow, text string) {
+	editAtCursors(buf, ew, func(at int) (rope.Edit, bool) {
+		return rope.Edit{Offset: at, Text: text}, true
+	})
+}
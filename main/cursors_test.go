@@ -0,0 +1,25 @@
+package main
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestBlockRegions(t *testing.T) {
+	tests := []struct {
+		name      string
+		content   string
+		anchor, c int
+		expected  [][2]int
+	}{
+		{"one line", "abcdef", 1, 3, [][2]int{{1, 4}}},
+		{"short line", "abcdef\nab\nabcdef", 3, 14, [][2]int{{3, 5}, {9, 9}, {13, 15}}},
+		{"wide characters and tabs", "ab日本cd\n\tefgh\nabcdefghijkl", 2, 22, [][2]int{{2, 8}, {11, 14}, {19, 23}}},
+		{"upwards", "ab日本cd\n\tefgh\nabcdefghijkl", 22, 2, [][2]int{{2, 8}, {11, 14}, {19, 23}}},
+	}
+	for _, tt := range tests {
+		if got := blockRegions(tt.content, tt.anchor, tt.c, 4); !slices.Equal(got, tt.expected) {
+			t.Fatalf("%s. Expected=%v, got=%v", tt.name, tt.expected, got)
+		}
+	}
+}
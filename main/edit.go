@@ -0,0 +1,94 @@
+package main
+
+import (
+	"NutCode/editor"
+	"NutCode/rope"
+	"strings"
+	"unicode/utf8"
+)
+
+// Compute the region covered by a visual selection from anchor to c
+func visualRegion(content string, anchor, c int, linewise bool) (int, int) {
+	start, end := min(anchor, c), max(anchor, c)
+	if linewise {
+		start, _ = editor.LineBounds(content, start)
+		_, end = editor.LineBounds(content, end)
+		return start, end
+	}
+	// The character under the cursor is selected, all its bytes
+	_, size := utf8.DecodeRuneInString(content[end:])
+	return start, end + size
+}
+
+// Compute the region of the visual selection in the buffer
+func (a *app) visualRegion() (int, int) {
+	base := a.cachedStart
+	start, end := visualRegion(a.cachedContent, a.anchor-base, a.ew.Cursor.Offset()-base, a.mode == VISUAL_LINE)
+	return start + base, end + base
+}
+
+// Take the text of a region, linewise text always ends with a newline
+func regionRegister(buf *editor.Buffer, start, end int, linewise bool) editor.Register {
+	text := buf.Content.Slice(start, end)
+	if linewise && !strings.HasSuffix(text, "\n") {
+		text += "\n"
+	}
+	return editor.Register{Content: text, Linewise: linewise}
+}
+
+// Copy a region into a register
+func yankRegion(regs *editor.Registers, name rune, buf *editor.Buffer, start, end int, linewise bool) {
+	regs.Yank(name, regionRegister(buf, start, end, linewise))
+}
+
+// Cut a region into a register, returns where the cursor should go
+func deleteRegion(buf *editor.Buffer, regs *editor.Registers, name rune, c, start, end int, linewise bool) int {
+	if start >= end {
+		return start
+	}
+	regs.Delete(name, regionRegister(buf, start, end, linewise))
+	if linewise && end == buf.Content.Len() && start > 0 && buf.Content.Slice(end-1, end) != "\n" {
+		// The last line has no newline of its own, take the previous one instead
+		start--
+	}
+	buf.Apply(rope.Transaction{{Offset: start, Length: end - start}}, c)
+	if linewise {
+		start = buf.Content.LineStart(buf.Content.LineAt(start))
+	}
+	return start
+}
+
+// Paste a register before or after the cursor, returns where the cursor should go
+func pasteRegister(buf *editor.Buffer, c int, reg editor.Register, after bool) int {
+	if reg.Content == "" {
+		return c
+	}
+	content, base := buf.Text(), buf.TextStart()
+	insert := func(at int, text string) {
+		buf.Apply(rope.Transaction{{Offset: at, Text: text}}, c)
+	}
+	if reg.Linewise {
+		start, end := editor.LineBounds(content, c-base)
+		start, end = start+base, end+base
+		if !after {
+			insert(start, reg.Content)
+			return start
+		}
+		if n := buf.Content.Len(); end == n && (n == 0 || buf.Content.Slice(n-1, n) != "\n") {
+			// Pasting below the last line, which has no newline to paste after
+			insert(end, "\n"+strings.TrimSuffix(reg.Content, "\n"))
+			return end + 1
+		}
+		insert(end, reg.Content)
+		return end
+	}
+	at := c
+	if after && c < buf.Content.Len() && content[c-base] != '\n' {
+		_, size := utf8.DecodeRuneInString(content[c-base:])
+		at += size
+	}
+	insert(at, reg.Content)
+	// Leave the cursor on the last pasted character
+	_, size := utf8.DecodeLastRuneInString(reg.Content)
+	return at + len(reg.Content) - size
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"NutCode/editor"
+	"testing"
+)
+
+func TestVisualRegion(t *testing.T) {
+	content := "héllo\nwörld\n"
+	tests := []struct {
+		name       string
+		anchor, c  int
+		linewise   bool
+		start, end int
+	}{
+		{"forward", 0, 3, false, 0, 4},
+		{"backward", 4, 1, false, 1, 5},
+		{"ending on é", 0, 1, false, 0, 3},
+		{"over a newline", 7, 6, false, 6, 8},
+		{"linewise", 3, 8, true, 0, 14},
+		{"linewise on one line", 9, 9, true, 7, 14},
+	}
+	for _, tt := range tests {
+		start, end := visualRegion(content, tt.anchor, tt.c, tt.linewise)
+		if start != tt.start || end != tt.end {
+			t.Fatalf("%s. Expected=%d-%d, got=%d-%d", tt.name, tt.start, tt.end, start, end)
+		}
+	}
+}
+
+func TestDeleteRegion(t *testing.T) {
+	tests := []struct {
+		name       string
+		start, end int
+		linewise   bool
+		expected   string
+		register   string
+		cursor     int
+	}{
+		{"charwise", 1, 3, false, "o\ntwo\nthree", "ne", 1},
+		{"charwise over a newline", 2, 5, false, "onwo\nthree", "e\nt", 2},
+		{"middle line", 4, 8, true, "one\nthree", "two\n", 4},
+		{"first line", 0, 4, true, "two\nthree", "one\n", 0},
+		{"last line", 8, 13, true, "one\ntwo", "three\n", 4},
+		{"empty region", 5, 5, false, "one\ntwo\nthree", "", 5},
+	}
+	for _, tt := range tests {
+		buf := editor.NewBufferFromString("", "one\ntwo\nthree")
+		regs := editor.NewRegisters(nil)
+		cursor := deleteRegion(buf, regs, editor.UnnamedRegister, tt.start, tt.start, tt.end, tt.linewise)
+		if got := buf.Content.String(); got != tt.expected {
+			t.Fatalf("%s. Expected=%q, got=%q", tt.name, tt.expected, got)
+		}
+		if cursor != tt.cursor {
+			t.Fatalf("%s. Expected cursor=%d, got=%d", tt.name, tt.cursor, cursor)
+		}
+		reg, _ := regs.Get(editor.UnnamedRegister)
+		if reg.Content != tt.register || (tt.register != "" && reg.Linewise != tt.linewise) {
+			t.Fatalf("%s. Expected register=%q, got=%+v", tt.name, tt.register, reg)
+		}
+	}
+}
+
+func TestPasteRegister(t *testing.T) {
+	tests := []struct {
+		name     string
+		c        int
+		reg      editor.Register
+		after    bool
+		expected string
+		cursor   int
+	}{
+		{"after é", 1, editor.Register{Content: "X"}, true, "héXllo\nend", 3},
+		{"before é", 1, editor.Register{Content: "X"}, false, "hXéllo\nend", 1},
+		{"ending with ü", 0, editor.Register{Content: "aü"}, true, "haüéllo\nend", 2},
+		{"after the last character of a line", 5, editor.Register{Content: "ab"}, true, "hélloab\nend", 7},
+		{"after on a newline", 6, editor.Register{Content: "X"}, true, "hélloX\nend", 6},
+		{"line below", 1, editor.Register{Content: "new\n", Linewise: true}, true, "héllo\nnew\nend", 7},
+		{"line above", 8, editor.Register{Content: "new\n", Linewise: true}, false, "héllo\nnew\nend", 7},
+		{"line below the last one", 8, editor.Register{Content: "new\n", Linewise: true}, true, "héllo\nend\nnew", 11},
+		{"empty register", 4, editor.Register{}, true, "héllo\nend", 4},
+	}
+	for _, tt := range tests {
+		buf := editor.NewBufferFromString("", "héllo\nend")
+		cursor := pasteRegister(buf, tt.c, tt.reg, tt.after)
+		if got := buf.Content.String(); got != tt.expected {
+			t.Fatalf("%s. Expected=%q, got=%q", tt.name, tt.expected, got)
+		}
+		if cursor != tt.cursor {
+			t.Fatalf("%s. Expected cursor=%d, got=%d", tt.name, tt.cursor, cursor)
+		}
+	}
+}
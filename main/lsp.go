@@ -0,0 +1,599 @@
+package main
+
+import (
+	"NutCode/editor"
+	"NutCode/lsp"
+	"NutCode/rope"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+)
+
+// How long language servers get to start, to answer and to stop
+const (
+	serverStartTimeout   = 30 * time.Second
+	serverRequestTimeout = 10 * time.Second
+	serverStopTimeout    = 2 * time.Second
+)
+
+// The language servers of the open buffers, one for each file type with a
+// command in the config. A server starts when the first buffer of its type
+// is opened. The changes to the buffers are sent after every key, and what
+// the servers send comes back to the main loop through post.
+type languageServers struct {
+	cfg *editor.Config
+	// Directory of the project the servers are told about
+	root string
+	// Run a function in the main loop
+	post func(func())
+	// Tell the user something the servers said
+	message func(text string, isError bool)
+	// Servers by file type
+	servers map[string]*languageServer
+	docs    map[*editor.Buffer]*document
+}
+
+type languageServer struct {
+	command []string
+	// nil while the server is starting, or when it couldn't start or exited
+	client *lsp.Client
+	// How the server takes changes to documents
+	sync int
+	// Why there is no client
+	err error
+}
+
+// A buffer opened on a language server
+type document struct {
+	uri, filetype string
+	server        *languageServer
+	version       int
+	// The server was told about the buffer
+	opened bool
+	// The buffer changed since its content was last sent
+	changed bool
+	// Changes not sent yet, for servers taking incremental changes
+	changes []lsp.TextDocumentContentChangeEvent
+	// The buffer was saved since the last sync, the server is told after
+	// the changes
+	saved bool
+}
+
+func newLanguageServers(cfg *editor.Config, post func(func()), message func(string, bool)) *languageServers {
+	root, _ := os.Getwd()
+	return &languageServers{
+		cfg:     cfg,
+		root:    root,
+		post:    post,
+		message: message,
+		servers: make(map[string]*languageServer),
+		docs:    make(map[*editor.Buffer]*document),
+	}
+}
+
+// Get the file type of a buffer, the name servers are configured by
+func fileType(b *editor.Buffer) string {
+	return strings.ToLower(syntaxName(b))
+}
+
+// Open the buffers that have a language server on it, close the ones that
+// were deleted or got another file or file type, and send the changes of
+// the others
+func (ls *languageServers) update(buffers []*editor.Buffer) {
+	for b, d := range ls.docs {
+		if !slices.Contains(buffers, b) || b.Path == "" || d.uri != lsp.PathToURI(b.Path) || d.filetype != fileType(b) {
+			ls.close(b, d)
+		}
+	}
+	for _, b := range buffers {
+		d := ls.docs[b]
+		if d == nil {
+			d = ls.open(b)
+		}
+		if d != nil {
+			ls.sync(b, d)
+		}
+	}
+}
+
+// Start following the changes of a buffer, nil if there is no language
+// server for it. Large files are left out.
+func (ls *languageServers) open(b *editor.Buffer) *document {
+	filetype := fileType(b)
+	command := ls.cfg.LanguageServers[filetype]
+	if b.Path == "" || b.Large || len(command) == 0 {
+		return nil
+	}
+	s := ls.servers[filetype]
+	if s == nil {
+		s = ls.start(filetype, command)
+	}
+	d := &document{uri: lsp.PathToURI(b.Path), filetype: filetype, server: s}
+	b.Changing = func(t rope.Transaction) { d.record(b, t) }
+	b.Saved = func() { d.saved = d.opened }
+	ls.docs[b] = d
+	return d
+}
+
+// Remember a change to a buffer, to send it with the next sync
+func (d *document) record(b *editor.Buffer, t rope.Transaction) {
+	if !d.opened {
+		return
+	}
+	d.changed = true
+	if d.server.sync == lsp.SyncIncremental {
+		d.changes = append(d.changes, lsp.ContentChanges(b.Content, t)...)
+	}
+}
+
+// Tell the server of a buffer what changed since the last time, once it
+// has started
+func (ls *languageServers) sync(b *editor.Buffer, d *document) {
+	c := d.server.client
+	if c == nil {
+		return
+	}
+	// Errors come from a lost connection, which is told when the server exits
+	switch {
+	case !d.opened:
+		d.opened = true
+		c.DidOpen(d.uri, d.filetype, d.version, b.Text())
+	case d.changed && d.server.sync != lsp.SyncNone:
+		d.version++
+		changes := d.changes
+		if d.server.sync == lsp.SyncFull {
+			changes = []lsp.TextDocumentContentChangeEvent{{Text: b.Text()}}
+		}
+		c.DidChange(d.uri, d.version, changes)
+	}
+	d.changed, d.changes = false, nil
+	if d.saved {
+		c.DidSave(d.uri)
+	}
+	d.saved = false
+}
+
+// Stop following a buffer
+func (ls *languageServers) close(b *editor.Buffer, d *document) {
+	if c := d.server.client; c != nil && d.opened {
+		c.DidClose(d.uri)
+	}
+	b.Changing = nil
+	b.Saved = nil
+	b.SetDiagnostics(nil)
+	delete(ls.docs, b)
+}
+
+// Start the server of a file type in the background, the buffers are
+// opened on it once it is ready
+func (ls *languageServers) start(filetype string, command []string) *languageServer {
+	s := &languageServer{command: command}
+	ls.servers[filetype] = s
+	n := lsp.Notifications{
+		Diagnostics: func(p lsp.PublishDiagnosticsParams) {
+			ls.post(func() { ls.diagnostics(p) })
+		},
+		Message: func(text string, isError bool) {
+			ls.post(func() { ls.message(text, isError) })
+		},
+	}
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), serverStartTimeout)
+		defer cancel()
+		c, err := lsp.Start(ctx, command, ls.root, n)
+		ls.post(func() {
+			if err != nil {
+				s.err = fmt.Errorf("Cannot start language server %s: %w", command[0], err)
+				ls.message(s.err.Error(), true)
+				return
+			}
+			s.client, s.sync = c, c.Capabilities.SyncKind()
+			if ls.servers[filetype] != s {
+				// Restarted in the meantime
+				stopServers([]*languageServer{s})
+				return
+			}
+			go func() {
+				<-c.Done()
+				ls.post(func() { ls.exited(s, c) })
+			}()
+		})
+	}()
+	return s
+}
+
+// Forget a server that exited on its own, like when it crashed
+func (ls *languageServers) exited(s *languageServer, c *lsp.Client) {
+	if s.client != c {
+		// Stopped by the editor
+		return
+	}
+	// Wait for its process, so that it does not linger
+	stopServers([]*languageServer{s})
+	s.err = fmt.Errorf("Language server %s exited", s.command[0])
+	ls.message(s.err.Error()+", :lsp restart starts it again", true)
+	for b, d := range ls.docs {
+		if d.server == s {
+			d.opened = false
+			b.SetDiagnostics(nil)
+		}
+	}
+}
+
+// Stop servers, giving them serverStopTimeout to exit. The channel is
+// closed once they did.
+func stopServers(servers []*languageServer) <-chan struct{} {
+	clients := []*lsp.Client{}
+	for _, s := range servers {
+		if s.client != nil {
+			clients = append(clients, s.client)
+			s.client = nil
+		}
+	}
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		ctx, cancel := context.WithTimeout(context.Background(), serverStopTimeout)
+		defer cancel()
+		stopped := make(chan struct{})
+		for _, c := range clients {
+			go func() {
+				c.Shutdown(ctx)
+				stopped <- struct{}{}
+			}()
+		}
+		for range clients {
+			<-stopped
+		}
+	}()
+	return done
+}
+
+// Stop all servers, when the editor quits
+func (ls *languageServers) shutdown() {
+	servers := make([]*languageServer, 0, len(ls.servers))
+	for _, s := range ls.servers {
+		servers = append(servers, s)
+	}
+	<-stopServers(servers)
+}
+
+// Stop all servers and start them again for the buffers
+func (ls *languageServers) restart() {
+	for b, d := range ls.docs {
+		ls.close(b, d)
+	}
+	servers := make([]*languageServer, 0, len(ls.servers))
+	for _, s := range ls.servers {
+		servers = append(servers, s)
+	}
+	stopServers(servers)
+	ls.servers = make(map[string]*languageServer)
+}
+
+// Give a buffer the diagnostics a server published for its file
+func (ls *languageServers) diagnostics(p lsp.PublishDiagnosticsParams) {
+	for b, d := range ls.docs {
+		if d.uri != p.URI || !d.opened {
+			continue
+		}
+		diagnostics := make([]editor.Diagnostic, 0, len(p.Diagnostics))
+		for _, pd := range p.Diagnostics {
+			start, end := lsp.RangeToOffsets(b.Content, pd.Range)
+			message := pd.Message
+			if pd.Source != "" {
+				message = pd.Source + ": " + message
+			}
+			diagnostics = append(diagnostics, editor.Diagnostic{Start: start, End: end, Severity: pd.Severity, Message: message})
+		}
+		b.SetDiagnostics(diagnostics)
+	}
+}
+
+// Describe the servers started, one per line
+func (ls *languageServers) status() string {
+	if len(ls.servers) == 0 {
+		return "No language servers started"
+	}
+	filetypes := make([]string, 0, len(ls.servers))
+	for filetype := range ls.servers {
+		filetypes = append(filetypes, filetype)
+	}
+	sort.Strings(filetypes)
+	lines := []string{}
+	for _, filetype := range filetypes {
+		s := ls.servers[filetype]
+		state := "starting"
+		if s.client != nil {
+			state = "running " + s.client.Name
+		} else if s.err != nil {
+			state = s.err.Error()
+		}
+		lines = append(lines, fmt.Sprintf("%s: %s (%s)", filetype, strings.Join(s.command, " "), state))
+	}
+	return strings.Join(lines, "\n")
+}
+
+// Get the server of the current buffer, and the buffer's document on it,
+// with the changes to the buffer sent
+func (a *app) languageServer() (*lsp.Client, *document, error) {
+	d := a.servers.docs[a.buf]
+	switch {
+	case d == nil:
+		return nil, nil, errors.New("No language server for this buffer")
+	case d.server.client == nil && d.server.err != nil:
+		return nil, nil, d.server.err
+	case d.server.client == nil:
+		return nil, nil, errors.New("The language server is starting")
+	}
+	a.servers.sync(a.buf, d)
+	return d.server.client, d, nil
+}
+
+// Ask the language server of the current buffer about the cursor position,
+// in the background. done gets the answer in the main loop, unless the
+// buffer, the cursor or the mode changed in the meantime.
+func request[T any](a *app, call func(c *lsp.Client, ctx context.Context, uri string, pos lsp.Position) (T, error), done func(T)) {
+	c, d, err := a.languageServer()
+	if err != nil {
+		a.message, a.messageIsError = err.Error(), true
+		return
+	}
+	ew, b, offset, mode, version := a.ew, a.buf, a.ew.Cursor.Offset(), a.mode, d.version
+	pos := lsp.OffsetToPosition(b.Content, offset)
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), serverRequestTimeout)
+		defer cancel()
+		result, err := call(c, ctx, d.uri, pos)
+		a.servers.post(func() {
+			if a.ew != ew || a.buf != b || ew.Cursor.Offset() != offset || a.mode != mode || d.version != version || a.servers.docs[b] != d {
+				return
+			}
+			if err != nil {
+				a.message, a.messageIsError = err.Error(), true
+				return
+			}
+			done(result)
+		})
+	}()
+}
+
+// Show the documentation of what is under the cursor
+func (a *app) hover() {
+	request(a, (*lsp.Client).Hover, func(text string) {
+		if text = strings.TrimSpace(text); text == "" {
+			a.message = "No information"
+			return
+		}
+		a.message = text
+	})
+}
+
+// Jump to the definition of what is under the cursor
+func (a *app) definition() {
+	request(a, (*lsp.Client).Definition, func(locs []lsp.Location) {
+		if len(locs) == 0 {
+			a.message = "No definition found"
+			return
+		}
+		a.pushJump()
+		a.goToLocation(locs[0])
+	})
+}
+
+// Pick from the places that refer to what is under the cursor
+func (a *app) references() {
+	request(a, (*lsp.Client).References, func(locs []lsp.Location) {
+		if len(locs) == 0 {
+			a.message = "No references found"
+			return
+		}
+		a.pick(a.describeLocations(locs), func(i int) {
+			a.pushJump()
+			a.goToLocation(locs[i])
+		})
+	})
+}
+
+// Rename what is under the cursor everywhere it is used
+func (a *app) rename(name string) {
+	if name == "" {
+		a.message, a.messageIsError = "Argument required", true
+		return
+	}
+	rename := func(c *lsp.Client, ctx context.Context, uri string, pos lsp.Position) (lsp.WorkspaceEdit, error) {
+		return c.Rename(ctx, uri, pos, name)
+	}
+	request(a, rename, a.applyWorkspaceEdit)
+}
+
+// Complete the word before the cursor with what the language server knows
+func (a *app) completeFromServer() {
+	request(a, (*lsp.Client).Completion, func(items []lsp.CompletionItem) {
+		a.openCompletion(serverCompleter(items))
+	})
+}
+
+// Completer of the items a language server gave, keeping the ones that
+// start like the text typed
+func serverCompleter(items []lsp.CompletionItem) func(prefix string) ([]string, []string) {
+	return func(prefix string) ([]string, []string) {
+		var labels, texts []string
+		prefix = strings.ToLower(prefix)
+		for _, item := range items {
+			filter := item.FilterText
+			if filter == "" {
+				filter = item.Label
+			}
+			if strings.HasPrefix(strings.ToLower(filter), prefix) {
+				labels = append(labels, item.Label)
+				texts = append(texts, item.Text())
+			}
+		}
+		return labels, texts
+	}
+}
+
+// Go to a place a language server told about, opening its file
+func (a *app) goToLocation(loc lsp.Location) {
+	path := lsp.URIToPath(loc.URI)
+	if path == "" {
+		a.message, a.messageIsError = "Cannot open "+loc.URI, true
+		return
+	}
+	b, err := a.buffers.Open(relativePath(path))
+	if err != nil {
+		a.message, a.messageIsError = err.Error(), true
+		return
+	}
+	if b != a.buf {
+		a.ew.ShowBuffer(b)
+		a.switchWindow()
+	}
+	a.ew.Cursor.Set(lsp.PositionToOffset(b.Content, loc.Range.Start))
+	a.ew.ClearCursors()
+	a.ew.ForgetColumns()
+	a.ew.Center()
+}
+
+// Describe places as file:line:column and the text of their line. The
+// text comes from the buffer of the file when it is open.
+func (a *app) describeLocations(locs []lsp.Location) []string {
+	buffers := make(map[string]*editor.Buffer)
+	for _, b := range a.buffers.Buffers() {
+		if b.Path != "" {
+			buffers[lsp.PathToURI(b.Path)] = b
+		}
+	}
+	lines := make(map[string][]string)
+	items := make([]string, 0, len(locs))
+	for _, loc := range locs {
+		path := lsp.URIToPath(loc.URI)
+		text, line := "", loc.Range.Start.Line
+		if b, ok := buffers[loc.URI]; ok {
+			if line < b.Content.LineCount() {
+				text = strings.TrimSpace(b.Content.Slice(b.Content.LineStart(line), b.Content.LineStart(line+1)))
+			}
+		} else {
+			if _, ok := lines[loc.URI]; !ok {
+				data, _ := os.ReadFile(path)
+				lines[loc.URI] = strings.Split(string(data), "\n")
+			}
+			if line < len(lines[loc.URI]) {
+				text = strings.TrimSpace(lines[loc.URI][line])
+			}
+		}
+		items = append(items, fmt.Sprintf("%s:%d:%d: %s", relativePath(path), loc.Range.Start.Line+1, loc.Range.Start.Character+1, text))
+	}
+	return items
+}
+
+// Make the changes of a rename in the buffers of their files. Files that
+// are not open are opened, so that the changes can be checked before they
+// are saved.
+func (a *app) applyWorkspaceEdit(edit lsp.WorkspaceEdit) {
+	changes := edit.Edits()
+	uris := make([]string, 0, len(changes))
+	for uri := range changes {
+		uris = append(uris, uri)
+	}
+	sort.Strings(uris)
+	current, count, errs := a.buf, 0, []error{}
+	for _, uri := range uris {
+		path := lsp.URIToPath(uri)
+		if path == "" {
+			errs = append(errs, fmt.Errorf("Cannot open %s", uri))
+			continue
+		}
+		b, err := a.buffers.Open(relativePath(path))
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		t := lsp.EditsToTransaction(b.Content, changes[uri])
+		cursor := b.Offset
+		if b == current {
+			cursor = a.ew.Cursor.Offset()
+		}
+		// The edits of a file are undone in one step
+		b.BeginGroup()
+		b.Apply(t, cursor)
+		b.EndGroup()
+		count += len(t)
+	}
+	a.buffers.Show(current.ID)
+	a.ew.ClearCursors()
+	a.afterEdit()
+	if err := errors.Join(errs...); err != nil {
+		a.message, a.messageIsError = strings.ReplaceAll(err.Error(), "\n", "; "), true
+		return
+	}
+	a.message = fmt.Sprintf("%d changes", count)
+	if len(uris) > 1 {
+		a.message += fmt.Sprintf(" in %d files", len(uris))
+	}
+}
+
+// Pick from the diagnostics of the buffer
+func (a *app) listDiagnostics() error {
+	diagnostics := a.buf.Diagnostics()
+	if len(diagnostics) == 0 {
+		return errors.New("No diagnostics")
+	}
+	items := make([]string, 0, len(diagnostics))
+	for _, d := range diagnostics {
+		line := a.buf.Content.LineAt(d.Start)
+		col := d.Start - a.buf.Content.LineStart(line)
+		message, _, _ := strings.Cut(d.Message, "\n")
+		items = append(items, fmt.Sprintf("%d:%d: %s: %s", line+1, col+1, severityNames[d.Severity], message))
+	}
+	a.pick(items, func(i int) {
+		a.pushJump()
+		a.ew.Cursor.Set(diagnostics[i].Start)
+		a.ew.ClearCursors()
+		a.ew.ForgetColumns()
+	})
+	return nil
+}
+
+var severityNames = [...]string{editor.SeverityError: "error", editor.SeverityWarning: "warning", editor.SeverityInformation: "info", editor.SeverityHint: "hint"}
+
+// Go to the next diagnostic of the buffer, or the previous one
+func (a *app) nextDiagnostic(forward bool) {
+	offset, ok := a.buf.NextDiagnostic(a.ew.Cursor.Offset(), forward)
+	if !ok {
+		a.message = "No diagnostics"
+		return
+	}
+	a.ew.Cursor.Set(offset)
+	a.ew.ClearCursors()
+	a.ew.ForgetColumns()
+}
+
+// Get the message of the diagnostic at the cursor, the most severe one
+func (a *app) diagnosticMessage() (string, bool) {
+	found := a.buf.DiagnosticsAt(a.ew.Cursor.Offset())
+	if len(found) == 0 {
+		return "", false
+	}
+	message, _, _ := strings.Cut(found[0].Message, "\n")
+	return message, found[0].Severity == editor.SeverityError
+}
+
+// Get a path relative to the working directory when the file is in it
+func relativePath(path string) string {
+	dir, err := os.Getwd()
+	if err != nil {
+		return path
+	}
+	if rel, err := filepath.Rel(dir, path); err == nil && !strings.HasPrefix(rel, "..") {
+		return rel
+	}
+	return path
+}
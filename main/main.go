@@ -2,11 +2,14 @@ package main
 
 import (
 	"NutCode/editor"
-	"NutCode/rope"
+	"NutCode/syntax"
+	"errors"
 	"flag"
 	"fmt"
+	"io"
 	"log"
 	"os"
+	"slices"
 	"strings"
 
 	"github.com/gdamore/tcell/v2"
@@ -16,47 +19,65 @@ const (
 	NORMAL = iota
 	INSERT
 	COMMAND
+	VISUAL
+	VISUAL_LINE
+	VISUAL_BLOCK
 )
 
 func main() {
 
-	filename := flag.String("filename", "", "the name of the file to read from or write to")
+	filename := flag.String("filename", "", "the name of the file to read from or write to, more files can follow as arguments")
 	// Parse command-line arguments
 
 	flag.Parse()
 
-	// Check if the filename flag is provided
-	if *filename == "" {
+	// Files can be given with the flag and as arguments
+	files := flag.Args()
+	if *filename != "" {
+		files = append([]string{*filename}, files...)
+	}
+	// +N, or +N:C, starts on a line of the first file
+	var startLine, startCol int
+	files = slices.DeleteFunc(files, func(f string) bool {
+		if !strings.HasPrefix(f, "+") {
+			return false
+		}
+		line, col, ok := parseLocation(f[1:])
+		if ok {
+			startLine, startCol = line, col
+		}
+		return ok
+	})
+	if len(files) == 0 {
 		fmt.Println("Please provide a filename")
 		os.Exit(1)
 	}
-	// Open the file
-	file, err := os.OpenFile(*filename, os.O_RDWR|os.O_CREATE, 0644)
-	if err != nil {
-		fmt.Println("Error:", err)
-		os.Exit(1)
+	// Grammars from the config directory, before files get their syntax picked
+	grammars, grammarErr := syntax.LoadDir(configPath("syntax"))
+	for _, g := range grammars {
+		syntax.Register(g)
 	}
-	defer file.Close()
 
-	// Get the file size
-	fileInfo, err := file.Stat()
-	if err != nil {
-		fmt.Println("Error getting file info:", err)
-		return
+	cfg, configErr := editor.LoadConfig(configPath("config.toml"))
+	// Marks of the files are kept for the next sessions
+	marks, marksErr := editor.LoadMarkStore(statePath("marks.json"))
+	buffers := editor.NewBufferList()
+	buffers.Options = cfg.Options
+	buffers.Configure = func(b *editor.Buffer) {
+		b.Options = bufferOptions(cfg, b)
+		marks.Restore(b)
 	}
-	fileSize := fileInfo.Size()
-
-	// Read the entire file into a byte slice
-	data := make([]byte, fileSize)
-	_, err = file.Read(data)
-	if err != nil {
-		fmt.Println("Error reading file:", err)
-		return
+	buffers.Closing = marks.Remember
+	for _, f := range files {
+		if _, err := buffers.Open(f); err != nil {
+			fmt.Println("Error reading file:", err)
+			os.Exit(1)
+		}
 	}
-	content := rope.New(string(data))
-	charCount := len(data)
+	// Start on the first file
+	buffers.Show(buffers.Buffers()[0].ID)
+	buf := buffers.Current()
 
-	defStyle := tcell.StyleDefault.Background(tcell.ColorReset).Foreground(tcell.ColorReset)
 	// Initialize screen
 	s, err := tcell.NewScreen()
 	if err != nil {
@@ -65,8 +86,17 @@ func main() {
 	if err := s.Init(); err != nil {
 		log.Fatalf("%+v", err)
 	}
-	s.SetStyle(defStyle)
-	s.SetCursorStyle(tcell.CursorStyleBlinkingBar)
+	editor.DetectColors(s)
+	theme := editor.DefaultTheme()
+	if cfg.ColorScheme != "" {
+		if t, err := findTheme(cfg.ColorScheme); err != nil {
+			configErr = errors.Join(configErr, err)
+		} else {
+			theme = t
+		}
+	}
+	editor.SetTheme(theme)
+	s.SetStyle(editor.CurrentTheme().Default)
 	s.EnableMouse()
 	s.EnablePaste()
 	s.Clear()
@@ -81,19 +111,65 @@ func main() {
 			panic(maybePanic)
 		}
 	}
+	// Errors saving the marks are told once the screen is closed
+	var marksSaveErr error
+	defer func() {
+		if marksSaveErr != nil {
+			fmt.Println("Error saving marks:", marksSaveErr)
+		}
+	}()
 	defer quit()
 
-	tabSize := 4
-	unsavedChanges := false
-	c := 0
+	// Yanks reach the system clipboard through the terminal (OSC 52), or xclip & co.
+	var clipboardTty io.Writer
+	if tty, ok := s.Tty(); ok {
+		clipboardTty = tty
+	}
 
-	cachedContent := content.GetContent()
-	editor := editor.New(s, 0, 0, 5, 7, defStyle)
-	editor.ComputeNumRows(cachedContent)
+	ew := editor.New(s, 0, 0, buf.Options.LineNumberWidth, buf.Options.ContentOffset)
+	ew.ShowBuffer(buf)
+	a := &app{
+		screen:    s,
+		buffers:   buffers,
+		tabs:      editor.NewTabPages(s, editor.NewLayout(s, ew)),
+		ew:        ew,
+		buf:       buf,
+		registers: editor.NewRegisters(editor.NewSystemClipboard(clipboardTty, true)),
+		cfg:       cfg,
+		actions:   editor.NewActions(),
+		mode:      INSERT,
+		register:  editor.UnnamedRegister,
+	}
+	a.registers.SyncClipboard = cfg.SyncClipboard
+	a.servers = newLanguageServers(cfg, func(f func()) {
+		s.PostEvent(tcell.NewEventInterrupt(f))
+	}, func(text string, isError bool) {
+		a.message, a.messageIsError = text, isError
+	})
+	a.registerActions()
+	keysErr := a.bindKeys()
+	buf.BeginGroup()
+	a.afterEdit()
+	if startLine > 0 {
+		ew.GoTo(startLine, startCol)
+	}
+	if grammarErr != nil {
+		a.message = "Error loading grammars: " + grammarErr.Error()
+		a.messageIsError = true
+	}
+	if marksErr != nil {
+		a.message = "Error loading marks: " + marksErr.Error()
+		a.messageIsError = true
+	}
+	if err := configError(errors.Join(configErr, keysErr)); err != nil {
+		a.message = err.Error()
+		a.messageIsError = true
+	}
 
-	editor.DrawFull(cachedContent, *filename, unsavedChanges)
+	a.servers.update(buffers.Buffers())
+	a.draw()
 
-	for {
+	for !a.quit {
 		// Update screen
 		s.Show()
 
@@ -103,155 +179,36 @@ func main() {
 		// Process event
 		switch ev := ev.(type) {
 		case *tcell.EventResize:
+			w, h := s.Size()
+			a.tabs.SetSize(w, h)
 			s.Sync()
+		case *tcell.EventMouse:
+			// Clicking a tab in the tab bar switches to it
+			if ev.Buttons()&tcell.Button1 == 0 {
+				break
+			}
+			if i := a.tabs.TabAt(ev.Position()); i != -1 && i != a.tabs.Index() {
+				a.goToTab(i + 1)
+				a.draw()
+			}
 		case *tcell.EventKey:
-			if ev.Key() == tcell.KeyEscape || ev.Key() == tcell.KeyCtrlC {
-				return
-			} else if ev.Key() == tcell.KeyCtrlL {
-				s.Sync()
-			} else if ev.Key() == tcell.KeyCtrlS {
-				// Save into file
-				err := os.WriteFile(*filename, []byte(content.GetContent()), 0644)
-				if err != nil {
-					fmt.Println("Error writing to file: ", err)
-					return
-				}
-				unsavedChanges = false
-
-			} else if ev.Key() == tcell.KeyRight {
-				nextRune := content.Index(c + 1)
-				if nextRune != "\n" && nextRune != "" {
-					c++
-					editor.MoveX(1)
-				}
-			} else if ev.Key() == tcell.KeyLeft {
-				if editor.Cursor.X > 0 {
-					c--
-					editor.MoveX(-1)
-				}
-			} else if ev.Key() == tcell.KeyDown {
-				// Move cursor depending on line length
-
-				minMove := content.SearchChar('\n', c+1)
-				if minMove != -1 {
-					// Note: this moves us after the newline
-					c = minMove
-					editor.MoveY(1)
-					// Check if we can move the pointer foward to the old x position
-					lineEnd := content.SearchChar('\n', c+1)
-					if lineEnd != -1 {
-						// Compute length of the line we move to
-						diff := lineEnd - minMove
-
-						if diff > 0 {
-							if diff <= editor.Cursor.X+editor.StartCol {
-								// Move x to the end of the line (-1 for the newline)
-								editor.SetX(diff - 1)
-							}
-							c += editor.Cursor.X + editor.StartCol
-						} else {
-							editor.ResetX()
-						}
-					} else {
-						editor.ResetX()
-					}
-				}
-			} else if ev.Key() == tcell.KeyUp {
-				if editor.Cursor.Y > 0 {
-					// Find end of last row
-					lineEnd, err := content.SearchCharReverse('\n', c)
-					if lineEnd != -1 && err == nil {
-						// Move up
-						editor.MoveY(-1)
-						// Find start of last row
-						lineStart, err := content.SearchCharReverse('\n', lineEnd-1)
-						if err == nil {
-							if lineStart == -1 {
-								lineStart = 1
-							}
-							c = lineStart
-							// Compute length of the line we move to
-							diff := lineEnd - lineStart
-
-							if diff > 0 {
-								if diff <= editor.Cursor.X+editor.StartCol {
-									// Move x to the end of the line (-1 for the newline)
-									editor.SetX(diff - 1)
-								}
-								c += editor.Cursor.X + editor.StartCol
-							} else {
-								editor.ResetX()
-							}
-						} else {
-							editor.ResetX()
-						}
-					}
-				} else {
-					// Move to the beginning of the file
-					c = 0
-					editor.ResetX()
-				}
-			} else if ev.Key() == tcell.KeyBackspace || ev.Key() == tcell.KeyBS || ev.Key() == tcell.KeyBackspace2 {
-				// Make sure there is something to delete
-				if c > 0 {
-					content = content.Delete(c-1, 1)
-					charCount--
-					c--
-					unsavedChanges = true
-					cachedContent = content.GetContent()
-					// Move cursor
-					if editor.Cursor.X > 0 {
-						editor.MoveX(-1)
-					} else {
-
-						// Find start of last row
-						lineStart, err := content.SearchCharReverse('\n', c)
-						if err == nil {
-							if lineStart == -1 {
-								lineStart = 0
-							}
-							editor.MoveY(-1)
-							// Compute length of the line we move to
-							diff := c - lineStart
-							editor.SetX(diff)
-							editor.NumRows--
-						}
-					}
-				}
-				// Move cursor depending on line length
-			} else if ev.Key() == tcell.KeyEnter {
-				// Insert a newline and move to the next line
-				content = content.Insert(c, string('\n'))
-				charCount++
-				editor.NumRows++
-				editor.ResetX()
-				editor.MoveY(1)
-				c++
-				unsavedChanges = true
-				cachedContent = content.GetContent()
-
-			} else if ev.Key() == tcell.KeyTab || ev.Key() == tcell.KeyTAB {
-				// Tab key, replaces with tabSize number of spaces
-				content = content.Insert(c, strings.Repeat(" ", tabSize))
-				charCount += tabSize
-				editor.MoveX(tabSize)
-				c += tabSize
-				unsavedChanges = true
-				cachedContent = content.GetContent()
-
-			} else {
-				// Catch-all for remaining characters,
-				// adding them to the content at the current cursor position
-				content = content.Insert(c, string(ev.Rune()))
-				charCount++
-				editor.MoveX(1)
-				c++
-				unsavedChanges = true
-				cachedContent = content.GetContent()
+			a.handleKey(ev)
+			if !a.quit {
+				a.servers.update(buffers.Buffers())
+				a.draw()
+			}
+		case *tcell.EventInterrupt:
+			// Something from a language server
+			if f, ok := ev.Data().(func()); ok {
+				f()
+				a.servers.update(buffers.Buffers())
+				a.draw()
 			}
-
-			// === Draw ===
-			editor.DrawFull(cachedContent, *filename, unsavedChanges)
 		}
 	}
+	a.servers.shutdown()
+	for _, b := range buffers.Buffers() {
+		marks.Remember(b)
+	}
+	marksSaveErr = marks.Save()
 }
@@ -0,0 +1,61 @@
+package rope
+
+import (
+	"io"
+	"strings"
+)
+
+// Size of the leaves of a rope read from a file
+const chunkSize = 64 << 10
+
+// Create a rope for the first size bytes of r. Its leaves only know where
+// their text is and read it when it's needed, so only inserted text lives
+// in memory. The text is read once here to count its lines.
+func NewReader(r io.ReaderAt, size int) *Rope {
+	return &Rope{Head: createLazyRope(r, 0, size)}
+}
+
+func createLazyRope(r io.ReaderAt, offset, length int) *Node {
+	if length <= chunkSize {
+		n := &Node{source: r, offset: offset, Weight: length}
+		n.Lines = strings.Count(n.text(0, length), "\n")
+		return n
+	}
+	mid := length / 2
+	left := createLazyRope(r, offset, mid)
+	return &Node{
+		Left:   left,
+		Right:  createLazyRope(r, offset+mid, length-mid),
+		Weight: mid,
+		Lines:  left.TotalLines(),
+	}
+}
+
+// Get bytes start to end of the text of a leaf
+func (n *Node) text(start, end int) string {
+	if n.source == nil {
+		return n.Content[start:end]
+	}
+	buf := make([]byte, end-start)
+	read, _ := n.source.ReadAt(buf, int64(n.offset+start))
+	// A file that got shorter gives less text
+	return string(buf[:read])
+}
+
+// Split a leaf in two at index, returning the second part
+func (n *Node) splitLeaf(index int) *Node {
+	if n.source != nil {
+		moved := &Node{source: n.source, offset: n.offset + index, Weight: n.Weight - index}
+		lines := strings.Count(n.text(0, index), "\n")
+		moved.Lines = n.Lines - lines
+		n.Lines = lines
+		n.Weight = index
+		return moved
+	}
+	moved := &Node{Content: n.Content[index:], Weight: len(n.Content) - index}
+	n.Content = n.Content[:index]
+	n.Weight = len(n.Content)
+	n.Lines = strings.Count(n.Content, "\n")
+	moved.Lines = strings.Count(moved.Content, "\n")
+	return moved
+}
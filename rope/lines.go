@@ -0,0 +1,95 @@
+package rope
+
+import "strings"
+
+// Count the newlines of a node. Like Weight, a node only knows the count of
+// its left child, so this goes down its right side.
+func (n *Node) TotalLines() int {
+	switch {
+	case n == nil:
+		return 0
+	case n.Left == nil && n.Right == nil:
+		return n.Lines
+	case n.Right == nil:
+		// Split leaves nodes with one child, and their counts out of date
+		return n.Left.TotalLines()
+	case n.Left == nil:
+		return n.Right.TotalLines()
+	}
+	return n.Lines + n.Right.TotalLines()
+}
+
+// Count the newlines of the rope
+func (r *Rope) Newlines() int {
+	return r.Head.TotalLines()
+}
+
+// Find the offset of the start of a line, counted from 0. Returns -1 when
+// the rope has fewer lines.
+func (r *Rope) LineStart(line int) int {
+	if line <= 0 {
+		return 0
+	}
+	newline := r.Head.newline(line)
+	if newline == -1 {
+		return -1
+	}
+	return newline + 1
+}
+
+// Find the line an offset is on, counted from 0
+func (r *Rope) LineAt(offset int) int {
+	return r.Head.newlinesBefore(offset)
+}
+
+// Find the offset of the k-th newline of a node, counting from 1, -1 if it
+// has fewer
+func (n *Node) newline(k int) int {
+	switch {
+	case n == nil:
+		return -1
+	case n.Left == nil && n.Right == nil:
+		if k > n.Lines {
+			return -1
+		}
+		text := n.text(0, n.Weight)
+		offset := -1
+		for ; k > 0; k-- {
+			i := strings.IndexByte(text[offset+1:], '\n')
+			if i == -1 {
+				// A file that got shorter
+				return -1
+			}
+			offset += i + 1
+		}
+		return offset
+	case n.Right == nil:
+		return n.Left.newline(k)
+	case n.Left == nil:
+		return n.Right.newline(k)
+	case k <= n.Lines:
+		return n.Left.newline(k)
+	}
+	offset := n.Right.newline(k - n.Lines)
+	if offset == -1 {
+		return -1
+	}
+	return n.Weight + offset
+}
+
+// Count the newlines of a node before an offset
+func (n *Node) newlinesBefore(offset int) int {
+	switch {
+	case n == nil || offset <= 0:
+		return 0
+	case n.Left == nil && n.Right == nil:
+		return strings.Count(n.text(0, min(offset, n.Weight)), "\n")
+	case n.Right == nil:
+		return n.Left.newlinesBefore(offset)
+	case n.Left == nil:
+		return n.Right.newlinesBefore(offset)
+	case offset <= n.Weight:
+		return n.Left.newlinesBefore(offset)
+	}
+	return n.Lines + n.Right.newlinesBefore(offset-n.Weight)
+}
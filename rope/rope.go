@@ -3,6 +3,8 @@ package rope
 import (
 	"errors"
 	"fmt"
+	"io"
+	"iter"
 	"strings"
 )
 
@@ -15,6 +17,11 @@ type Node struct {
 	Right   *Node
 	Content string
 	Weight  int
+	// Newlines in the text of a leaf, or in the left child like Weight
+	Lines int
+	// Where the text of a leaf is read from instead of Content, if anywhere
+	source io.ReaderAt
+	offset int
 }
 
 func New(s string) *Rope {
@@ -25,17 +32,19 @@ func New(s string) *Rope {
 // createRope recursively creates a rope from a string.
 func createRope(s string) *Node {
 	if len(s) <= 5 { // You can adjust this threshold based on your needs
-		return &Node{Content: s, Weight: len(s)}
+		return &Node{Content: s, Weight: len(s), Lines: strings.Count(s, "\n")}
 	}
 
 	mid := (len(s) - 1) / 2
 	leftSubString := s[:mid]
 	rightSubString := s[mid:]
 
+	left := createRope(leftSubString)
 	return &Node{
-		Left:   createRope(leftSubString),
+		Left:   left,
 		Right:  createRope(rightSubString),
 		Weight: mid,
+		Lines:  left.TotalLines(),
 	}
 }
 
@@ -87,7 +96,7 @@ func Index(node *Node, index int) string {
 	if index > node.Weight {
 		return ""
 	}
-	return string(node.Content[index-1])
+	return node.text(index-1, index)
 }
 
 // Collect all leaves of the rope structure
@@ -110,7 +119,7 @@ func Report(n *Node, start, end int) string {
 	if (start > n.Weight || end > n.Weight) && n.Right != nil {
 		content += Report(n.Right, max(start-n.Weight, 1), end-n.Weight)
 	}
-	if start < n.Weight && n.Left != nil {
+	if start <= n.Weight && n.Left != nil {
 		content = Report(n.Left, start, end) + content
 	}
 
@@ -118,9 +127,9 @@ func Report(n *Node, start, end int) string {
 		if n.Weight < start {
 			return ""
 		} else if n.Weight >= end {
-			return n.Content[start-1 : end-1]
+			return n.text(start-1, end-1)
 		}
-		return n.Content[start-1:]
+		return n.text(start-1, n.Weight)
 	}
 	return content
 }
@@ -179,6 +188,7 @@ func Concatenate(node1, node2 *Node) *Node {
 		Left:   node1,
 		Right:  node2,
 		Weight: node1.ComputeTotalWeight(),
+		Lines:  node1.TotalLines(),
 	}
 }
 
@@ -216,7 +226,7 @@ func (r *Rope) Split(index int) *Rope {
 	}
 
 	// Create a new rope with the removed nodes
-	rope := &Rope{Head: &Node{Left: removedNodes[0], Weight: removedNodes[0].ComputeTotalWeight()}}
+	rope := &Rope{Head: &Node{Left: removedNodes[0], Weight: removedNodes[0].ComputeTotalWeight(), Lines: removedNodes[0].TotalLines()}}
 	for i := 1; i < len(removedNodes); i++ {
 		if removedNodes[i] != nil {
 			toConcat := &Rope{removedNodes[i]}
@@ -228,9 +238,11 @@ func (r *Rope) Split(index int) *Rope {
 	// Recompute weights
 	if r.Head.Left != nil {
 		r.Head.Weight = r.Head.Left.ComputeTotalWeight()
+		r.Head.Lines = r.Head.Left.TotalLines()
 	}
 	if rope.Head.Left != nil {
 		rope.Head.Weight = rope.Head.Left.ComputeTotalWeight()
+		rope.Head.Lines = rope.Head.Left.TotalLines()
 	}
 	return rope
 }
@@ -252,16 +264,8 @@ func Split(node *Node, index int) []*Node {
 	}
 	// Check if the split should occurr somewhere within the content
 	if index >= 1 && index < node.Weight {
-		// Create a new node and fill it with content
-		movedContent := node.Content[index:]
-		newNode := &Node{Content: movedContent, Weight: len(movedContent)}
-
-		// Remove the moved content from this node
-		node.Content = node.Content[:index]
-		node.Weight = len(node.Content)
-
-		// Return the newly created node
-		return []*Node{newNode}
+		// Move the content after index to a new node, and return it
+		return []*Node{node.splitLeaf(index)}
 	} else if index == 0 {
 		// Return this node
 		return []*Node{node}
@@ -313,11 +317,28 @@ func (n *Node) GetContent() string {
 	}
 
 	if n.Right == nil && n.Left == nil {
-		return n.Content
+		return n.text(0, n.Weight)
 	}
 	return content
 }
 
+// Go through the text of the leaves, in order
+func (r *Rope) Chunks() iter.Seq[string] {
+	return func(yield func(string) bool) {
+		r.Head.chunks(yield)
+	}
+}
+
+func (n *Node) chunks(yield func(string) bool) bool {
+	if n == nil {
+		return true
+	}
+	if n.Left == nil && n.Right == nil {
+		return n.Weight == 0 || yield(n.text(0, n.Weight))
+	}
+	return n.Left.chunks(yield) && n.Right.chunks(yield)
+}
+
 func (r *Rope) SearchChar(char rune, startFrom int) int {
 	if startFrom < 0 {
 		return -1
@@ -355,7 +376,7 @@ func (n *Node) SearchChar(char rune, index, globalIndex int) int {
 	if index > n.Weight {
 		return -1
 	}
-	c := strings.IndexRune(n.Content[index-1:], char)
+	c := strings.IndexRune(n.text(index-1, n.Weight), char)
 	if c == -1 {
 		return -1
 	}
@@ -407,7 +428,7 @@ func (n *Node) SearchCharReverse(char rune, index, globalIndex int) (int, error)
 	if index > n.Weight {
 		return -1, errors.New("Index out of bounds.")
 	}
-	runes := []rune(n.Content)
+	runes := []rune(n.text(0, n.Weight))
 	c := -2
 	for i := index - 1; i >= 0; i-- {
 		if runes[i] == char {
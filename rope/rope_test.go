@@ -2,6 +2,8 @@ package rope
 
 import (
 	"errors"
+	"math/rand"
+	"strings"
 	"testing"
 )
 
@@ -144,6 +146,19 @@ func TestRopeReport(t *testing.T) {
 	}
 }
 
+// Every part of a text, whatever leaves it starts and ends in
+func TestRopeReportAll(t *testing.T) {
+	testInput := "hello world"
+	rope := New(testInput)
+	for start := 0; start < len(testInput); start++ {
+		for end := start + 1; end <= len(testInput); end++ {
+			if got := rope.Report(start+1, end-start); got != testInput[start:end] {
+				t.Fatalf("Content mismatch. Expected=%s, got=%s", testInput[start:end], got)
+			}
+		}
+	}
+}
+
 func TestRopeSearch(t *testing.T) {
 	testInput := "Ahello_I_am_Aa_rope_AdaAAta_structurezA"
 	testSearch := []struct {
@@ -220,3 +235,135 @@ func TestRopeSearchReverse(t *testing.T) {
 		//fmt.Println("")
 	}
 }
+
+func TestRopeApply(t *testing.T) {
+	testInput := "hello_I_am_a_rope_data_structure"
+	testTransactions := []struct {
+		transaction Transaction
+		expected    string
+	}{
+		{Transaction{{Offset: 0, Text: ">"}, {Offset: 5, Text: ">"}}, ">hello>_I_am_a_rope_data_structure"},
+		{Transaction{{Offset: 13, Length: 4, Text: "tree"}, {Offset: 0, Length: 5, Text: "hi"}}, "hi_I_am_a_tree_data_structure"},
+		{Transaction{{Offset: 5, Length: 1}, {Offset: 7, Length: 1}, {Offset: 10, Length: 1}}, "helloIama_rope_data_structure"},
+		{Transaction{{Offset: len(testInput), Text: "!"}, {Offset: len(testInput) - 1, Length: 1, Text: "E"}}, "hello_I_am_a_rope_data_structurE!"},
+	}
+	for _, v := range testTransactions {
+
+		rope := New(testInput)
+		rope, inverse := rope.Apply(v.transaction)
+		content := rope.GetContent()
+		if content != v.expected {
+			t.Fatalf("Content mismatch. Expected=%s, got=%s", v.expected, content)
+		}
+
+		rope, _ = rope.Apply(inverse)
+		content = rope.GetContent()
+		if content != testInput {
+			t.Fatalf("Inverse did not restore content. Expected=%s, got=%s", testInput, content)
+		}
+	}
+}
+
+func TestTransactionMapOffset(t *testing.T) {
+	transaction := Transaction{{Offset: 2, Text: "ab"}, {Offset: 6, Length: 3}, {Offset: 12, Length: 1, Text: "xyz"}}
+	testOffsets := []struct {
+		offset   int
+		expected int
+	}{
+		{0, 0},
+		{2, 4},
+		{3, 5},
+		{6, 8},
+		{7, 8},
+		{9, 8},
+		{12, 11},
+		{13, 14},
+		{20, 21},
+	}
+	for _, v := range testOffsets {
+		res := transaction.MapOffset(v.offset)
+		if res != v.expected {
+			t.Fatalf("Wrong offset for %d. Expected=%d, got=%d", v.offset, v.expected, res)
+		}
+	}
+}
+
+func TestTransactionApplyToString(t *testing.T) {
+	text := "hello world"
+	tr := Transaction{{Offset: 6, Length: 5, Text: "there"}, {Offset: 0, Text: "oh, "}}
+	if got := tr.ApplyToString(text); got != "oh, hello there" {
+		t.Fatalf("Expected=%q, got=%q", "oh, hello there", got)
+	}
+	r, _ := New(text).Apply(tr)
+	if r.GetContent() != tr.ApplyToString(text) {
+		t.Fatalf("String and rope differ, rope=%q", r.GetContent())
+	}
+	if got := tr.Shift(3).ApplyToString("so " + text); got != "so oh, hello there" {
+		t.Fatalf("Wrong shifted edits, got=%q", got)
+	}
+}
+
+func TestRopeReader(t *testing.T) {
+	text := strings.Repeat("line of a big file\n", 10000)
+	r := NewReader(strings.NewReader(text), len(text))
+	if r.GetContent() != text {
+		t.Fatalf("Content of a rope read from a file differs")
+	}
+	if got := r.Report(chunkSize-2, 10); got != text[chunkSize-3:chunkSize+7] {
+		t.Fatalf("Wrong report across leaves. Expected=%q, got=%q", text[chunkSize-3:chunkSize+7], got)
+	}
+	if r.Index(20) != "l" {
+		t.Fatalf("Wrong character, got=%q", r.Index(20))
+	}
+	if r.SearchChar('\n', chunkSize) != strings.IndexByte(text[chunkSize-1:], '\n')+chunkSize {
+		t.Fatalf("Wrong newline found")
+	}
+
+	tr := Transaction{{Offset: 5, Length: 2, Text: "OF"}, {Offset: chunkSize + 3, Text: "new"}}
+	expected := tr.ApplyToString(text)
+	r, inverse := r.Apply(tr)
+	if r.GetContent() != expected {
+		t.Fatalf("Edit of a rope read from a file failed")
+	}
+	r, _ = r.Apply(inverse)
+	if r.GetContent() != text {
+		t.Fatalf("Undoing the edit failed")
+	}
+}
+
+// Line lookups against the text, through edits that split leaves and
+// leave nodes with one child
+func TestRopeLines(t *testing.T) {
+	text := strings.Repeat("a line\n", 3*chunkSize/7) + "last"
+	r := NewReader(strings.NewReader(text), len(text))
+	random := rand.New(rand.NewSource(1))
+	check := func() {
+		t.Helper()
+		if r.Newlines() != strings.Count(text, "\n") {
+			t.Fatalf("Wrong newline count. Expected=%d, got=%d", strings.Count(text, "\n"), r.Newlines())
+		}
+		for i := 0; i < 50; i++ {
+			offset := random.Intn(len(text) + 1)
+			line := strings.Count(text[:offset], "\n")
+			if r.LineAt(offset) != line {
+				t.Fatalf("Wrong line at %d. Expected=%d, got=%d", offset, line, r.LineAt(offset))
+			}
+			start := strings.LastIndexByte(text[:offset], '\n') + 1
+			if r.LineStart(line) != start {
+				t.Fatalf("Wrong start of line %d. Expected=%d, got=%d", line, start, r.LineStart(line))
+			}
+		}
+		if r.LineStart(strings.Count(text, "\n")+1) != -1 {
+			t.Fatalf("Expected no start for the line after the last")
+		}
+	}
+	check()
+	for i := 0; i < 100; i++ {
+		offset := random.Intn(len(text) + 1)
+		length := random.Intn(chunkSize / 8)
+		tr := Transaction{{Offset: offset, Length: min(length, len(text)-offset), Text: strings.Repeat("new\n", random.Intn(3))}}
+		r, _ = r.Apply(tr)
+		text = tr.ApplyToString(text)
+		check()
+	}
+}
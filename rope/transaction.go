@@ -0,0 +1,97 @@
+package rope
+
+import (
+	"sort"
+	"strings"
+)
+
+// A single change: remove Length bytes at Offset and insert Text in their place
+type Edit struct {
+	Offset int
+	Length int
+	Text   string
+}
+
+// A group of edits made to the same version of a rope.
+// Offsets refer to the content before any of the edits, and the edited
+// regions must not overlap.
+type Transaction []Edit
+
+// Apply all edits of a transaction. Returns the new rope and the transaction
+// that reverts the change.
+func (r *Rope) Apply(t Transaction) (*Rope, Transaction) {
+	edits := t.sorted()
+	inverse := make(Transaction, 0, len(edits))
+	delta := 0
+	for _, e := range edits {
+		removed := ""
+		if e.Length > 0 {
+			removed = r.Report(e.Offset+1, e.Length)
+		}
+		inverse = append(inverse, Edit{Offset: e.Offset + delta, Length: len(e.Text), Text: removed})
+		delta += len(e.Text) - e.Length
+	}
+	// Apply from the back, so that earlier offsets stay valid
+	for i := len(edits) - 1; i >= 0; i-- {
+		e := edits[i]
+		if e.Length > 0 {
+			r = r.Delete(e.Offset, e.Length)
+		}
+		if len(e.Text) > 0 {
+			r = r.Insert(e.Offset, e.Text)
+		}
+	}
+	return r, inverse
+}
+
+// Apply the edits of a transaction to a string, giving what the content of
+// a rope holding it becomes
+func (t Transaction) ApplyToString(s string) string {
+	var out strings.Builder
+	out.Grow(len(s))
+	last := 0
+	for _, e := range t.sorted() {
+		out.WriteString(s[last:e.Offset])
+		out.WriteString(e.Text)
+		last = e.Offset + e.Length
+	}
+	out.WriteString(s[last:])
+	return out.String()
+}
+
+// Compute where an offset ends up after the transaction is applied.
+// Offsets at an insertion are moved past the inserted text, and offsets
+// inside a removed region are moved to its start.
+func (t Transaction) MapOffset(offset int) int {
+	delta := 0
+	for _, e := range t.sorted() {
+		if e.Offset+e.Length <= offset {
+			delta += len(e.Text) - e.Length
+		} else if e.Offset < offset {
+			return e.Offset + delta
+		} else {
+			break
+		}
+	}
+	return offset + delta
+}
+
+func (t Transaction) sorted() Transaction {
+	edits := make(Transaction, len(t))
+	copy(edits, t)
+	sort.SliceStable(edits, func(i, j int) bool {
+		return edits[i].Offset < edits[j].Offset
+	})
+	return edits
+}
+
+// Move all edits by an offset, like edits made to a part of a text to the
+// place of that part in the whole
+func (t Transaction) Shift(offset int) Transaction {
+	shifted := make(Transaction, len(t))
+	for i, e := range t {
+		e.Offset += offset
+		shifted[i] = e
+	}
+	return shifted
+}
@@ -0,0 +1,161 @@
+package syntax
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+)
+
+// Name of the context a grammar starts in
+const MainContext = "main"
+
+// Zero-length matches allowed at one position before giving up on it
+const maxEmptyMatches = 16
+
+// A grammar made of contexts, like the main code or the inside of a block
+// comment. Every context has rules that are tried at each position of the
+// line, the leftmost match wins and rules listed first win ties. A rule can
+// enter another context or leave the current one, the contexts entered form
+// a stack which is the state carried over to the next line.
+type Grammar struct {
+	Name string
+	// Extensions like ".go", or whole file names, of the files the grammar is for
+	Extensions []string
+	// Matches the first line of the files the grammar is for, like a shebang
+	FirstLine *regexp.Regexp
+	contexts  map[string]*Context
+}
+
+type Context struct {
+	// Kind of the text that no rule matches
+	Kind  Kind
+	Rules []Rule
+}
+
+type Rule struct {
+	Pattern *regexp.Regexp
+	Kind    Kind
+	// Kinds of capture groups, by group number, group 0 being the whole match
+	Captures map[int]Kind
+	// Context to enter after the match
+	Push string
+	// Leave the current context after the match, before entering Push
+	Pop bool
+	// The pattern only matches at the start of a line
+	anchored bool
+}
+
+// Create a grammar, contexts must include MainContext and every context
+// that rules push
+func NewGrammar(name string, extensions []string, contexts map[string]*Context) (*Grammar, error) {
+	if _, ok := contexts[MainContext]; !ok {
+		return nil, fmt.Errorf("Grammar %s has no %s context", name, MainContext)
+	}
+	for cname, ctx := range contexts {
+		for i := range ctx.Rules {
+			rule := &ctx.Rules[i]
+			if rule.Push != "" && contexts[rule.Push] == nil {
+				return nil, fmt.Errorf("Grammar %s: context %s pushes unknown context %s", name, cname, rule.Push)
+			}
+			rule.anchored = strings.HasPrefix(rule.Pattern.String(), "^")
+		}
+	}
+	return &Grammar{Name: name, Extensions: extensions, contexts: contexts}, nil
+}
+
+func (g *Grammar) Lex(line string, state State) ([]Token, State) {
+	stack := strings.Fields(string(state))
+	if len(stack) == 0 {
+		stack = []string{MainContext}
+	}
+	tokens := []Token{}
+	pos := 0
+	emptyMatches := 0
+	for pos <= len(line) {
+		ctx := g.contexts[stack[len(stack)-1]]
+		rule, m := ctx.match(line, pos)
+		if rule == nil {
+			tokens = appendToken(tokens, pos, len(line), ctx.Kind)
+			break
+		}
+		if m[0] == m[1] && (emptyMatches >= maxEmptyMatches || (rule.Push == "" && !rule.Pop)) {
+			// Nothing to consume, step over a character to make progress
+			if pos == len(line) {
+				break
+			}
+			_, size := utf8.DecodeRuneInString(line[pos:])
+			tokens = appendToken(tokens, pos, pos+size, ctx.Kind)
+			pos += size
+			emptyMatches = 0
+			continue
+		}
+		tokens = appendToken(tokens, pos, m[0], ctx.Kind)
+		tokens = rule.appendTokens(tokens, m)
+		if rule.Pop && len(stack) > 1 {
+			stack = stack[:len(stack)-1]
+		}
+		if rule.Push != "" {
+			stack = append(stack, rule.Push)
+		}
+		if m[0] == m[1] {
+			emptyMatches++
+		} else {
+			emptyMatches = 0
+		}
+		pos = m[1]
+	}
+	return tokens, State(strings.Join(stack, " "))
+}
+
+// Find the rule with the leftmost match at or after pos, returns the
+// submatch offsets into the whole line
+func (ctx *Context) match(line string, pos int) (*Rule, []int) {
+	var best *Rule
+	var bestMatch []int
+	for i := range ctx.Rules {
+		rule := &ctx.Rules[i]
+		if rule.anchored && pos > 0 {
+			continue
+		}
+		m := rule.Pattern.FindStringSubmatchIndex(line[pos:])
+		if m == nil || (bestMatch != nil && m[0]+pos >= bestMatch[0]) {
+			continue
+		}
+		for j := range m {
+			if m[j] >= 0 {
+				m[j] += pos
+			}
+		}
+		best, bestMatch = rule, m
+	}
+	return best, bestMatch
+}
+
+// Add the tokens of a match, capture groups override the kind of the rule
+func (rule *Rule) appendTokens(tokens []Token, m []int) []Token {
+	if len(rule.Captures) == 0 {
+		return appendToken(tokens, m[0], m[1], rule.Kind)
+	}
+	kinds := make([]Kind, m[1]-m[0])
+	for i := range kinds {
+		kinds[i] = rule.Kind
+	}
+	for group := 0; group*2 < len(m); group++ {
+		kind, ok := rule.Captures[group]
+		if !ok || m[group*2] < 0 {
+			continue
+		}
+		for i := m[group*2]; i < m[group*2+1]; i++ {
+			kinds[i-m[0]] = kind
+		}
+	}
+	start := m[0]
+	for i := range kinds {
+		if kinds[i] != kinds[start-m[0]] {
+			tokens = appendToken(tokens, start, m[0]+i, kinds[start-m[0]])
+			start = m[0] + i
+		}
+	}
+	return appendToken(tokens, start, m[1], kinds[start-m[0]])
+}
@@ -0,0 +1,62 @@
+package syntax
+
+// Source of the lines to highlight, without their newlines
+type Lines interface {
+	Line(i int) string
+}
+
+// Tokens of a file, lexed as far down as they were asked for. The state at
+// the start of every line is kept, so that after an edit only the lines
+// from the changed one down need to be lexed again.
+type Highlighter struct {
+	lexer Lexer
+	// states[i] is the state at the start of line i, known for every lexed line and the one after
+	states []State
+	tokens [][]Token
+}
+
+func NewHighlighter(lexer Lexer) *Highlighter {
+	return &Highlighter{lexer: lexer, states: []State{""}}
+}
+
+func (h *Highlighter) Lexer() Lexer {
+	return h.lexer
+}
+
+// Forget the tokens of a line and all lines below it, after the line changed
+func (h *Highlighter) Invalidate(line int) {
+	line = max(line, 0)
+	if line < len(h.tokens) {
+		h.tokens = h.tokens[:line]
+		h.states = h.states[:line+1]
+	}
+}
+
+// Get the tokens of a line, lexing the lines above it first if needed
+func (h *Highlighter) Tokens(lines Lines, i int) []Token {
+	for len(h.tokens) <= i {
+		n := len(h.tokens)
+		tokens, state := h.lexer.Lex(lines.Line(n), h.states[n])
+		h.tokens = append(h.tokens, tokens)
+		h.states = append(h.states, state)
+	}
+	return h.tokens[i]
+}
+
+// Number of lines lexed so far
+func (h *Highlighter) Lexed() int {
+	return len(h.tokens)
+}
+
+// Get the kind of the text at an offset of a line
+func KindAt(tokens []Token, offset int) Kind {
+	for _, t := range tokens {
+		if offset < t.Start {
+			break
+		}
+		if offset < t.End {
+			return t.Kind
+		}
+	}
+	return Plain
+}
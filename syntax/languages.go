@@ -0,0 +1,226 @@
+package syntax
+
+import (
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// Grammars that can be picked for a file, later ones win
+var grammars = []*Grammar{
+	goGrammar(),
+	jsonGrammar(),
+	yamlGrammar(),
+	markdownGrammar(),
+	shellGrammar(),
+}
+
+// Make a grammar available, replacing one with the same name
+func Register(g *Grammar) {
+	for i, old := range grammars {
+		if strings.EqualFold(old.Name, g.Name) {
+			grammars = append(grammars[:i], grammars[i+1:]...)
+			break
+		}
+	}
+	grammars = append(grammars, g)
+}
+
+func Grammars() []*Grammar {
+	return grammars
+}
+
+// Get a grammar by its name or one of its extensions, nil if there is none
+func ByName(name string) *Grammar {
+	for i := len(grammars) - 1; i >= 0; i-- {
+		if strings.EqualFold(grammars[i].Name, name) {
+			return grammars[i]
+		}
+	}
+	for i := len(grammars) - 1; i >= 0; i-- {
+		if grammars[i].matches("x." + name) {
+			return grammars[i]
+		}
+	}
+	return nil
+}
+
+// Pick the grammar for a file by its name, nil if there is none
+func ForFile(path string) *Grammar {
+	for i := len(grammars) - 1; i >= 0; i-- {
+		if grammars[i].matches(path) {
+			return grammars[i]
+		}
+	}
+	return nil
+}
+
+// Pick the grammar for a file by its name, or else by its first line
+func Detect(path, firstLine string) *Grammar {
+	if g := ForFile(path); g != nil {
+		return g
+	}
+	for i := len(grammars) - 1; i >= 0; i-- {
+		if g := grammars[i]; g.FirstLine != nil && g.FirstLine.MatchString(firstLine) {
+			return g
+		}
+	}
+	// Grammars without a first line pattern are found by the interpreter of a shebang
+	if name := interpreter(firstLine); name != "" {
+		return ByName(name)
+	}
+	return nil
+}
+
+func (g *Grammar) matches(path string) bool {
+	base := strings.ToLower(filepath.Base(path))
+	for _, e := range g.Extensions {
+		e = strings.ToLower(e)
+		if base == e || base == strings.TrimPrefix(e, ".") || (strings.HasPrefix(e, ".") && strings.HasSuffix(base, e)) {
+			return true
+		}
+	}
+	return false
+}
+
+// Get the name of the program a shebang line runs, without a version, like
+// python for "#!/usr/bin/env python3"
+func interpreter(line string) string {
+	if !strings.HasPrefix(line, "#!") {
+		return ""
+	}
+	fields := strings.Fields(line[2:])
+	for i, f := range fields {
+		f = filepath.Base(f)
+		if i == 0 && f == "env" || strings.HasPrefix(f, "-") {
+			continue
+		}
+		return strings.TrimRight(f, "0123456789.")
+	}
+	return ""
+}
+
+func match(pattern string, kind Kind) Rule {
+	return Rule{Pattern: regexp.MustCompile(pattern), Kind: kind}
+}
+
+func captures(pattern string, kind Kind, groups map[int]Kind) Rule {
+	return Rule{Pattern: regexp.MustCompile(pattern), Kind: kind, Captures: groups}
+}
+
+func push(pattern string, kind Kind, context string) Rule {
+	return Rule{Pattern: regexp.MustCompile(pattern), Kind: kind, Push: context}
+}
+
+func pop(pattern string, kind Kind) Rule {
+	return Rule{Pattern: regexp.MustCompile(pattern), Kind: kind, Pop: true}
+}
+
+func mustGrammar(name string, extensions []string, contexts map[string]*Context) *Grammar {
+	g, err := NewGrammar(name, extensions, contexts)
+	if err != nil {
+		panic(err)
+	}
+	return g
+}
+
+func goGrammar() *Grammar {
+	return mustGrammar("go", []string{".go"}, map[string]*Context{
+		MainContext: {Rules: []Rule{
+			match(`//.*`, Comment),
+			push(`/\*`, Comment, "comment"),
+			push("`", String, "raw"),
+			match(`"(\\.|[^"\\])*"`, String),
+			match(`'(\\.|[^'\\])*'`, String),
+			match(`\b(break|case|chan|const|continue|default|defer|else|fallthrough|for|func|go|goto|if|import|interface|map|package|range|return|select|struct|switch|type|var)\b`, Keyword),
+			match(`\b(any|bool|byte|comparable|complex64|complex128|error|float32|float64|int|int8|int16|int32|int64|rune|string|uint|uint8|uint16|uint32|uint64|uintptr)\b`, Type),
+			match(`\b(true|false|nil|iota)\b`, Constant),
+			match(`\b(0[xX][0-9a-fA-F_]+|0[bB][01_]+|0[oO][0-7_]+|[0-9][0-9_]*(\.[0-9_]*)?([eE][+-]?[0-9]+)?i?)\b`, Number),
+			captures(`\b([A-Za-z_]\w*)\s*\(`, Plain, map[int]Kind{1: Function}),
+			match(`[A-Za-z_]\w*`, Plain),
+			match(`[-+*/%&|^<>=!:]+`, Operator),
+		}},
+		"comment": {Kind: Comment, Rules: []Rule{
+			pop(`\*/`, Comment),
+		}},
+		"raw": {Kind: String, Rules: []Rule{
+			pop("`", String),
+		}},
+	})
+}
+
+func jsonGrammar() *Grammar {
+	return mustGrammar("json", []string{".json"}, map[string]*Context{
+		MainContext: {Rules: []Rule{
+			captures(`("(\\.|[^"\\])*")\s*:`, Plain, map[int]Kind{1: Property}),
+			match(`"(\\.|[^"\\])*"`, String),
+			match(`-?[0-9]+(\.[0-9]+)?([eE][+-]?[0-9]+)?`, Number),
+			match(`\b(true|false|null)\b`, Constant),
+		}},
+	})
+}
+
+func yamlGrammar() *Grammar {
+	return mustGrammar("yaml", []string{".yaml", ".yml"}, map[string]*Context{
+		MainContext: {Rules: []Rule{
+			match(`^#.*`, Comment),
+			match(`\s#.*`, Comment),
+			match(`^(---|\.\.\.)`, Keyword),
+			captures(`^\s*(?:(-)\s+)?([^\s#'"-][^#:]*?|"(?:\\.|[^"\\])*"|'[^']*')\s*:(?:\s|$)`, Plain, map[int]Kind{1: Operator, 2: Property}),
+			captures(`^\s*(-)(\s|$)`, Plain, map[int]Kind{1: Operator}),
+			match(`"(\\.|[^"\\])*"|'[^']*'`, String),
+			match(`[&*][\w-]+`, Variable),
+			match(`!!?[\w-]*`, Type),
+			match(`\b(true|false|null|True|False|Null|TRUE|FALSE|NULL)\b|~`, Constant),
+			match(`(^|\s)-?[0-9]+(\.[0-9]+)?\b`, Number),
+			match(`[|>][-+]?\s*$`, Operator),
+		}},
+	})
+}
+
+func markdownGrammar() *Grammar {
+	return mustGrammar("markdown", []string{".md", ".markdown"}, map[string]*Context{
+		MainContext: {Rules: []Rule{
+			push("^\\s*```.*", String, "code"),
+			match(`^#{1,6}(\s.*)?$`, Heading),
+			match(`^\s*>.*`, Comment),
+			captures(`^\s*([-*+]|[0-9]+[.)])\s`, Plain, map[int]Kind{1: Operator}),
+			match("`[^`]*`", String),
+			match(`\*\*[^*]+\*\*|__[^_]+__`, Emphasis),
+			match(`\*[^*\s][^*]*\*|\b_[^_\s][^_]*_\b`, Emphasis),
+			match(`!?\[[^\]]*\]\([^)]*\)|<[a-z]+://[^>]+>`, Link),
+		}},
+		"code": {Kind: String, Rules: []Rule{
+			pop("^\\s*```\\s*$", String),
+		}},
+	})
+}
+
+func shellGrammar() *Grammar {
+	variable := match(`\$(\{[^}]*\}|\w+|[#?@*$!0-9-])`, Variable)
+	g := mustGrammar("shell", []string{".sh", ".bash", ".zsh", ".bashrc", ".zshrc", ".profile"}, map[string]*Context{
+		MainContext: {Rules: []Rule{
+			variable,
+			match(`^#.*`, Comment),
+			match(`\s#.*`, Comment),
+			push(`'`, String, "single"),
+			push(`"`, String, "double"),
+			match(`\b(if|then|else|elif|fi|for|while|until|do|done|case|esac|in|function|return|local|export|readonly|declare|select|time|break|continue)\b`, Keyword),
+			match(`\b(echo|cd|printf|read|set|unset|shift|exit|source|eval|exec|test|trap|alias|wait|kill)\b`, Function),
+			captures(`^\s*([A-Za-z_]\w*)=`, Plain, map[int]Kind{1: Variable}),
+			match(`[A-Za-z_][\w-]*`, Plain),
+			match(`\b[0-9]+\b`, Number),
+			match(`[|&;<>]+`, Operator),
+		}},
+		"single": {Kind: String, Rules: []Rule{
+			pop(`'`, String),
+		}},
+		"double": {Kind: String, Rules: []Rule{
+			match(`\\.`, String),
+			variable,
+			pop(`"`, String),
+		}},
+	})
+	g.FirstLine = regexp.MustCompile(`^#!.*\b(ba|z|k|da)?sh\b`)
+	return g
+}
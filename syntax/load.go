@@ -0,0 +1,47 @@
+package syntax
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Load the .tmLanguage.json and .sublime-syntax grammars in a directory.
+// A directory that does not exist holds no grammars.
+func LoadDir(dir string) ([]*Grammar, error) {
+	entries, err := os.ReadDir(dir)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Name() < entries[j].Name() })
+	grammars := []*Grammar{}
+	errs := []error{}
+	for _, entry := range entries {
+		name := entry.Name()
+		var load func([]byte) (*Grammar, error)
+		switch {
+		case strings.HasSuffix(name, ".tmLanguage.json"):
+			load = LoadTextMate
+		case strings.HasSuffix(name, ".sublime-syntax"):
+			load = LoadSublime
+		default:
+			continue
+		}
+		data, err := os.ReadFile(filepath.Join(dir, name))
+		if err == nil {
+			var g *Grammar
+			if g, err = load(data); err == nil {
+				grammars = append(grammars, g)
+				continue
+			}
+		}
+		errs = append(errs, fmt.Errorf("%s: %w", name, err))
+	}
+	return grammars, errors.Join(errs...)
+}
@@ -0,0 +1,132 @@
+package syntax
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+const sqlGrammar = `{
+	"name": "SQL",
+	"scopeName": "source.sql",
+	"fileTypes": ["sql"],
+	"patterns": [
+		{"include": "#comments"},
+		{"match": "(?i)\\b(select|from|where)\\b", "name": "keyword.other.sql"},
+		{"match": "\\b(\\w+)\\s*(?=\\()", "name": "support.function.sql"},
+		{"begin": "'", "end": "'", "name": "string.quoted.single.sql",
+		 "patterns": [{"match": "''", "name": "constant.character.escape.sql"}]},
+		{"match": "(?x) \\b [0-9]+ \\b  # a number", "name": "constant.numeric.sql"}
+	],
+	"repository": {
+		"comments": {"patterns": [
+			{"match": "--.*$", "name": "comment.line.double-dash.sql"},
+			{"begin": "/\\*", "end": "\\*/", "name": "comment.block.sql"}
+		]}
+	}
+}`
+
+const protoGrammar = `
+name: Protobuf
+file_extensions: [proto]
+scope: source.proto
+variables:
+  ident: '[A-Za-z_]\w*'
+contexts:
+  prototype:
+    - match: '//.*$'
+      scope: comment.line.proto
+  main:
+    - match: '\b(message)\s+({{ident}})'
+      captures:
+        1: keyword.declaration.proto
+        2: entity.name.type.proto
+    - match: '"'
+      scope: punctuation.definition.string.begin.proto
+      push: string
+    - match: '\b(int32|string)\b'
+      scope: storage.type.proto
+    - match: '\b[0-9]+\b'
+      scope: constant.numeric.proto
+  string:
+    - meta_include_prototype: false
+    - meta_scope: string.quoted.double.proto
+    - match: '"'
+      pop: true
+`
+
+func TestLoadTextMate(t *testing.T) {
+	g, err := LoadTextMate([]byte(sqlGrammar))
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := testLines{
+		"SELECT count(*) FROM t WHERE a = 'it''s' -- all",
+		"/* multi",
+		"line */ 42",
+	}
+	h := NewHighlighter(g)
+	// The function rule uses a lookahead, so it is left out
+	checkLine(t, h, lines, 0, "SELECT:keyword FROM:keyword WHERE:keyword 'it''s':string -- all:comment")
+	checkLine(t, h, lines, 1, "/* multi:comment")
+	checkLine(t, h, lines, 2, "line */:comment 42:number")
+}
+
+func TestLoadSublime(t *testing.T) {
+	g, err := LoadSublime([]byte(protoGrammar))
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := testLines{`message Foo { string name = 1; // "x"`, `"a // b"`}
+	h := NewHighlighter(g)
+	checkLine(t, h, lines, 0, `message:keyword Foo:type string:type 1:number // "x":comment`)
+	checkLine(t, h, lines, 1, `"a // b":string`)
+}
+
+func TestLoadDir(t *testing.T) {
+	dir := t.TempDir()
+	os.WriteFile(filepath.Join(dir, "sql.tmLanguage.json"), []byte(sqlGrammar), 0644)
+	os.WriteFile(filepath.Join(dir, "proto.sublime-syntax"), []byte(protoGrammar), 0644)
+	os.WriteFile(filepath.Join(dir, "broken.tmLanguage.json"), []byte("{"), 0644)
+	os.WriteFile(filepath.Join(dir, "notes.txt"), []byte("hi"), 0644)
+
+	grammars, err := LoadDir(dir)
+	if err == nil {
+		t.Fatalf("Broken grammar did not give an error")
+	}
+	if len(grammars) != 2 {
+		t.Fatalf("Expected 2 grammars, got=%d", len(grammars))
+	}
+	for _, g := range grammars {
+		Register(g)
+	}
+	if g := Detect("schema.proto", ""); g == nil || g.Name != "Protobuf" {
+		t.Fatalf("Protobuf grammar not picked by extension")
+	}
+	if g := ByName("sql"); g == nil || g.Name != "SQL" {
+		t.Fatalf("SQL grammar not found by name")
+	}
+}
+
+func TestDetect(t *testing.T) {
+	tests := []struct {
+		path      string
+		firstLine string
+		expected  string
+	}{
+		{"main.go", "", "go"},
+		{"config.YML", "", "yaml"},
+		{"deploy", "#!/bin/bash", "shell"},
+		{"deploy", "#!/usr/bin/env -S zsh -f", "shell"},
+		{"notes", "hello", ""},
+	}
+	for _, tt := range tests {
+		name := ""
+		if g := Detect(tt.path, tt.firstLine); g != nil {
+			name = g.Name
+		}
+		if name != tt.expected {
+			t.Fatalf("Wrong grammar for %s. Expected=%q, got=%q", tt.path, tt.expected, name)
+		}
+	}
+}
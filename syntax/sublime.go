@@ -0,0 +1,211 @@
+package syntax
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// A Sublime Text grammar, as found in .sublime-syntax files
+type sublimeSyntax struct {
+	Name           string                      `yaml:"name"`
+	Scope          string                      `yaml:"scope"`
+	FileExtensions []string                    `yaml:"file_extensions"`
+	FirstLineMatch string                      `yaml:"first_line_match"`
+	Variables      map[string]string           `yaml:"variables"`
+	Contexts       map[string][]map[string]any `yaml:"contexts"`
+}
+
+// Turns the contexts of a Sublime grammar into ours, contexts pushed as
+// anonymous lists get generated names
+type sublimeBuilder struct {
+	syntax    *sublimeSyntax
+	contexts  map[string]*Context
+	anonymous int
+}
+
+// Load a grammar from the contents of a .sublime-syntax file. Rules using
+// regular expressions Go does not support, like lookarounds, are left out,
+// and so is embedding other grammars.
+func LoadSublime(data []byte) (*Grammar, error) {
+	var syn sublimeSyntax
+	if err := yaml.Unmarshal(data, &syn); err != nil {
+		return nil, err
+	}
+	name := syn.Name
+	if name == "" {
+		name = syn.Scope
+	}
+	if name == "" {
+		return nil, errors.New("Grammar has no name")
+	}
+	if syn.Contexts[MainContext] == nil {
+		return nil, fmt.Errorf("Grammar %s has no %s context", name, MainContext)
+	}
+	b := &sublimeBuilder{syntax: &syn, contexts: map[string]*Context{}}
+	b.context(MainContext)
+
+	g, err := NewGrammar(name, extensions(syn.FileExtensions), b.contexts)
+	if err != nil {
+		return nil, err
+	}
+	if syn.FirstLineMatch != "" {
+		g.FirstLine, _ = compileRegexp(b.expand(syn.FirstLineMatch))
+	}
+	return g, nil
+}
+
+// Build a named context, along with the contexts it pushes. Returns false
+// if there is no such context.
+func (b *sublimeBuilder) context(name string) bool {
+	if _, ok := b.contexts[name]; ok {
+		return true
+	}
+	items, ok := b.syntax.Contexts[name]
+	if !ok {
+		return false
+	}
+	b.build(name, items)
+	return true
+}
+
+func (b *sublimeBuilder) build(name string, items []map[string]any) {
+	ctx := &Context{}
+	b.contexts[name] = ctx
+	prototype := name != "prototype"
+	for _, item := range items {
+		if scope, ok := item["meta_content_scope"].(string); ok {
+			ctx.Kind = scopeKind(scope, ctx.Kind)
+		} else if scope, ok := item["meta_scope"].(string); ok && ctx.Kind == Plain {
+			ctx.Kind = scopeKind(scope, ctx.Kind)
+		}
+		if include, ok := item["meta_include_prototype"].(bool); ok && !include {
+			prototype = false
+		}
+	}
+	if prototype && b.syntax.Contexts["prototype"] != nil {
+		ctx.Rules = b.rules(b.syntax.Contexts["prototype"], ctx.Kind, map[string]bool{"prototype": true})
+	}
+	ctx.Rules = append(ctx.Rules, b.rules(items, ctx.Kind, map[string]bool{name: true})...)
+}
+
+// Translate the rules of a context whose text is of a kind. including holds
+// the contexts being included, to stop include cycles.
+func (b *sublimeBuilder) rules(items []map[string]any, kind Kind, including map[string]bool) []Rule {
+	rules := []Rule{}
+	for _, item := range items {
+		if include, ok := item["include"].(string); ok {
+			// Other grammars can't be included
+			if including[include] || strings.HasPrefix(include, "scope:") || strings.Contains(include, ".sublime-syntax") {
+				continue
+			}
+			if included, ok := b.syntax.Contexts[include]; ok {
+				including[include] = true
+				rules = append(rules, b.rules(included, kind, including)...)
+				delete(including, include)
+			}
+			continue
+		}
+		pattern, ok := item["match"].(string)
+		if !ok {
+			continue
+		}
+		re, err := compileRegexp(b.expand(pattern))
+		if err != nil {
+			continue
+		}
+		scope, _ := item["scope"].(string)
+		rule := Rule{Pattern: re, Kind: scopeKind(scope, kind)}
+		rule.Captures = b.captures(item["captures"], rule.Kind)
+
+		target, set := item["set"]
+		if !set {
+			target = item["push"]
+		}
+		if target != nil {
+			rule.Push, ok = b.target(target)
+			if !ok {
+				// Embedding another grammar
+				continue
+			}
+			rule.Pop = set
+		}
+		switch pop := item["pop"].(type) {
+		case bool:
+			rule.Pop = rule.Pop || pop
+		case int:
+			rule.Pop = rule.Pop || pop > 0
+		}
+		rules = append(rules, rule)
+	}
+	return rules
+}
+
+// Get the context a rule pushes, which is a name, an anonymous context or a
+// list of them. Only the last context of a list, the one on top, is pushed.
+func (b *sublimeBuilder) target(target any) (string, bool) {
+	switch t := target.(type) {
+	case string:
+		return t, b.context(t)
+	case []any:
+		if len(t) == 0 {
+			return "", false
+		}
+		if _, ok := t[0].(map[string]any); ok {
+			items := []map[string]any{}
+			for _, item := range t {
+				if m, ok := item.(map[string]any); ok {
+					items = append(items, m)
+				}
+			}
+			b.anonymous++
+			name := fmt.Sprintf("anonymous%d", b.anonymous)
+			b.build(name, items)
+			return name, true
+		}
+		return b.target(t[len(t)-1])
+	}
+	return "", false
+}
+
+// Get the kinds of the capture groups that have one
+func (b *sublimeBuilder) captures(captures any, kind Kind) map[int]Kind {
+	kinds := map[int]Kind{}
+	add := func(group string, scope any) {
+		n, err := strconv.Atoi(group)
+		name, ok := scope.(string)
+		if err != nil || !ok {
+			return
+		}
+		if k := scopeKind(name, kind); k != kind {
+			kinds[n] = k
+		}
+	}
+	switch c := captures.(type) {
+	case map[string]any:
+		for group, scope := range c {
+			add(group, scope)
+		}
+	case map[any]any:
+		for group, scope := range c {
+			add(fmt.Sprint(group), scope)
+		}
+	}
+	return kinds
+}
+
+// Replace {{variables}} in a pattern, variables can use other variables
+func (b *sublimeBuilder) expand(pattern string) string {
+	for range 10 {
+		if !strings.Contains(pattern, "{{") {
+			break
+		}
+		for name, value := range b.syntax.Variables {
+			pattern = strings.ReplaceAll(pattern, "{{"+name+"}}", value)
+		}
+	}
+	return pattern
+}
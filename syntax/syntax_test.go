@@ -0,0 +1,91 @@
+package syntax
+
+import (
+	"strings"
+	"testing"
+)
+
+type testLines []string
+
+func (l testLines) Line(i int) string {
+	if i >= len(l) {
+		return ""
+	}
+	return l[i]
+}
+
+// Describe the tokens of a line as "text:kind" pieces, plain text left out
+func describe(line string, tokens []Token) string {
+	parts := []string{}
+	for _, t := range tokens {
+		if t.Kind != Plain {
+			parts = append(parts, line[t.Start:t.End]+":"+t.Kind.String())
+		}
+	}
+	return strings.Join(parts, " ")
+}
+
+func checkLine(t *testing.T, h *Highlighter, lines testLines, i int, expected string) {
+	t.Helper()
+	got := describe(lines[i], h.Tokens(lines, i))
+	if got != expected {
+		t.Fatalf("Wrong tokens on line %d. Expected=%q, got=%q", i, expected, got)
+	}
+}
+
+func TestGoGrammar(t *testing.T) {
+	lines := testLines{
+		`func main() { // hi`,
+		`	x := "a // b" /* c`,
+		`d */ return 0x1F`,
+	}
+	h := NewHighlighter(ForFile("main.go"))
+	checkLine(t, h, lines, 0, "func:keyword main:function // hi:comment")
+	checkLine(t, h, lines, 1, `:=:operator "a // b":string /* c:comment`)
+	checkLine(t, h, lines, 2, "d */:comment return:keyword 0x1F:number")
+}
+
+func TestHighlighterInvalidate(t *testing.T) {
+	lines := testLines{"a := 1", "b := 2", "c := 3"}
+	h := NewHighlighter(ByName("go"))
+	checkLine(t, h, lines, 2, ":=:operator 3:number")
+	if h.Lexed() != 3 {
+		t.Fatalf("Expected 3 lexed lines, got=%d", h.Lexed())
+	}
+
+	// Opening a comment on line 1 changes everything below it
+	lines[1] = "b /* 2"
+	h.Invalidate(1)
+	if h.Lexed() != 1 {
+		t.Fatalf("Lines above the change should stay lexed, got=%d", h.Lexed())
+	}
+	checkLine(t, h, lines, 1, "/* 2:comment")
+	checkLine(t, h, lines, 2, "c := 3:comment")
+}
+
+func TestDataGrammars(t *testing.T) {
+	json := testLines{`{"name": "nut", "n": -1.5, "ok": true}`}
+	checkLine(t, NewHighlighter(ForFile("a.json")), json, 0, `"name":property "nut":string "n":property -1.5:number "ok":property true:constant`)
+
+	yaml := testLines{"# config", "- name: 'x' # c", "  on: true"}
+	h := NewHighlighter(ForFile("a.yml"))
+	checkLine(t, h, yaml, 0, "# config:comment")
+	checkLine(t, h, yaml, 1, "-:operator name:property 'x':string  # c:comment")
+	checkLine(t, h, yaml, 2, "on:property true:constant")
+}
+
+func TestMarkdownGrammar(t *testing.T) {
+	lines := testLines{"# Title", "Some **bold** `code`", "```go", "# not a heading", "```"}
+	h := NewHighlighter(ForFile("README.md"))
+	checkLine(t, h, lines, 0, "# Title:heading")
+	checkLine(t, h, lines, 1, "**bold**:emphasis `code`:string")
+	checkLine(t, h, lines, 3, "# not a heading:string")
+	checkLine(t, h, lines, 4, "```:string")
+}
+
+func TestShellGrammar(t *testing.T) {
+	lines := testLines{`if [ "$HOME" ]; then echo 'a`, `b' # done`}
+	h := NewHighlighter(ForFile("run.sh"))
+	checkLine(t, h, lines, 0, `if:keyword ":string $HOME:variable ":string ;:operator then:keyword echo:function 'a:string`)
+	checkLine(t, h, lines, 1, "b':string  # done:comment")
+}
@@ -0,0 +1,350 @@
+package syntax
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// A TextMate grammar, as found in .tmLanguage.json files
+type tmGrammar struct {
+	Name           string             `json:"name"`
+	ScopeName      string             `json:"scopeName"`
+	FileTypes      []string           `json:"fileTypes"`
+	FirstLineMatch string             `json:"firstLineMatch"`
+	Patterns       []*tmRule          `json:"patterns"`
+	Repository     map[string]*tmRule `json:"repository"`
+}
+
+type tmRule struct {
+	Include             string               `json:"include"`
+	Name                string               `json:"name"`
+	ContentName         string               `json:"contentName"`
+	Match               string               `json:"match"`
+	Begin               string               `json:"begin"`
+	End                 string               `json:"end"`
+	While               string               `json:"while"`
+	Captures            map[string]tmCapture `json:"captures"`
+	BeginCaptures       map[string]tmCapture `json:"beginCaptures"`
+	EndCaptures         map[string]tmCapture `json:"endCaptures"`
+	Patterns            []*tmRule            `json:"patterns"`
+	ApplyEndPatternLast json.RawMessage      `json:"applyEndPatternLast"`
+}
+
+type tmCapture struct {
+	Name string `json:"name"`
+}
+
+// Turns a TextMate grammar into contexts. Every begin/end rule becomes a
+// context, made once for each kind of text it is found in since unnamed
+// rules take the kind of their surroundings.
+type tmBuilder struct {
+	grammar  *tmGrammar
+	contexts map[string]*Context
+	made     map[tmContextKey]string
+}
+
+type tmContextKey struct {
+	rule *tmRule
+	kind Kind
+}
+
+// Load a grammar from the contents of a .tmLanguage.json file. Rules using
+// regular expressions Go does not support, like lookarounds or back
+// references, are left out.
+func LoadTextMate(data []byte) (*Grammar, error) {
+	var tm tmGrammar
+	if err := json.Unmarshal(data, &tm); err != nil {
+		return nil, err
+	}
+	name := tm.Name
+	if name == "" {
+		name = tm.ScopeName
+	}
+	if name == "" {
+		return nil, errors.New("Grammar has no name")
+	}
+	b := &tmBuilder{
+		grammar:  &tm,
+		contexts: map[string]*Context{},
+		made:     map[tmContextKey]string{},
+	}
+	b.contexts[MainContext] = &Context{Rules: b.rules(tm.Patterns, Plain, map[string]bool{})}
+
+	g, err := NewGrammar(name, extensions(tm.FileTypes), b.contexts)
+	if err != nil {
+		return nil, err
+	}
+	if tm.FirstLineMatch != "" {
+		g.FirstLine, _ = compileRegexp(tm.FirstLineMatch)
+	}
+	return g, nil
+}
+
+// Translate a list of patterns found in text of a kind. including holds the
+// repository entries being included, to stop include cycles.
+func (b *tmBuilder) rules(patterns []*tmRule, kind Kind, including map[string]bool) []Rule {
+	rules := []Rule{}
+	for _, p := range patterns {
+		if p == nil {
+			continue
+		}
+		switch {
+		case p.Include != "":
+			rules = append(rules, b.include(p.Include, kind, including)...)
+		case p.Match != "":
+			re, err := compileRegexp(p.Match)
+			if err != nil {
+				continue
+			}
+			ruleKind := scopeKind(p.Name, kind)
+			rules = append(rules, Rule{Pattern: re, Kind: ruleKind, Captures: captureKinds(p.Captures, ruleKind)})
+		case p.Begin != "":
+			if rule, ok := b.beginEnd(p, kind); ok {
+				rules = append(rules, rule)
+			}
+		case len(p.Patterns) > 0:
+			rules = append(rules, b.rules(p.Patterns, scopeKind(p.Name, kind), including)...)
+		}
+	}
+	return rules
+}
+
+func (b *tmBuilder) include(name string, kind Kind, including map[string]bool) []Rule {
+	if name == "$self" || name == "$base" {
+		name = "$self"
+		if including[name] {
+			return nil
+		}
+		including[name] = true
+		defer delete(including, name)
+		return b.rules(b.grammar.Patterns, kind, including)
+	}
+	// Other grammars can't be included
+	if !strings.HasPrefix(name, "#") {
+		return nil
+	}
+	entry := b.grammar.Repository[name[1:]]
+	if entry == nil || including[name] {
+		return nil
+	}
+	including[name] = true
+	defer delete(including, name)
+	return b.rules([]*tmRule{entry}, kind, including)
+}
+
+// Translate a begin/end rule into a rule entering a context, which the end
+// pattern leaves
+func (b *tmBuilder) beginEnd(p *tmRule, kind Kind) (Rule, bool) {
+	begin, err := compileRegexp(p.Begin)
+	if err != nil {
+		return Rule{}, false
+	}
+	ruleKind := scopeKind(p.Name, kind)
+	beginCaptures := p.BeginCaptures
+	if beginCaptures == nil {
+		beginCaptures = p.Captures
+	}
+	rule := Rule{Pattern: begin, Kind: ruleKind, Captures: captureKinds(beginCaptures, ruleKind)}
+	if p.End == "" {
+		// Begin/while rules only highlight their first line
+		return rule, true
+	}
+
+	key := tmContextKey{rule: p, kind: kind}
+	if name, ok := b.made[key]; ok {
+		rule.Push = name
+		return rule, name != ""
+	}
+	end, err := compileRegexp(p.End)
+	if err != nil {
+		b.made[key] = ""
+		return Rule{}, false
+	}
+	name := fmt.Sprintf("context%d", len(b.made)+1)
+	b.made[key] = name
+	ctx := &Context{Kind: scopeKind(p.ContentName, ruleKind)}
+	b.contexts[name] = ctx
+
+	endCaptures := p.EndCaptures
+	if endCaptures == nil {
+		endCaptures = p.Captures
+	}
+	endRule := Rule{Pattern: end, Kind: ruleKind, Captures: captureKinds(endCaptures, ruleKind), Pop: true}
+	inner := b.rules(p.Patterns, ctx.Kind, map[string]bool{})
+	if truthy(p.ApplyEndPatternLast) {
+		ctx.Rules = append(inner, endRule)
+	} else {
+		ctx.Rules = append([]Rule{endRule}, inner...)
+	}
+	rule.Push = name
+	return rule, true
+}
+
+// Get the kinds of the capture groups that have one, group 0 is the whole match
+func captureKinds(captures map[string]tmCapture, kind Kind) map[int]Kind {
+	kinds := map[int]Kind{}
+	for group, c := range captures {
+		n, err := strconv.Atoi(group)
+		if err != nil {
+			continue
+		}
+		if k := scopeKind(c.Name, kind); k != kind {
+			kinds[n] = k
+		}
+	}
+	return kinds
+}
+
+func truthy(raw json.RawMessage) bool {
+	s := strings.TrimSpace(string(raw))
+	return s == "true" || s == "1"
+}
+
+// Turn file types like "tf" into extensions like ".tf"
+func extensions(fileTypes []string) []string {
+	exts := []string{}
+	for _, t := range fileTypes {
+		if !strings.HasPrefix(t, ".") {
+			t = "." + t
+		}
+		exts = append(exts, t)
+	}
+	return exts
+}
+
+// Kinds of the scope names used by TextMate and Sublime grammars, the
+// longest matching prefix wins
+var scopeKinds = map[string]Kind{
+	"comment":                        Comment,
+	"punctuation.definition.comment": Comment,
+	"string":                         String,
+	"punctuation.definition.string":  String,
+	"string.other.link":              Link,
+	"constant":                       Constant,
+	"constant.numeric":               Number,
+	"constant.character.escape":      String,
+	"keyword":                        Keyword,
+	"keyword.operator":               Operator,
+	"storage":                        Keyword,
+	"storage.type":                   Type,
+	"entity.name.function":           Function,
+	"support.function":               Function,
+	"entity.name.type":               Type,
+	"entity.name.class":              Type,
+	"support.type":                   Type,
+	"support.class":                  Type,
+	"support.type.property-name":     Property,
+	"entity.name.tag":                Keyword,
+	"entity.other.attribute-name":    Property,
+	"variable":                       Variable,
+	"variable.language":              Constant,
+	"variable.other.property":        Property,
+	"variable.other.member":          Property,
+	"entity.name.section":            Heading,
+	"markup.heading":                 Heading,
+	"markup.bold":                    Emphasis,
+	"markup.italic":                  Emphasis,
+	"markup.underline.link":          Link,
+	"markup.raw":                     String,
+}
+
+// Get the kind of a scope name, which can hold several scopes. The last
+// scope with a known kind wins, fallback is used when there is none.
+func scopeKind(name string, fallback Kind) Kind {
+	kind := fallback
+	for _, scope := range strings.Fields(name) {
+		best := ""
+		for prefix, k := range scopeKinds {
+			if (scope == prefix || strings.HasPrefix(scope, prefix+".")) && len(prefix) > len(best) {
+				best = prefix
+				kind = k
+			}
+		}
+	}
+	return kind
+}
+
+// Compile a regular expression written for Oniguruma, the engine used by
+// TextMate and Sublime grammars
+func compileRegexp(pattern string) (*regexp.Regexp, error) {
+	return regexp.Compile(convertRegexp(pattern))
+}
+
+// Rewrite the Oniguruma syntax that Go's regexp lacks where it can be done:
+// extended mode, \h for hex digits, \Z, \G, atomic groups and possessive
+// quantifiers
+func convertRegexp(pattern string) string {
+	extended := false
+	if strings.HasPrefix(pattern, "(?x)") {
+		extended = true
+		pattern = pattern[4:]
+	}
+	var out strings.Builder
+	inClass := false
+	for i := 0; i < len(pattern); i++ {
+		c := pattern[i]
+		switch {
+		case c == '\\' && i+1 < len(pattern):
+			i++
+			switch pattern[i] {
+			case 'h':
+				if inClass {
+					out.WriteString("0-9a-fA-F")
+				} else {
+					out.WriteString("[0-9a-fA-F]")
+				}
+			case 'Z':
+				out.WriteString("$")
+			case 'G':
+				// Matching where the last match ended is what happens anyway most of the time
+			default:
+				out.WriteByte('\\')
+				out.WriteByte(pattern[i])
+			}
+		case inClass:
+			if c == '[' && strings.HasPrefix(pattern[i:], "[:") {
+				// Named classes like [:alpha:]
+				end := strings.Index(pattern[i:], ":]")
+				if end != -1 {
+					out.WriteString(pattern[i : i+end+2])
+					i += end + 1
+					continue
+				}
+			}
+			if c == ']' {
+				inClass = false
+			}
+			out.WriteByte(c)
+		case c == '[':
+			inClass = true
+			out.WriteByte(c)
+			// A ] right at the start of a class is part of it
+			if strings.HasPrefix(pattern[i+1:], "^") {
+				i++
+				out.WriteByte('^')
+			}
+			if strings.HasPrefix(pattern[i+1:], "]") {
+				i++
+				out.WriteString(`\]`)
+			}
+		case extended && (c == ' ' || c == '\t' || c == '\n' || c == '\r'):
+		case extended && c == '#':
+			for i+1 < len(pattern) && pattern[i+1] != '\n' {
+				i++
+			}
+		case c == '(' && strings.HasPrefix(pattern[i:], "(?>"):
+			out.WriteString("(?:")
+			i += 2
+		case (c == '*' || c == '+' || c == '?' || c == '}') && strings.HasPrefix(pattern[i+1:], "+"):
+			out.WriteByte(c)
+			i++
+		default:
+			out.WriteByte(c)
+		}
+	}
+	return out.String()
+}
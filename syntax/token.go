@@ -0,0 +1,94 @@
+package syntax
+
+// Kind of a piece of text, which decides how it is colored
+type Kind int
+
+const (
+	Plain Kind = iota
+	Comment
+	Keyword
+	Type
+	String
+	Number
+	Constant
+	Function
+	Operator
+	Variable
+	Property
+	Heading
+	Emphasis
+	Link
+	numKinds
+)
+
+var kindNames = [numKinds]string{
+	Plain:    "plain",
+	Comment:  "comment",
+	Keyword:  "keyword",
+	Type:     "type",
+	String:   "string",
+	Number:   "number",
+	Constant: "constant",
+	Function: "function",
+	Operator: "operator",
+	Variable: "variable",
+	Property: "property",
+	Heading:  "heading",
+	Emphasis: "emphasis",
+	Link:     "link",
+}
+
+func (k Kind) String() string {
+	if k < 0 || k >= numKinds {
+		return "plain"
+	}
+	return kindNames[k]
+}
+
+// Get a kind by its name
+func KindByName(name string) (Kind, bool) {
+	for k, n := range kindNames {
+		if n == name {
+			return Kind(k), true
+		}
+	}
+	return Plain, false
+}
+
+// Kinds lists every kind
+func Kinds() []Kind {
+	kinds := make([]Kind, numKinds)
+	for k := range kinds {
+		kinds[k] = Kind(k)
+	}
+	return kinds
+}
+
+// A piece of a line, as [Start, End) byte offsets into the line
+type Token struct {
+	Start int
+	End   int
+	Kind  Kind
+}
+
+// State of a lexer between two lines, like being inside a block comment.
+// The empty state is the start of a file.
+type State string
+
+// Something that splits lines into tokens. Lines are lexed in order, each
+// starting in the state the previous line ended in.
+type Lexer interface {
+	Lex(line string, state State) ([]Token, State)
+}
+
+// Add a token, merging it with the last one if they are of the same kind
+func appendToken(tokens []Token, start, end int, kind Kind) []Token {
+	if start >= end {
+		return tokens
+	}
+	if n := len(tokens); n > 0 && tokens[n-1].End == start && tokens[n-1].Kind == kind {
+		tokens[n-1].End = end
+		return tokens
+	}
+	return append(tokens, Token{Start: start, End: end, Kind: kind})
+}
@@ -0,0 +1,3 @@
+module NutCode/text
+
+go 1.23
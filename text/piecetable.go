@@ -0,0 +1,191 @@
+package text
+
+import (
+	"iter"
+	"strings"
+)
+
+// A TextBuffer kept as pieces of the original text and of the text added
+// since. Edits only change the list of pieces, text is never moved.
+type PieceTable struct {
+	original string
+	added    []byte
+	pieces   []piece
+	length   int
+}
+
+// Part of the text, taken from the original or the added text
+type piece struct {
+	added    bool
+	start    int
+	length   int
+	newlines int
+}
+
+func NewPieceTable(s string) *PieceTable {
+	p := &PieceTable{original: s, length: len(s)}
+	if s != "" {
+		p.pieces = []piece{{start: 0, length: len(s), newlines: strings.Count(s, "\n")}}
+	}
+	return p
+}
+
+// Get the text of a piece
+func (p *PieceTable) text(pc piece) string {
+	if pc.added {
+		return string(p.added[pc.start : pc.start+pc.length])
+	}
+	return p.original[pc.start : pc.start+pc.length]
+}
+
+// Get the part of a piece from start up to end, counting newlines again
+func (p *PieceTable) cut(pc piece, start, end int) piece {
+	cut := piece{added: pc.added, start: pc.start + start, length: end - start}
+	cut.newlines = strings.Count(p.text(cut), "\n")
+	return cut
+}
+
+// Find the piece holding an offset, and where the offset is in it. The end
+// of the text gives the number of pieces.
+func (p *PieceTable) find(offset int) (int, int) {
+	for i, pc := range p.pieces {
+		if offset < pc.length {
+			return i, offset
+		}
+		offset -= pc.length
+	}
+	return len(p.pieces), 0
+}
+
+func (p *PieceTable) Len() int {
+	return p.length
+}
+
+func (p *PieceTable) Insert(offset int, s string) {
+	if s == "" {
+		return
+	}
+	offset = clamp(offset, p.length)
+	i, inner := p.find(offset)
+	p.length += len(s)
+	// Typing goes on at the end of the last added piece, which can grow
+	if inner == 0 && i > 0 {
+		last := &p.pieces[i-1]
+		if last.added && last.start+last.length == len(p.added) {
+			p.added = append(p.added, s...)
+			last.length += len(s)
+			last.newlines += strings.Count(s, "\n")
+			return
+		}
+	}
+	added := piece{added: true, start: len(p.added), length: len(s), newlines: strings.Count(s, "\n")}
+	p.added = append(p.added, s...)
+	if inner == 0 {
+		p.pieces = append(p.pieces[:i], append([]piece{added}, p.pieces[i:]...)...)
+		return
+	}
+	pc := p.pieces[i]
+	split := []piece{p.cut(pc, 0, inner), added, p.cut(pc, inner, pc.length)}
+	p.pieces = append(p.pieces[:i], append(split, p.pieces[i+1:]...)...)
+}
+
+func (p *PieceTable) Delete(offset, length int) {
+	offset = clamp(offset, p.length)
+	end := offset + clamp(length, p.length-offset)
+	if end == offset {
+		return
+	}
+	pieces := make([]piece, 0, len(p.pieces)+1)
+	pos := 0
+	for _, pc := range p.pieces {
+		pcEnd := pos + pc.length
+		switch {
+		case pcEnd <= offset || pos >= end:
+			pieces = append(pieces, pc)
+		default:
+			// Keep what is before and after the deleted part
+			if pos < offset {
+				pieces = append(pieces, p.cut(pc, 0, offset-pos))
+			}
+			if pcEnd > end {
+				pieces = append(pieces, p.cut(pc, end-pos, pc.length))
+			}
+		}
+		pos = pcEnd
+	}
+	p.pieces = pieces
+	p.length -= end - offset
+}
+
+func (p *PieceTable) Slice(start, end int) string {
+	start, end = clamp(start, p.length), clamp(end, p.length)
+	if start >= end {
+		return ""
+	}
+	var out strings.Builder
+	out.Grow(end - start)
+	pos := 0
+	for _, pc := range p.pieces {
+		pcEnd := pos + pc.length
+		if pcEnd > start && pos < end {
+			text := p.text(pc)
+			out.WriteString(text[max(start-pos, 0):min(end-pos, pc.length)])
+		}
+		if pcEnd >= end {
+			break
+		}
+		pos = pcEnd
+	}
+	return out.String()
+}
+
+func (p *PieceTable) String() string {
+	return p.Slice(0, p.length)
+}
+
+func (p *PieceTable) LineCount() int {
+	lines := 1
+	for _, pc := range p.pieces {
+		lines += pc.newlines
+	}
+	return lines
+}
+
+func (p *PieceTable) LineStart(line int) int {
+	if line <= 0 {
+		return 0
+	}
+	pos := 0
+	for _, pc := range p.pieces {
+		if pc.newlines >= line {
+			// The line starts in this piece
+			return pos + lineStart(func(yield func(string) bool) { yield(p.text(pc)) }, line)
+		}
+		line -= pc.newlines
+		pos += pc.length
+	}
+	return p.length
+}
+
+func (p *PieceTable) LineAt(offset int) int {
+	offset = clamp(offset, p.length)
+	line := 0
+	for _, pc := range p.pieces {
+		if offset <= pc.length {
+			return line + strings.Count(p.text(pc)[:offset], "\n")
+		}
+		line += pc.newlines
+		offset -= pc.length
+	}
+	return line
+}
+
+func (p *PieceTable) Chunks() iter.Seq[string] {
+	return func(yield func(string) bool) {
+		for _, pc := range p.pieces {
+			if !yield(p.text(pc)) {
+				return
+			}
+		}
+	}
+}
@@ -0,0 +1,86 @@
+package text
+
+import (
+	"NutCode/rope"
+	"io"
+	"iter"
+	"strings"
+)
+
+// A TextBuffer kept in a rope
+type Rope struct {
+	r      *rope.Rope
+	length int
+}
+
+func NewRope(s string) *Rope {
+	return &Rope{r: rope.New(s), length: len(s)}
+}
+
+// Create a rope reading the first size bytes of r when it needs them, see
+// rope.NewReader
+func NewRopeReader(r io.ReaderAt, size int) *Rope {
+	return &Rope{r: rope.NewReader(r, size), length: size}
+}
+
+func (r *Rope) Len() int {
+	return r.length
+}
+
+func (r *Rope) Insert(offset int, s string) {
+	if s == "" {
+		return
+	}
+	r.r = r.r.Insert(clamp(offset, r.length), s)
+	r.length += len(s)
+}
+
+func (r *Rope) Delete(offset, length int) {
+	offset = clamp(offset, r.length)
+	length = clamp(length, r.length-offset)
+	if length == 0 {
+		return
+	}
+	r.r = r.r.Delete(offset, length)
+	r.length -= length
+}
+
+func (r *Rope) Slice(start, end int) string {
+	start, end = clamp(start, r.length), clamp(end, r.length)
+	if start >= end {
+		return ""
+	}
+	// Report counts from 1
+	return r.r.Report(start+1, end-start)
+}
+
+func (r *Rope) String() string {
+	var out strings.Builder
+	out.Grow(r.length)
+	for chunk := range r.Chunks() {
+		out.WriteString(chunk)
+	}
+	return out.String()
+}
+
+// The rope counts the newlines of its leaves, lines are found without
+// going through the whole text
+
+func (r *Rope) LineCount() int {
+	return r.r.Newlines() + 1
+}
+
+func (r *Rope) LineStart(line int) int {
+	if start := r.r.LineStart(line); start != -1 {
+		return start
+	}
+	return r.length
+}
+
+func (r *Rope) LineAt(offset int) int {
+	return r.r.LineAt(clamp(offset, r.length))
+}
+
+func (r *Rope) Chunks() iter.Seq[string] {
+	return r.r.Chunks()
+}
@@ -0,0 +1,82 @@
+// Package text holds the data structures the editor can keep the content of
+// a buffer in, all behind the TextBuffer interface.
+package text
+
+import (
+	"NutCode/rope"
+	"iter"
+	"slices"
+	"strings"
+)
+
+// Text that can be edited. Offsets are bytes counted from 0, and lines are
+// counted from 0 too. Offsets out of the text are moved to its start or end.
+type TextBuffer interface {
+	// Number of bytes of the text
+	Len() int
+	Insert(offset int, s string)
+	Delete(offset, length int)
+	// Get the text from start up to end
+	Slice(start, end int) string
+	String() string
+	// Number of lines, which is one more than the number of newlines
+	LineCount() int
+	// Offset of the start of a line, Len() for lines after the last one
+	LineStart(line int) int
+	// Line an offset is on
+	LineAt(offset int) int
+	// The text in pieces, in order
+	Chunks() iter.Seq[string]
+}
+
+// Apply the edits of a transaction to a text, returning the transaction
+// that reverts them
+func Apply(b TextBuffer, t rope.Transaction) rope.Transaction {
+	edits := slices.Clone(t)
+	slices.SortStableFunc(edits, func(a, b rope.Edit) int {
+		return a.Offset - b.Offset
+	})
+	inverse := make(rope.Transaction, 0, len(edits))
+	delta := 0
+	for _, e := range edits {
+		removed := b.Slice(e.Offset, e.Offset+e.Length)
+		inverse = append(inverse, rope.Edit{Offset: e.Offset + delta, Length: len(e.Text), Text: removed})
+		delta += len(e.Text) - e.Length
+	}
+	// Apply from the back, so that earlier offsets stay valid
+	for i := len(edits) - 1; i >= 0; i-- {
+		e := edits[i]
+		b.Delete(e.Offset, e.Length)
+		b.Insert(e.Offset, e.Text)
+	}
+	return inverse
+}
+
+// Keep an offset inside a text of a length
+func clamp(offset, length int) int {
+	return max(0, min(offset, length))
+}
+
+// Find the start of a line by going through the chunks of a text
+func lineStart(chunks iter.Seq[string], line int) int {
+	if line <= 0 {
+		return 0
+	}
+	offset := 0
+	for chunk := range chunks {
+		for {
+			i := strings.IndexByte(chunk, '\n')
+			if i == -1 {
+				break
+			}
+			line--
+			offset += i + 1
+			chunk = chunk[i+1:]
+			if line == 0 {
+				return offset
+			}
+		}
+		offset += len(chunk)
+	}
+	return offset
+}
@@ -0,0 +1,185 @@
+package text
+
+import (
+	"NutCode/rope"
+	"math/rand"
+	"strings"
+	"testing"
+)
+
+// The implementations of TextBuffer, which all have to pass the same tests
+var backends = []struct {
+	name string
+	new  func(s string) TextBuffer
+}{
+	{"rope", func(s string) TextBuffer { return NewRope(s) }},
+	{"piecetable", func(s string) TextBuffer { return NewPieceTable(s) }},
+	{"ropereader", func(s string) TextBuffer { return NewRopeReader(strings.NewReader(s), len(s)) }},
+}
+
+// Check everything a text tells against the string it should hold
+func checkText(t *testing.T, b TextBuffer, expected string) {
+	t.Helper()
+	if b.Len() != len(expected) {
+		t.Fatalf("Wrong length. Expected=%d, got=%d", len(expected), b.Len())
+	}
+	if b.String() != expected {
+		t.Fatalf("Wrong content. Expected=%q, got=%q", expected, b.String())
+	}
+	chunks := ""
+	for chunk := range b.Chunks() {
+		chunks += chunk
+	}
+	if chunks != expected {
+		t.Fatalf("Wrong chunks. Expected=%q, got=%q", expected, chunks)
+	}
+	lines := strings.Split(expected, "\n")
+	if b.LineCount() != len(lines) {
+		t.Fatalf("Wrong line count. Expected=%d, got=%d", len(lines), b.LineCount())
+	}
+	offset := 0
+	for i, line := range lines {
+		if b.LineStart(i) != offset {
+			t.Fatalf("Wrong start of line %d. Expected=%d, got=%d", i, offset, b.LineStart(i))
+		}
+		for j := offset; j <= offset+len(line); j++ {
+			if b.LineAt(j) != i {
+				t.Fatalf("Wrong line at %d. Expected=%d, got=%d", j, i, b.LineAt(j))
+			}
+		}
+		offset += len(line) + 1
+	}
+	if b.LineStart(len(lines)) != len(expected) {
+		t.Fatalf("Expected the end for the line after the last, got=%d", b.LineStart(len(lines)))
+	}
+}
+
+func TestTextBuffer(t *testing.T) {
+	for _, backend := range backends {
+		t.Run(backend.name, func(t *testing.T) {
+			b := backend.new("")
+			checkText(t, b, "")
+
+			b.Insert(0, "hello world")
+			checkText(t, b, "hello world")
+			b.Insert(5, ",\nbig")
+			checkText(t, b, "hello,\nbig world")
+			b.Insert(b.Len(), "\n")
+			checkText(t, b, "hello,\nbig world\n")
+			b.Insert(0, "> ")
+			checkText(t, b, "> hello,\nbig world\n")
+
+			if got := b.Slice(2, 7); got != "hello" {
+				t.Fatalf("Wrong slice, got=%q", got)
+			}
+			if got := b.Slice(8, 100); got != "\nbig world\n" {
+				t.Fatalf("Wrong slice at the end, got=%q", got)
+			}
+			if got := b.Slice(5, 3); got != "" {
+				t.Fatalf("Expected an empty slice, got=%q", got)
+			}
+
+			b.Delete(7, 5)
+			checkText(t, b, "> hello world\n")
+			b.Delete(0, 2)
+			checkText(t, b, "hello world\n")
+			b.Delete(5, 100)
+			checkText(t, b, "hello")
+			b.Delete(-3, 0)
+			b.Insert(-3, "oh ")
+			checkText(t, b, "oh hello")
+		})
+	}
+}
+
+// Random edits, checked against the same edits made to a string
+func TestTextBufferRandomEdits(t *testing.T) {
+	for _, backend := range backends {
+		t.Run(backend.name, func(t *testing.T) {
+			random := rand.New(rand.NewSource(1))
+			expected := "first line\nsecond line\n"
+			b := backend.new(expected)
+			for i := 0; i < 300; i++ {
+				offset := random.Intn(len(expected) + 1)
+				if random.Intn(3) == 0 {
+					length := random.Intn(10)
+					b.Delete(offset, length)
+					expected = expected[:offset] + expected[min(offset+length, len(expected)):]
+				} else {
+					s := []string{"a", "bc\n", "\n", "word ", "é"}[random.Intn(5)]
+					b.Insert(offset, s)
+					expected = expected[:offset] + s + expected[offset:]
+				}
+				if b.String() != expected {
+					t.Fatalf("Edit %d. Expected=%q, got=%q", i, expected, b.String())
+				}
+			}
+			checkText(t, b, expected)
+		})
+	}
+}
+
+func TestApply(t *testing.T) {
+	for _, backend := range backends {
+		t.Run(backend.name, func(t *testing.T) {
+			b := backend.new("hello world")
+			inverse := Apply(b, rope.Transaction{{Offset: 6, Length: 5, Text: "there"}, {Offset: 0, Text: "oh, "}})
+			checkText(t, b, "oh, hello there")
+			Apply(b, inverse)
+			checkText(t, b, "hello world")
+		})
+	}
+}
+
+func benchmarkBackends(b *testing.B, run func(b *testing.B, text TextBuffer)) {
+	content := strings.Repeat("a line of text in a file of some size\n", 20000)
+	for _, backend := range backends {
+		b.Run(backend.name, func(b *testing.B) {
+			run(b, backend.new(content))
+		})
+	}
+}
+
+// Typing at one place, like in insert mode
+func BenchmarkTyping(b *testing.B) {
+	benchmarkBackends(b, func(b *testing.B, text TextBuffer) {
+		offset := text.Len() / 2
+		for i := 0; i < b.N; i++ {
+			text.Insert(offset+i%1000, "x")
+		}
+	})
+}
+
+// Edits all over the text
+func BenchmarkRandomEdits(b *testing.B) {
+	benchmarkBackends(b, func(b *testing.B, text TextBuffer) {
+		random := rand.New(rand.NewSource(1))
+		for i := 0; i < b.N; i++ {
+			offset := random.Intn(text.Len())
+			if i%2 == 0 {
+				text.Insert(offset, "word ")
+			} else {
+				text.Delete(offset, 5)
+			}
+		}
+	})
+}
+
+// Getting a screen of lines
+func BenchmarkLines(b *testing.B) {
+	benchmarkBackends(b, func(b *testing.B, text TextBuffer) {
+		random := rand.New(rand.NewSource(1))
+		for i := 0; i < b.N; i++ {
+			line := random.Intn(text.LineCount() - 50)
+			text.Slice(text.LineStart(line), text.LineStart(line+50))
+		}
+	})
+}
+
+func BenchmarkString(b *testing.B) {
+	benchmarkBackends(b, func(b *testing.B, text TextBuffer) {
+		for i := 0; i < b.N; i++ {
+			_ = text.String()
+		}
+	})
+}